@@ -16,9 +16,14 @@ import (
 	"github.com/PRM710/Rankedterview-backend/internal/config"
 	"github.com/PRM710/Rankedterview-backend/internal/database"
 	"github.com/PRM710/Rankedterview-backend/internal/handlers"
+	"github.com/PRM710/Rankedterview-backend/internal/mailer"
 	"github.com/PRM710/Rankedterview-backend/internal/middleware"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/recall"
 	"github.com/PRM710/Rankedterview-backend/internal/repositories"
 	"github.com/PRM710/Rankedterview-backend/internal/services"
+	"github.com/PRM710/Rankedterview-backend/internal/storage"
+	"github.com/PRM710/Rankedterview-backend/internal/utils"
 	"github.com/PRM710/Rankedterview-backend/internal/websocket"
 	"github.com/PRM710/Rankedterview-backend/pkg/logger"
 )
@@ -65,28 +70,83 @@ func main() {
 	interviewRepo := repositories.NewInterviewRepository(mongoDB)
 	roomRepo := repositories.NewRoomRepository(mongoDB)
 	rankingRepo := repositories.NewRankingRepository(mongoDB)
+	usageRepo := repositories.NewUsageRepository(mongoDB)
+	sessionRepo := repositories.NewSessionRepository(mongoDB)
+	banRepo := repositories.NewBanRepository(mongoDB)
+	apiTokenRepo := repositories.NewAPITokenRepository(mongoDB)
+	auditRepo := repositories.NewAuditRepository(mongoDB)
+
+	if err := sessionRepo.EnsureIndexes(context.Background()); err != nil {
+		loggerInstance.Fatal("Failed to create session indexes: %v", err)
+	}
+
+	// Initialize mailer
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		loggerInstance.Fatal("Failed to initialize mailer: %v", err)
+	}
+
+	// Initialize object storage (R2, or any S3-compatible endpoint)
+	storageEndpoint := cfg.R2Endpoint
+	if storageEndpoint == "" && cfg.R2AccountID != "" {
+		storageEndpoint = fmt.Sprintf("https://%s.r2.cloudflarestorage.com", cfg.R2AccountID)
+	}
+	storageClient, err := storage.NewClient(storage.Options{
+		Endpoint:        storageEndpoint,
+		Region:          cfg.R2Region,
+		Bucket:          cfg.R2BucketName,
+		AccessKeyID:     cfg.R2AccessKeyID,
+		SecretAccessKey: cfg.R2SecretAccessKey,
+	})
+	if err != nil {
+		loggerInstance.Fatal("Failed to initialize storage: %v", err)
+	}
+
+	// Initialize WebSocket hub
+	hub := websocket.NewHub(redisClient, cfg.JWTSecret)
 
 	// Initialize services
-	authService := services.NewAuthService(userRepo, cfg)
+	moderationService := services.NewModerationService(banRepo, redisClient, hub)
+	authService := services.NewAuthService(userRepo, sessionRepo, redisClient, mail, moderationService, hub, cfg)
 	userService := services.NewUserService(userRepo)
-	matchmakingService := services.NewMatchmakingService(redisClient, roomRepo)
+	matchmakingService := services.NewMatchmakingService(redisClient, roomRepo, moderationService)
 	roomService := services.NewRoomService(roomRepo, redisClient)
 	interviewService := services.NewInterviewService(interviewRepo, roomRepo)
 	rankingService := services.NewRankingService(rankingRepo, redisClient)
+	usageService := services.NewUsageService(usageRepo, cfg)
+	transcriptService := services.NewTranscriptService(interviewService, userService)
+	apiTokenService := services.NewAPITokenService(apiTokenRepo, redisClient, moderationService, cfg)
 
-	// Initialize WebSocket hub
-	hub := websocket.NewHub(redisClient)
+	evaluationService := services.NewEvaluationService(cfg)
+	coachingService := services.NewCoachingService(redisClient, evaluationService, usageService, hub, cfg)
+	hub.SetTranscriptRelay(coachingService)
+	hub.SetSessionChecker(authService)
+
+	recallClient := recall.NewClient(cfg.RecallBaseURL, cfg.RecallAPIKey)
+	recordingService := services.NewRecordingService(recallClient, storageClient, interviewService, roomService, rankingService, hub, redisClient, cfg)
+	hub.SetRoomObserver(recordingService)
+
+	reconnectGrace, err := utils.ParseDuration(cfg.ReconnectGracePeriod)
+	if err != nil {
+		loggerInstance.Fatal("Invalid RECONNECT_GRACE_PERIOD: %v", err)
+	}
+	hub.SetReconnectGrace(reconnectGrace)
 	go hub.Run()
 
+	retentionService := services.NewRetentionService(interviewService, storageClient, auditRepo, redisClient, cfg)
+	go retentionService.Run()
+
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(authService)
+	authHandler := handlers.NewAuthHandler(authService, cfg)
 	userHandler := handlers.NewUserHandler(userService)
+	apiTokenHandler := handlers.NewAPITokenHandler(apiTokenService)
 	matchmakingHandler := handlers.NewMatchmakingHandler(matchmakingService, hub)
 	roomHandler := handlers.NewRoomHandler(roomService)
-	interviewHandler := handlers.NewInterviewHandler(interviewService)
+	interviewHandler := handlers.NewInterviewHandler(interviewService, recordingService, retentionService)
 	rankingHandler := handlers.NewRankingHandler(rankingService)
-	webhookHandler := handlers.NewWebhookHandler(interviewService, rankingService, cfg)
-	wsHandler := handlers.NewWebSocketHandler(hub)
+	webhookHandler := handlers.NewWebhookHandler(interviewService, transcriptService, recordingService, rankingService, usageService, coachingService, hub, cfg)
+	wsHandler := handlers.NewWebSocketHandler(hub, authService, moderationService)
+	adminHandler := handlers.NewAdminHandler(userService, authService, moderationService, matchmakingService, roomService, recordingService, rankingService, usageService, hub)
 
 	// Set up Gin router
 	if cfg.Environment == "production" {
@@ -119,17 +179,45 @@ func main() {
 			auth.GET("/oauth/github", authHandler.GitHubOAuth)
 			auth.POST("/callback", authHandler.OAuthCallback)
 			auth.POST("/refresh", authHandler.RefreshToken)
+			auth.POST("/verify-email", authHandler.VerifyEmail)
+			auth.POST("/verify-email/resend", authHandler.ResendVerification)
+			auth.POST("/password/forgot", authHandler.ForgotPassword)
+			auth.POST("/password/reset", authHandler.ResetPassword)
+		}
+
+		// Read-only routes personal access tokens may call, and the scope each needs
+		apiTokenScopes := map[string]string{
+			"GET /api/v1/users/me":                    models.ScopeProfileRead,
+			"GET /api/v1/users/:id":                   models.ScopeProfileRead,
+			"GET /api/v1/users/:id/stats":             models.ScopeProfileRead,
+			"GET /api/v1/interviews":                  models.ScopeInterviewsRead,
+			"GET /api/v1/interviews/:id":              models.ScopeInterviewsRead,
+			"GET /api/v1/interviews/:id/transcript":   models.ScopeInterviewsRead,
+			"GET /api/v1/interviews/:id/feedback":     models.ScopeInterviewsRead,
+			"GET /api/v1/rankings/global":             models.ScopeRankingsRead,
+			"GET /api/v1/rankings/category/:category": models.ScopeRankingsRead,
+			"GET /api/v1/rankings/user/:userId":       models.ScopeRankingsRead,
+			"GET /api/v1/rankings/history/:userId":    models.ScopeRankingsRead,
 		}
 
 		// Protected routes
 		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+		protected.Use(middleware.AuthMiddleware(cfg.JWTSecret, authService, moderationService, apiTokenService, apiTokenScopes))
 		{
+			protected.POST("/auth/logout", authHandler.Logout)
+
 			// User routes
 			users := protected.Group("/users")
 			{
 				users.GET("/me", userHandler.GetCurrentUser)
 				users.PUT("/me", userHandler.UpdateProfile)
+				users.GET("/me/sessions", authHandler.ListSessions)
+				users.DELETE("/me/sessions/:sessionId", authHandler.RevokeSession)
+				users.POST("/me/identities", authHandler.LinkIdentity)
+				users.DELETE("/me/identities/:provider", authHandler.UnlinkIdentity)
+				users.GET("/me/tokens", apiTokenHandler.ListTokens)
+				users.POST("/me/tokens", apiTokenHandler.CreateToken)
+				users.DELETE("/me/tokens/:tokenId", apiTokenHandler.RevokeToken)
 				users.GET("/:id", userHandler.GetUser)
 				users.GET("/:id/stats", userHandler.GetUserStats)
 			}
@@ -158,6 +246,7 @@ func main() {
 				interviews.GET("/:id", interviewHandler.GetInterview)
 				interviews.GET("/:id/transcript", interviewHandler.GetTranscript)
 				interviews.GET("/:id/recording", interviewHandler.GetRecordingURLs)
+				interviews.DELETE("/:id/recording", interviewHandler.DeleteRecording)
 				interviews.GET("/:id/feedback", interviewHandler.GetFeedback)
 			}
 
@@ -169,12 +258,45 @@ func main() {
 				rankings.GET("/user/:userId", rankingHandler.GetUserRank)
 				rankings.GET("/history/:userId", rankingHandler.GetRankHistory)
 			}
+
+			// Admin routes (moderators can inspect; admins can change things)
+			admin := protected.Group("/admin")
+			admin.Use(middleware.RequireRole(models.RoleModerator))
+			{
+				requireAdmin := middleware.RequireRole(models.RoleAdmin)
+
+				admin.GET("/users", adminHandler.ListUsers)
+				admin.GET("/users/:id", adminHandler.GetUser)
+				admin.PUT("/users/:id/role", requireAdmin, adminHandler.SetUserRole)
+				admin.DELETE("/users/:id", requireAdmin, adminHandler.DeleteUser)
+				admin.GET("/users/:id/bans", adminHandler.ListBans)
+				admin.POST("/users/:id/ban", adminHandler.BanUser)
+				admin.DELETE("/users/:id/ban", adminHandler.LiftBan)
+
+				admin.GET("/rooms", adminHandler.ListRooms)
+				admin.GET("/rooms/:roomId", adminHandler.GetRoom)
+				admin.POST("/rooms/:roomId/terminate", adminHandler.TerminateRoom)
+				admin.POST("/rooms/cleanup", requireAdmin, adminHandler.CleanupRooms)
+
+				admin.PUT("/rankings/:userId", requireAdmin, adminHandler.AdjustRanking)
+
+				admin.GET("/usage", requireAdmin, adminHandler.GetUsageReport)
+			}
 		}
 
-		// Webhook routes (authenticated differently)
+		// Webhook routes (authenticated by signature)
+		webhookTolerance, err := utils.ParseDuration(cfg.RecallWebhookTolerance)
+		if err != nil {
+			loggerInstance.Fatal("Invalid RECALL_WEBHOOK_TOLERANCE: %v", err)
+		}
+		if cfg.RecallWebhookSecret == "" {
+			loggerInstance.Warn("RECALL_WEBHOOK_SECRET is not set; all Recall webhooks will be rejected")
+		}
 		webhooks := v1.Group("/webhooks")
+		webhooks.Use(middleware.VerifyRecallWebhook(cfg.RecallWebhookSecret, webhookTolerance, redisClient))
 		{
 			webhooks.POST("/recall", webhookHandler.RecallWebhook)
+			webhooks.POST("/recall/realtime", webhookHandler.RecallRealtimeWebhook)
 		}
 	}
 
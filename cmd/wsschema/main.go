@@ -0,0 +1,33 @@
+// Command wsschema writes the JSON Schema of the WebSocket event protocol,
+// so frontend clients can be type-checked against the server's events.
+//
+//	go run ./cmd/wsschema -o api/websocket.schema.json
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/PRM710/Rankedterview-backend/internal/websocket"
+)
+
+func main() {
+	output := flag.String("o", "", "file to write the schema to (default stdout)")
+	flag.Parse()
+
+	schema, err := websocket.Schema()
+	if err != nil {
+		log.Fatalf("Failed to generate schema: %v", err)
+	}
+	schema = append(schema, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(schema)
+		return
+	}
+
+	if err := os.WriteFile(*output, schema, 0o644); err != nil {
+		log.Fatalf("Failed to write schema: %v", err)
+	}
+}
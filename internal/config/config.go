@@ -34,24 +34,86 @@ type Config struct {
 	GitHubClientSecret string
 	GitHubRedirectURI  string
 
+	// OAuth provider endpoints (overridable to point at a local fake)
+	GoogleAuthURL     string
+	GoogleTokenURL    string
+	GoogleUserInfoURL string
+	GitHubAuthURL     string
+	GitHubTokenURL    string
+	GitHubAPIURL      string
+
+	// Email/password accounts
+	AppBaseURL               string // frontend URL used in verification and reset links
+	EmailVerificationTTL     string
+	PasswordResetTTL         string
+	RequireEmailVerification bool
+
+	// Mail
+	MailBackend  string // "smtp", "file" or "log"
+	MailFrom     string
+	MailFileDir  string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
 	// Cloudflare R2
 	R2AccountID       string
 	R2AccessKeyID     string
 	R2SecretAccessKey string
 	R2BucketName      string
 	R2PublicURL       string
-	R2Endpoint        string
+	R2Endpoint        string // any S3-compatible endpoint, e.g. a local MinIO; defaults to the account's R2 endpoint
+	R2Region          string
+	RecordingURLTTL   string // lifetime of presigned recording URLs
+
+	// Recording consent: users consent to this version of the recording policy
+	RecordingPolicyVersion string
+	RecordingPolicyURL     string
+
+	// Recording retention ("0" keeps files forever; transcripts are kept
+	// until their participants delete them)
+	RecordingVideoRetention string
+	RecordingAudioRetention string
+	RetentionPurgeInterval  string
+
+	// How long a participant who drops out of a call has to reconnect before
+	// leaving forfeits (ranked) or abandons the interview ("0" ends it at once)
+	ReconnectGracePeriod string
 
 	// Recall.ai
-	RecallAPIKey        string
-	RecallWebhookSecret string
-	RecallBotName       string
+	RecallAPIKey           string
+	RecallBaseURL          string
+	RecallMeetingURL       string // URL the bot joins; "{roomId}" is replaced with the room
+	RecallWebhookSecret    string
+	RecallWebhookTolerance string // max clock skew of a signed delivery
+	RecallBotName          string
 
 	// OpenAI
 	OpenAIKey       string
 	OpenAIModel     string
 	OpenAIMaxTokens int
 
+	// EvaluationChunkTokens is the estimated transcript size above which
+	// evaluation switches to per-chunk analysis plus an aggregation pass
+	EvaluationChunkTokens int
+
+	// AI usage accounting and budgets (a zero budget disables that limit)
+	OpenAIPricing          string // "model=prompt/completion" USD per 1M tokens, comma separated
+	OpenAIFallbackModel    string
+	AIUserDailyTokenBudget int
+	AIUserDailyCostBudget  float64
+	AIDailyCostBudget      float64
+	AIBudgetAction         string // "downgrade" or "block"
+
+	// Live coaching (practice rooms only)
+	CoachingEnabled      bool
+	CoachingHintInterval string
+	CoachingMaxHints     int
+
+	// Bootstrap admins: these user IDs always get the admin role
+	AdminUserIDs []string
+
 	// CORS
 	AllowedOrigins []string
 
@@ -64,6 +126,10 @@ type Config struct {
 	// Rate Limiting
 	RateLimitRequests int
 	RateLimitWindow   string
+
+	// Personal access tokens
+	APITokenRateLimit  int // requests per minute, per token
+	APITokenMaxPerUser int
 }
 
 // LoadConfig loads configuration from environment variables
@@ -95,6 +161,29 @@ func LoadConfig() *Config {
 		GitHubClientSecret: getEnv("GITHUB_CLIENT_SECRET", ""),
 		GitHubRedirectURI:  getEnv("GITHUB_REDIRECT_URI", "http://localhost:3000/callback"),
 
+		// OAuth provider endpoints
+		GoogleAuthURL:     getEnv("GOOGLE_AUTH_URL", "https://accounts.google.com/o/oauth2/v2/auth"),
+		GoogleTokenURL:    getEnv("GOOGLE_TOKEN_URL", "https://oauth2.googleapis.com/token"),
+		GoogleUserInfoURL: getEnv("GOOGLE_USERINFO_URL", "https://openidconnect.googleapis.com/v1/userinfo"),
+		GitHubAuthURL:     getEnv("GITHUB_AUTH_URL", "https://github.com/login/oauth/authorize"),
+		GitHubTokenURL:    getEnv("GITHUB_TOKEN_URL", "https://github.com/login/oauth/access_token"),
+		GitHubAPIURL:      getEnv("GITHUB_API_URL", "https://api.github.com"),
+
+		// Email/password accounts
+		AppBaseURL:               getEnv("APP_BASE_URL", "http://localhost:3000"),
+		EmailVerificationTTL:     getEnv("EMAIL_VERIFICATION_TTL", "24h"),
+		PasswordResetTTL:         getEnv("PASSWORD_RESET_TTL", "1h"),
+		RequireEmailVerification: getEnvAsBool("REQUIRE_EMAIL_VERIFICATION", true),
+
+		// Mail
+		MailBackend:  getEnv("MAIL_BACKEND", "log"),
+		MailFrom:     getEnv("MAIL_FROM", "RANKEDterview <no-reply@rankedterview.local>"),
+		MailFileDir:  getEnv("MAIL_FILE_DIR", "./tmp/mail"),
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
 		// Cloudflare R2
 		R2AccountID:       getEnv("R2_ACCOUNT_ID", ""),
 		R2AccessKeyID:     getEnv("R2_ACCESS_KEY_ID", ""),
@@ -102,17 +191,52 @@ func LoadConfig() *Config {
 		R2BucketName:      getEnv("R2_BUCKET_NAME", "rankedterview-recordings"),
 		R2PublicURL:       getEnv("R2_PUBLIC_URL", ""),
 		R2Endpoint:        getEnv("R2_ENDPOINT", ""),
+		R2Region:          getEnv("R2_REGION", "auto"),
+		RecordingURLTTL:   getEnv("RECORDING_URL_TTL", "15m"),
+
+		// Recording consent
+		RecordingPolicyVersion: getEnv("RECORDING_POLICY_VERSION", "2024-01"),
+		RecordingPolicyURL:     getEnv("RECORDING_POLICY_URL", "http://localhost:3000/legal/recording"),
+
+		// Recording retention
+		RecordingVideoRetention: getEnv("RECORDING_VIDEO_RETENTION", "30d"),
+		RecordingAudioRetention: getEnv("RECORDING_AUDIO_RETENTION", "90d"),
+		RetentionPurgeInterval:  getEnv("RETENTION_PURGE_INTERVAL", "1h"),
+
+		// Reconnect grace
+		ReconnectGracePeriod: getEnv("RECONNECT_GRACE_PERIOD", "30s"),
 
 		// Recall.ai
-		RecallAPIKey:        getEnv("RECALL_API_KEY", ""),
-		RecallWebhookSecret: getEnv("RECALL_WEBHOOK_SECRET", ""),
-		RecallBotName:       getEnv("RECALL_BOT_NAME", "RANKEDterview Recorder"),
+		RecallAPIKey:           getEnv("RECALL_API_KEY", ""),
+		RecallBaseURL:          getEnv("RECALL_BASE_URL", "https://us-east-1.recall.ai"),
+		RecallMeetingURL:       getEnv("RECALL_MEETING_URL", "http://localhost:3000/room/{roomId}"),
+		RecallWebhookSecret:    getEnv("RECALL_WEBHOOK_SECRET", ""),
+		RecallWebhookTolerance: getEnv("RECALL_WEBHOOK_TOLERANCE", "5m"),
+		RecallBotName:          getEnv("RECALL_BOT_NAME", "RANKEDterview Recorder"),
 
 		// OpenAI
 		OpenAIKey:       getEnv("OPENAI_API_KEY", ""),
 		OpenAIModel:     getEnv("OPENAI_MODEL", "gpt-4o"),
 		OpenAIMaxTokens: getEnvAsInt("OPENAI_MAX_TOKENS", 2000),
 
+		EvaluationChunkTokens: getEnvAsInt("EVALUATION_CHUNK_TOKENS", 6000),
+
+		// AI usage accounting and budgets
+		OpenAIPricing:          getEnv("OPENAI_PRICING", "gpt-4o=2.50/10.00,gpt-4o-mini=0.15/0.60"),
+		OpenAIFallbackModel:    getEnv("OPENAI_FALLBACK_MODEL", "gpt-4o-mini"),
+		AIUserDailyTokenBudget: getEnvAsInt("AI_USER_DAILY_TOKEN_BUDGET", 200000),
+		AIUserDailyCostBudget:  getEnvAsFloat("AI_USER_DAILY_COST_BUDGET", 0),
+		AIDailyCostBudget:      getEnvAsFloat("AI_DAILY_COST_BUDGET", 0),
+		AIBudgetAction:         getEnv("AI_BUDGET_ACTION", "downgrade"),
+
+		// Live coaching
+		CoachingEnabled:      getEnvAsBool("COACHING_ENABLED", true),
+		CoachingHintInterval: getEnv("COACHING_HINT_INTERVAL", "45s"),
+		CoachingMaxHints:     getEnvAsInt("COACHING_MAX_HINTS", 10),
+
+		// Admin
+		AdminUserIDs: getEnvAsSlice("ADMIN_USER_IDS", []string{}),
+
 		// CORS
 		AllowedOrigins: getEnvAsSlice("ALLOWED_ORIGINS", []string{"http://localhost:3000"}),
 
@@ -125,6 +249,10 @@ func LoadConfig() *Config {
 		// Rate Limiting
 		RateLimitRequests: getEnvAsInt("RATE_LIMIT_REQUESTS", 100),
 		RateLimitWindow:   getEnv("RATE_LIMIT_WINDOW", "1m"),
+
+		// Personal access tokens
+		APITokenRateLimit:  getEnvAsInt("API_TOKEN_RATE_LIMIT", 60),
+		APITokenMaxPerUser: getEnvAsInt("API_TOKEN_MAX_PER_USER", 10),
 	}
 }
 
@@ -150,6 +278,30 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return value
 }
 
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
 func getEnvAsSlice(key string, defaultValue []string) []string {
 	valueStr := os.Getenv(key)
 	if valueStr == "" {
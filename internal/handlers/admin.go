@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PRM710/Rankedterview-backend/internal/middleware"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/services"
+	"github.com/PRM710/Rankedterview-backend/internal/utils"
+	"github.com/PRM710/Rankedterview-backend/internal/websocket"
+)
+
+type AdminHandler struct {
+	userService        *services.UserService
+	authService        *services.AuthService
+	moderationService  *services.ModerationService
+	matchmakingService *services.MatchmakingService
+	roomService        *services.RoomService
+	recordingService   *services.RecordingService
+	rankingService     *services.RankingService
+	usageService       *services.UsageService
+	hub                *websocket.Hub
+}
+
+func NewAdminHandler(
+	userService *services.UserService,
+	authService *services.AuthService,
+	moderationService *services.ModerationService,
+	matchmakingService *services.MatchmakingService,
+	roomService *services.RoomService,
+	recordingService *services.RecordingService,
+	rankingService *services.RankingService,
+	usageService *services.UsageService,
+	hub *websocket.Hub,
+) *AdminHandler {
+	return &AdminHandler{
+		userService:        userService,
+		authService:        authService,
+		moderationService:  moderationService,
+		matchmakingService: matchmakingService,
+		roomService:        roomService,
+		recordingService:   recordingService,
+		rankingService:     rankingService,
+		usageService:       usageService,
+		hub:                hub,
+	}
+}
+
+// ListUsers lists all users with pagination
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	page, limit := pagination(c)
+
+	users, total, err := h.userService.ListUsers(c.Request.Context(), page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to list users")
+		return
+	}
+
+	responses := make([]models.UserResponse, len(users))
+	for i, user := range users {
+		responses[i] = user.ToResponse()
+	}
+
+	utils.PaginatedResponse(c, responses, page, limit, total)
+}
+
+// GetUser retrieves any user's full profile
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	user, err := h.userService.GetUser(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.NotFoundResponse(c, "User not found")
+		return
+	}
+
+	utils.SuccessResponse(c, user.ToResponse())
+}
+
+// SetUserRole changes a user's role. Admins can't change their own role.
+func (h *AdminHandler) SetUserRole(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+	userID := c.Param("id")
+
+	var input struct {
+		Role string `json:"role" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	if userID == adminID {
+		utils.BadRequestResponse(c, "You can't change your own role")
+		return
+	}
+
+	user, err := h.userService.SetRole(c.Request.Context(), userID, input.Role)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidRole:
+			utils.BadRequestResponse(c, "Role must be one of: user, moderator, admin")
+		case services.ErrUserNotFound:
+			utils.NotFoundResponse(c, "User not found")
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to update role")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, user.ToResponse())
+}
+
+// DeleteUser deletes a user and revokes all of their sessions
+func (h *AdminHandler) DeleteUser(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+	userID := c.Param("id")
+
+	if userID == adminID {
+		utils.BadRequestResponse(c, "You can't delete your own account here")
+		return
+	}
+
+	if _, err := h.userService.GetUser(c.Request.Context(), userID); err != nil {
+		utils.NotFoundResponse(c, "User not found")
+		return
+	}
+
+	if err := h.authService.RevokeAllSessions(c.Request.Context(), userID, "deleted"); err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to revoke sessions")
+		return
+	}
+
+	if err := h.userService.DeleteUser(c.Request.Context(), userID); err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to delete user")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "User deleted"})
+}
+
+// BanUser bans a user, permanently or for ?duration, revokes their sessions
+// and kicks them off matchmaking and their live WebSocket connection. Staff
+// can only ban users with a lower role than their own.
+func (h *AdminHandler) BanUser(c *gin.Context) {
+	moderatorID, _ := middleware.GetUserID(c)
+	moderatorRole, _ := middleware.GetUserRole(c)
+	userID := c.Param("id")
+
+	var input struct {
+		Reason   string `json:"reason" binding:"required"`
+		Duration string `json:"duration"` // e.g. "7d" or "12h"; empty means permanent
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	var duration time.Duration
+	if input.Duration != "" {
+		var err error
+		duration, err = utils.ParseDuration(input.Duration)
+		if err != nil || duration <= 0 {
+			utils.BadRequestResponse(c, "duration must be a duration such as 7d or 12h")
+			return
+		}
+	}
+
+	if userID == moderatorID {
+		utils.BadRequestResponse(c, "You can't ban yourself")
+		return
+	}
+
+	user, err := h.userService.GetUser(c.Request.Context(), userID)
+	if err != nil {
+		utils.NotFoundResponse(c, "User not found")
+		return
+	}
+
+	if models.RoleAtLeast(h.authService.RoleOf(user), moderatorRole) {
+		utils.ErrorResponse(c, http.StatusForbidden, "You can only ban users with a lower role than yours")
+		return
+	}
+
+	ban, err := h.moderationService.BanUser(c.Request.Context(), userID, moderatorID, input.Reason, duration)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to ban user")
+		return
+	}
+
+	// The ban already blocks their access tokens, so a failure here isn't fatal
+	if err := h.authService.RevokeAllSessions(c.Request.Context(), userID, "banned"); err != nil {
+		log.Printf("Failed to revoke sessions of banned user %s: %v", userID, err)
+	}
+
+	if err := h.matchmakingService.LeaveQueue(c.Request.Context(), userID); err != nil {
+		log.Printf("Failed to remove banned user %s from queue: %v", userID, err)
+	}
+
+	utils.CreatedResponse(c, ban)
+}
+
+// LiftBan lifts a user's active bans
+func (h *AdminHandler) LiftBan(c *gin.Context) {
+	moderatorID, _ := middleware.GetUserID(c)
+
+	err := h.moderationService.LiftBan(c.Request.Context(), c.Param("id"), moderatorID)
+	if err != nil {
+		if err == services.ErrNoActiveBan {
+			utils.NotFoundResponse(c, "User has no active ban")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to lift ban")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Ban lifted"})
+}
+
+// ListBans lists a user's ban history
+func (h *AdminHandler) ListBans(c *gin.Context) {
+	bans, err := h.moderationService.ListBans(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		utils.BadRequestResponse(c, "Invalid user ID")
+		return
+	}
+
+	utils.SuccessResponse(c, bans)
+}
+
+// ListRooms lists rooms, optionally filtered by ?status=waiting|active|ended
+func (h *AdminHandler) ListRooms(c *gin.Context) {
+	page, limit := pagination(c)
+
+	rooms, total, err := h.roomService.ListRooms(c.Request.Context(), c.Query("status"), page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to list rooms")
+		return
+	}
+
+	responses := make([]models.RoomResponse, len(rooms))
+	for i, room := range rooms {
+		responses[i] = room.ToResponse()
+	}
+
+	utils.PaginatedResponse(c, responses, page, limit, total)
+}
+
+// GetRoom retrieves a room along with its live state from Redis
+func (h *AdminHandler) GetRoom(c *gin.Context) {
+	roomID := c.Param("roomId")
+
+	room, err := h.roomService.GetRoom(c.Request.Context(), roomID)
+	if err != nil {
+		utils.NotFoundResponse(c, "Room not found")
+		return
+	}
+
+	state, err := h.roomService.GetRoomState(c.Request.Context(), roomID)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to get room state")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"room":  room.ToResponse(),
+		"state": state,
+	})
+}
+
+// TerminateRoom force-ends a room, completing its interview, and tells its
+// participants the call is over
+func (h *AdminHandler) TerminateRoom(c *gin.Context) {
+	roomID := c.Param("roomId")
+
+	room, err := h.recordingService.TerminateRoom(c.Request.Context(), roomID)
+	if err != nil {
+		switch err {
+		case services.ErrRoomNotFound:
+			utils.NotFoundResponse(c, "Room not found")
+		case services.ErrRoomNotActive:
+			utils.ConflictResponse(c, "Room has already ended")
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to terminate room")
+		}
+		return
+	}
+
+	h.hub.BroadcastToRoom(roomID, websocket.CallEndEvent{
+		Type:   websocket.EventCallEnd,
+		RoomID: roomID,
+		Reason: "terminated",
+	})
+
+	utils.SuccessResponse(c, room.ToResponse())
+}
+
+// CleanupRooms deletes ended rooms older than ?olderThan (default 30d)
+func (h *AdminHandler) CleanupRooms(c *gin.Context) {
+	olderThan, err := utils.ParseDuration(c.DefaultQuery("olderThan", "30d"))
+	if err != nil || olderThan <= 0 {
+		utils.BadRequestResponse(c, "olderThan must be a duration such as 30d or 12h")
+		return
+	}
+
+	if err := h.roomService.CleanupOldRooms(c.Request.Context(), olderThan); err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to clean up rooms")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Old rooms cleaned up"})
+}
+
+// AdjustRanking manually corrects a user's Elo in a category
+func (h *AdminHandler) AdjustRanking(c *gin.Context) {
+	adminID, _ := middleware.GetUserID(c)
+
+	var input struct {
+		Category string `json:"category"`
+		Elo      int    `json:"elo" binding:"required,min=0"`
+		Reason   string `json:"reason" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	if input.Category == "" {
+		input.Category = "overall"
+	}
+
+	ranking, err := h.rankingService.AdjustRanking(
+		c.Request.Context(),
+		c.Param("userId"),
+		input.Category,
+		input.Elo,
+		input.Reason,
+		adminID,
+	)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidCategory:
+			utils.BadRequestResponse(c, "Category must be one of: "+strings.Join(models.RankingCategories, ", "))
+		case services.ErrRankingNotFound:
+			utils.NotFoundResponse(c, "Ranking not found")
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to adjust ranking")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, ranking.ToResponse())
+}
+
+// GetUsageReport reports AI token usage and cost, grouped by user, day and/or model
+func (h *AdminHandler) GetUsageReport(c *gin.Context) {
+	now := time.Now().UTC()
+	from := c.DefaultQuery("from", now.AddDate(0, 0, -30).Format("2006-01-02"))
+	to := c.DefaultQuery("to", now.Format("2006-01-02"))
+
+	for _, day := range []string{from, to} {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			utils.BadRequestResponse(c, "Dates must use the YYYY-MM-DD format")
+			return
+		}
+	}
+
+	validGroups := map[string]bool{
+		"userId": true,
+		"day":    true,
+		"model":  true,
+	}
+
+	groupBy := strings.Split(c.DefaultQuery("groupBy", "day,model"), ",")
+	for _, field := range groupBy {
+		if !validGroups[field] {
+			utils.BadRequestResponse(c, "Invalid groupBy field: "+field)
+			return
+		}
+	}
+
+	entries, err := h.usageService.GetReport(c.Request.Context(), from, to, groupBy)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to build usage report")
+		return
+	}
+
+	var totalTokens int64
+	var totalCost float64
+	for _, entry := range entries {
+		totalTokens += entry.TotalTokens
+		totalCost += entry.CostUSD
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"from":    from,
+		"to":      to,
+		"groupBy": groupBy,
+		"entries": entries,
+		"totals": gin.H{
+			"totalTokens": totalTokens,
+			"costUsd":     totalCost,
+		},
+	})
+}
+
+// pagination reads ?page and ?limit, clamped to sensible values
+func pagination(c *gin.Context) (int64, int64) {
+	page, _ := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	limit, _ := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	return page, limit
+}
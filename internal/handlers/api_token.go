@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PRM710/Rankedterview-backend/internal/middleware"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/services"
+	"github.com/PRM710/Rankedterview-backend/internal/utils"
+)
+
+type APITokenHandler struct {
+	apiTokenService *services.APITokenService
+}
+
+func NewAPITokenHandler(apiTokenService *services.APITokenService) *APITokenHandler {
+	return &APITokenHandler{
+		apiTokenService: apiTokenService,
+	}
+}
+
+// CreateToken creates a personal access token. The token itself is only
+// returned in this response.
+func (h *APITokenHandler) CreateToken(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	var input struct {
+		Name      string   `json:"name" binding:"required"`
+		Scopes    []string `json:"scopes" binding:"required"`
+		ExpiresIn string   `json:"expiresIn"` // e.g. "90d"; empty never expires
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	var expiresIn time.Duration
+	if input.ExpiresIn != "" {
+		var err error
+		expiresIn, err = utils.ParseDuration(input.ExpiresIn)
+		if err != nil || expiresIn <= 0 {
+			utils.BadRequestResponse(c, "expiresIn must be a duration such as 90d or 12h")
+			return
+		}
+	}
+
+	token, secret, err := h.apiTokenService.CreateToken(c.Request.Context(), userID, input.Name, input.Scopes, expiresIn)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidScope:
+			utils.BadRequestResponse(c, "Scopes must be one or more of: profile:read, interviews:read, rankings:read")
+		case services.ErrTooManyAPITokens:
+			utils.ConflictResponse(c, "Revoke an existing API token before creating another")
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to create API token")
+		}
+		return
+	}
+
+	utils.CreatedResponse(c, gin.H{
+		"token":    secret,
+		"apiToken": token.ToResponse(),
+	})
+}
+
+// ListTokens lists the authenticated user's active personal access tokens
+func (h *APITokenHandler) ListTokens(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	tokens, err := h.apiTokenService.ListTokens(c.Request.Context(), userID)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to list API tokens")
+		return
+	}
+
+	responses := make([]models.APITokenResponse, len(tokens))
+	for i, token := range tokens {
+		responses[i] = token.ToResponse()
+	}
+
+	utils.SuccessResponse(c, responses)
+}
+
+// RevokeToken revokes one of the authenticated user's personal access tokens
+func (h *APITokenHandler) RevokeToken(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	if err := h.apiTokenService.RevokeToken(c.Request.Context(), userID, c.Param("tokenId")); err != nil {
+		if err == services.ErrAPITokenNotFound {
+			utils.NotFoundResponse(c, "API token not found")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to revoke API token")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "API token revoked"})
+}
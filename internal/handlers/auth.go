@@ -1,68 +1,249 @@
 package handlers
 
 import (
+	"crypto/subtle"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/PRM710/Rankedterview-backend/internal/config"
+	"github.com/PRM710/Rankedterview-backend/internal/middleware"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
 	"github.com/PRM710/Rankedterview-backend/internal/services"
 	"github.com/PRM710/Rankedterview-backend/internal/utils"
 )
 
+// oauthStateCookie holds the state of the OAuth flow a browser started, so
+// a state value issued to one browser can't complete a login in another
+const oauthStateCookie = "oauth_state"
+
 type AuthHandler struct {
 	authService *services.AuthService
+
+	// Cookies are only sent over HTTPS in production
+	secureCookies bool
 }
 
-func NewAuthHandler(authService *services.AuthService) *AuthHandler {
+func NewAuthHandler(authService *services.AuthService, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
+		authService:   authService,
+		secureCookies: cfg.Environment == "production",
 	}
 }
 
-// Register handles user registration (placeholder for future email/password auth)
+// Register creates an email/password account and sends a verification email
 func (h *AuthHandler) Register(c *gin.Context) {
-	utils.ErrorResponse(c, http.StatusNotImplemented, "Email/password registration not implemented. Please use OAuth.")
+	var input struct {
+		Email    string `json:"email" binding:"required,email"`
+		Password string `json:"password" binding:"required"`
+		Name     string `json:"name" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	user, err := h.authService.Register(c.Request.Context(), input.Email, input.Password, input.Name)
+	if err != nil {
+		switch err {
+		case services.ErrUserExists:
+			utils.ConflictResponse(c, "An account with this email already exists")
+		case services.ErrWeakPassword, utils.ErrInvalidEmail:
+			utils.BadRequestResponse(c, err.Error())
+		default:
+			utils.InternalServerErrorResponse(c, "Registration failed")
+		}
+		return
+	}
+
+	utils.CreatedResponse(c, gin.H{
+		"user":    user.ToSelfResponse(),
+		"message": "Check your email to verify your account",
+	})
 }
 
-// Login handles user login (placeholder for future email/password auth)
+// Login authenticates with email and password
 func (h *AuthHandler) Login(c *gin.Context) {
-	utils.ErrorResponse(c, http.StatusNotImplemented, "Email/password login not implemented. Please use OAuth.")
+	var input struct {
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	user, tokens, err := h.authService.Login(c.Request.Context(), input.Email, input.Password, clientInfo(c))
+	if banErrorResponse(c, err) {
+		return
+	}
+	if err != nil {
+		switch err {
+		case services.ErrInvalidCredentials:
+			utils.UnauthorizedResponse(c, "Invalid email or password")
+		case services.ErrEmailNotVerified:
+			utils.ErrorResponse(c, http.StatusForbidden, "Please verify your email before logging in")
+		default:
+			utils.InternalServerErrorResponse(c, "Login failed")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"token":        tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+		"expiresIn":    tokens.ExpiresIn,
+		"user":         user.ToSelfResponse(),
+	})
+}
+
+// VerifyEmail confirms an email address with the token from the verification email
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	if err := h.authService.VerifyEmail(c.Request.Context(), input.Token); err != nil {
+		if err == services.ErrInvalidEmailLink {
+			utils.BadRequestResponse(c, err.Error())
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to verify email")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Email verified"})
+}
+
+// ResendVerification sends another verification email
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	if err := h.authService.ResendVerification(c.Request.Context(), input.Email); err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to send verification email")
+		return
+	}
+
+	// Same response whether or not the account exists
+	utils.SuccessResponse(c, gin.H{"message": "If the account needs verification, an email is on its way"})
+}
+
+// ForgotPassword emails a password reset link
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	if err := h.authService.RequestPasswordReset(c.Request.Context(), input.Email); err != nil {
+		utils.InternalServerErrorResponse(c, "Failed to send password reset email")
+		return
+	}
+
+	// Same response whether or not the account exists
+	utils.SuccessResponse(c, gin.H{"message": "If an account exists for this email, a reset link is on its way"})
+}
+
+// ResetPassword sets a new password with the token from the reset email
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	if err := h.authService.ResetPassword(c.Request.Context(), input.Token, input.Password); err != nil {
+		switch err {
+		case services.ErrInvalidEmailLink, services.ErrWeakPassword:
+			utils.BadRequestResponse(c, err.Error())
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to reset password")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Password updated. Please log in again."})
 }
 
 // GoogleOAuth initiates Google OAuth flow
 func (h *AuthHandler) GoogleOAuth(c *gin.Context) {
-	url, err := h.authService.GetOAuthURL("google")
+	url, state, err := h.authService.GetOAuthURL(c.Request.Context(), "google")
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
+	h.setOAuthState(c, state)
 
 	c.JSON(http.StatusOK, gin.H{
 		"authUrl": url,
+		"state":   state,
 	})
 }
 
 // GitHubOAuth initiates GitHub OAuth flow
 func (h *AuthHandler) GitHubOAuth(c *gin.Context) {
-	url, err := h.authService.GetOAuthURL("github")
+	url, state, err := h.authService.GetOAuthURL(c.Request.Context(), "github")
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
+	h.setOAuthState(c, state)
 
 	c.JSON(http.StatusOK, gin.H{
 		"authUrl": url,
+		"state":   state,
 	})
 }
 
-// OAuthCallback handles OAuth callback
+// setOAuthState remembers the state of the OAuth flow this browser started
+// in an HttpOnly cookie
+func (h *AuthHandler) setOAuthState(c *gin.Context, state string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, state, int(services.OAuthStateTTL.Seconds()), "/api/v1", "", h.secureCookies, true)
+}
+
+// checkOAuthState reports whether state belongs to the OAuth flow this
+// browser started. The cookie is cleared either way: a state is used once.
+func (h *AuthHandler) checkOAuthState(c *gin.Context, state string) bool {
+	cookie, err := c.Cookie(oauthStateCookie)
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, "", -1, "/api/v1", "", h.secureCookies, true)
+
+	return err == nil && subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) == 1
+}
+
+// OAuthCallback completes the OAuth flow with the authorization code and state
+// the provider redirected back with
 func (h *AuthHandler) OAuthCallback(c *gin.Context) {
 	var input struct {
 		Provider string `json:"provider" binding:"required"`
-		OAuthID  string `json:"oauthId" binding:"required"`
-		Email    string `json:"email" binding:"required,email"`
-		Name     string `json:"name" binding:"required"`
-		Avatar   string `json:"avatar"`
+		Code     string `json:"code" binding:"required"`
+		State    string `json:"state" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -70,32 +251,48 @@ func (h *AuthHandler) OAuthCallback(c *gin.Context) {
 		return
 	}
 
-	// Register or login user
-	user, token, err := h.authService.RegisterWithOAuth(
+	if !h.checkOAuthState(c, input.State) {
+		utils.UnauthorizedResponse(c, services.ErrInvalidOAuthState.Error())
+		return
+	}
+
+	// Exchange the code with the provider and register or login user
+	user, tokens, err := h.authService.AuthenticateWithOAuth(
 		c.Request.Context(),
 		input.Provider,
-		input.OAuthID,
-		input.Email,
-		input.Name,
-		input.Avatar,
+		input.Code,
+		input.State,
+		clientInfo(c),
 	)
 
+	if banErrorResponse(c, err) {
+		return
+	}
 	if err != nil {
-		utils.InternalServerErrorResponse(c, "Authentication failed: "+err.Error())
+		switch err {
+		case services.ErrUnsupportedOAuth:
+			utils.BadRequestResponse(c, err.Error())
+		case services.ErrInvalidOAuthState, services.ErrOAuthEmailMissing:
+			utils.UnauthorizedResponse(c, err.Error())
+		default:
+			utils.UnauthorizedResponse(c, "Authentication failed: "+err.Error())
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"token":   token,
-		"user":    user.ToResponse(),
+		"success":      true,
+		"token":        tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+		"expiresIn":    tokens.ExpiresIn,
+		"user":         user.ToSelfResponse(),
 	})
 }
 
-// RefreshToken handles token refresh
+// RefreshToken exchanges a refresh token for a new access and refresh token pair
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	var input struct {
-		Token string `json:"token" binding:"required"`
+		RefreshToken string `json:"refreshToken" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -103,22 +300,181 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// Validate old token
-	claims, err := h.authService.ValidateToken(input.Token)
+	tokens, err := h.authService.Refresh(c.Request.Context(), input.RefreshToken)
+	if banErrorResponse(c, err) {
+		return
+	}
 	if err != nil {
-		utils.UnauthorizedResponse(c, "Invalid token")
+		switch err {
+		case services.ErrInvalidRefresh, services.ErrRefreshReused, services.ErrUserNotFound:
+			utils.UnauthorizedResponse(c, err.Error())
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to refresh token")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"token":        tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+		"expiresIn":    tokens.ExpiresIn,
+	})
+}
+
+// Logout revokes the current session
+func (h *AuthHandler) Logout(c *gin.Context) {
+	userID, _ := middleware.GetUserID(c)
+	sessionID, exists := middleware.GetSessionID(c)
+	if !exists {
+		utils.BadRequestResponse(c, "Token is not bound to a session")
 		return
 	}
 
-	// Generate new token
-	newToken, err := h.authService.RefreshToken(c.Request.Context(), claims.UserID)
+	if err := h.authService.Logout(c.Request.Context(), userID, sessionID); err != nil {
+		if err == services.ErrSessionNotFound {
+			utils.NotFoundResponse(c, err.Error())
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to log out")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Logged out"})
+}
+
+// ListSessions lists the authenticated user's active sessions
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(c.Request.Context(), userID)
 	if err != nil {
-		utils.InternalServerErrorResponse(c, "Failed to refresh token")
+		utils.InternalServerErrorResponse(c, "Failed to list sessions")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"token":   newToken,
+	currentSessionID, _ := middleware.GetSessionID(c)
+	responses := make([]models.SessionResponse, len(sessions))
+	for i, session := range sessions {
+		responses[i] = session.ToResponse(currentSessionID)
+	}
+
+	utils.SuccessResponse(c, responses)
+}
+
+// RevokeSession revokes one of the authenticated user's sessions
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	err := h.authService.RevokeSession(c.Request.Context(), userID, c.Param("sessionId"), "revoked")
+	if err != nil {
+		if err == services.ErrSessionNotFound {
+			utils.NotFoundResponse(c, err.Error())
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to revoke session")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Session revoked"})
+}
+
+// LinkIdentity links another OAuth provider to the authenticated user. The
+// code and state come from a normal /auth/oauth/:provider redirect.
+func (h *AuthHandler) LinkIdentity(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	var input struct {
+		Provider string `json:"provider" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+		State    string `json:"state" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.BadRequestResponse(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	if !h.checkOAuthState(c, input.State) {
+		utils.BadRequestResponse(c, services.ErrInvalidOAuthState.Error())
+		return
+	}
+
+	user, err := h.authService.LinkIdentity(c.Request.Context(), userID, input.Provider, input.Code, input.State)
+	if err != nil {
+		switch err {
+		case services.ErrUnsupportedOAuth, services.ErrInvalidOAuthState:
+			utils.BadRequestResponse(c, err.Error())
+		case services.ErrIdentityInUse, services.ErrProviderLinked:
+			utils.ConflictResponse(c, err.Error())
+		case services.ErrUserNotFound:
+			utils.NotFoundResponse(c, err.Error())
+		default:
+			utils.UnauthorizedResponse(c, "Failed to link provider: "+err.Error())
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, user.ToSelfResponse())
+}
+
+// UnlinkIdentity removes an OAuth provider from the authenticated user
+func (h *AuthHandler) UnlinkIdentity(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	user, err := h.authService.UnlinkIdentity(c.Request.Context(), userID, c.Param("provider"))
+	if err != nil {
+		switch err {
+		case services.ErrIdentityNotLinked, services.ErrUserNotFound:
+			utils.NotFoundResponse(c, err.Error())
+		case services.ErrLastLoginMethod:
+			utils.ConflictResponse(c, err.Error())
+		default:
+			utils.InternalServerErrorResponse(c, "Failed to unlink provider")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, user.ToSelfResponse())
+}
+
+// clientInfo describes the device making the request
+func clientInfo(c *gin.Context) services.ClientInfo {
+	return services.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
+// banErrorResponse responds with 403 and the ban details if err is a ban,
+// reporting whether it did
+func banErrorResponse(c *gin.Context, err error) bool {
+	var banErr *services.BanError
+	if !errors.As(err, &banErr) {
+		return false
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"success": false,
+		"error":   "Your account is banned",
+		"code":    "ACCOUNT_BANNED",
+		"ban":     banErr.Ban.ToResponse(),
 	})
+	return true
 }
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
@@ -12,11 +14,19 @@ import (
 
 type InterviewHandler struct {
 	interviewService *services.InterviewService
+	recordingService *services.RecordingService
+	retentionService *services.RetentionService
 }
 
-func NewInterviewHandler(interviewService *services.InterviewService) *InterviewHandler {
+func NewInterviewHandler(
+	interviewService *services.InterviewService,
+	recordingService *services.RecordingService,
+	retentionService *services.RetentionService,
+) *InterviewHandler {
 	return &InterviewHandler{
 		interviewService: interviewService,
+		recordingService: recordingService,
+		retentionService: retentionService,
 	}
 }
 
@@ -75,24 +85,79 @@ func (h *InterviewHandler) GetTranscript(c *gin.Context) {
 	utils.SuccessResponse(c, transcript)
 }
 
-// GetRecordingURLs retrieves the recording URLs
+// GetRecordingURLs returns short-lived presigned URLs to the stored recording
+// files. Only the interview's participants may download them.
 func (h *InterviewHandler) GetRecordingURLs(c *gin.Context) {
 	interviewID := c.Param("id")
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
 
-	recording, err := h.interviewService.GetRecording(c.Request.Context(), interviewID)
+	urls, err := h.recordingService.RecordingURLs(c.Request.Context(), interviewID, userID)
 	if err != nil {
-		utils.NotFoundResponse(c, "Interview not found")
+		if err == services.ErrInterviewNotFound {
+			utils.NotFoundResponse(c, "Interview not found")
+			return
+		}
+		if err == services.ErrNotParticipant {
+			utils.ErrorResponse(c, http.StatusForbidden, "Only participants can access this recording")
+			return
+		}
+		if err == services.ErrRecordingNotStored {
+			utils.NotFoundResponse(c, "Recording is not available yet")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to sign recording URLs")
 		return
 	}
 
 	utils.SuccessResponse(c, gin.H{
-		"videoUrl":      recording.VideoURL,
-		"audioUrl":      recording.AudioURL,
-		"transcriptUrl": recording.TranscriptURL,
-		"status":        recording.Status,
+		"videoUrl":      urls.VideoURL,
+		"audioUrl":      urls.AudioURL,
+		"transcriptUrl": urls.TranscriptURL,
+		"status":        urls.Status,
+		"expiresAt":     urls.ExpiresAt,
 	})
 }
 
+// DeleteRecording deletes the interview's recording files ahead of their
+// retention period. ?files=video,audio,transcript picks which; all by default.
+func (h *InterviewHandler) DeleteRecording(c *gin.Context) {
+	interviewID := c.Param("id")
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		utils.UnauthorizedResponse(c, "User not authenticated")
+		return
+	}
+
+	var files []string
+	if param := c.Query("files"); param != "" {
+		files = strings.Split(param, ",")
+	}
+
+	err := h.retentionService.DeleteRecording(c.Request.Context(), interviewID, userID, files)
+	if err != nil {
+		if err == services.ErrInvalidRecordingFile {
+			utils.BadRequestResponse(c, "files must be video, audio or transcript")
+			return
+		}
+		if err == services.ErrInterviewNotFound {
+			utils.NotFoundResponse(c, "Interview not found")
+			return
+		}
+		if err == services.ErrNotParticipant {
+			utils.ErrorResponse(c, http.StatusForbidden, "Only participants can delete this recording")
+			return
+		}
+		utils.InternalServerErrorResponse(c, "Failed to delete recording")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "Recording deleted"})
+}
+
 // GetFeedback retrieves the AI-generated feedback
 func (h *InterviewHandler) GetFeedback(c *gin.Context) {
 	interviewID := c.Param("id")
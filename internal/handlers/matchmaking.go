@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/PRM710/Rankedterview-backend/internal/middleware"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
 	"github.com/PRM710/Rankedterview-backend/internal/services"
 	"github.com/PRM710/Rankedterview-backend/internal/utils"
 	"github.com/PRM710/Rankedterview-backend/internal/websocket"
@@ -33,7 +34,8 @@ func (h *MatchmakingHandler) JoinQueue(c *gin.Context) {
 	}
 
 	var input struct {
-		SkillLevel int `json:"skillLevel"`
+		SkillLevel int    `json:"skillLevel"`
+		Mode       string `json:"mode"` // "ranked" (default) or "practice"
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -41,20 +43,32 @@ func (h *MatchmakingHandler) JoinQueue(c *gin.Context) {
 		input.SkillLevel = 1000
 	}
 
-	err := h.matchmakingService.JoinQueue(c.Request.Context(), userID, input.SkillLevel)
+	switch input.Mode {
+	case "":
+		input.Mode = models.RoomModeRanked
+	case models.RoomModeRanked, models.RoomModePractice:
+	default:
+		utils.BadRequestResponse(c, "Invalid mode. Use \"ranked\" or \"practice\"")
+		return
+	}
+
+	err := h.matchmakingService.JoinQueue(c.Request.Context(), userID, input.SkillLevel, input.Mode)
 	if err != nil {
 		if err == services.ErrAlreadyInQueue {
 			utils.ConflictResponse(c, "Already in queue")
 			return
 		}
+		if banErrorResponse(c, err) {
+			return
+		}
 		utils.InternalServerErrorResponse(c, "Failed to join queue: "+err.Error())
 		return
 	}
 
 	// Notify via WebSocket
-	h.hub.BroadcastToUser(userID, map[string]interface{}{
-		"type":    "queue_joined",
-		"message": "Successfully joined matchmaking queue",
+	h.hub.BroadcastToUser(userID, websocket.QueueEvent{
+		Type:    websocket.EventQueueJoined,
+		Message: "Successfully joined matchmaking queue",
 	})
 
 	// Try to find a match immediately
@@ -80,9 +94,9 @@ func (h *MatchmakingHandler) LeaveQueue(c *gin.Context) {
 		return
 	}
 
-	h.hub.BroadcastToUser(userID, map[string]interface{}{
-		"type":    "queue_left",
-		"message": "Left matchmaking queue",
+	h.hub.BroadcastToUser(userID, websocket.QueueEvent{
+		Type:    websocket.EventQueueLeft,
+		Message: "Left matchmaking queue",
 	})
 
 	utils.SuccessResponse(c, gin.H{"message": "Left queue"})
@@ -112,9 +126,9 @@ func (h *MatchmakingHandler) GetQueueStatus(c *gin.Context) {
 	roomID, opponentID, matchErr := h.matchmakingService.FindMatch(c.Request.Context(), userID)
 	if matchErr == nil {
 		// Match found! Return match info instead of queue status
-		matchData := map[string]interface{}{
-			"type":   "match_found",
-			"roomId": roomID,
+		matchData := websocket.MatchFoundEvent{
+			Type:   websocket.EventMatchFound,
+			RoomID: roomID,
 		}
 
 		// Notify opponent via WebSocket
@@ -146,9 +160,9 @@ func (h *MatchmakingHandler) tryMatch(userID string) {
 	}
 
 	// Notify both users of the match
-	matchData := map[string]interface{}{
-		"type":   "match_found",
-		"roomId": roomID,
+	matchData := websocket.MatchFoundEvent{
+		Type:   websocket.EventMatchFound,
+		RoomID: roomID,
 	}
 
 	h.hub.BroadcastToUser(userID, matchData)
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/PRM710/Rankedterview-backend/internal/models"
 	"github.com/PRM710/Rankedterview-backend/internal/services"
 	"github.com/PRM710/Rankedterview-backend/internal/utils"
 )
@@ -43,16 +44,7 @@ func (h *RankingHandler) GetCategoryLeaderboard(c *gin.Context) {
 	category := c.Param("category")
 	limit, _ := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
 
-	// Validate category
-	validCategories := map[string]bool{
-		"overall":       true,
-		"communication": true,
-		"technical":     true,
-		"confidence":    true,
-		"structure":     true,
-	}
-
-	if !validCategories[category] {
+	if !models.IsValidRankingCategory(category) {
 		utils.BadRequestResponse(c, "Invalid category")
 		return
 	}
@@ -35,7 +35,7 @@ func (h *UserHandler) GetCurrentUser(c *gin.Context) {
 		return
 	}
 
-	utils.SuccessResponse(c, user.ToResponse())
+	utils.SuccessResponse(c, user.ToSelfResponse())
 }
 
 // GetUser retrieves a user by ID
@@ -71,7 +71,7 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	utils.SuccessResponse(c, user.ToResponse())
+	utils.SuccessResponse(c, user.ToSelfResponse())
 }
 
 // GetUserStats retrieves user statistics
@@ -2,59 +2,117 @@ package handlers
 
 import (
 	"context"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/PRM710/Rankedterview-backend/internal/config"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
 	"github.com/PRM710/Rankedterview-backend/internal/services"
 	"github.com/PRM710/Rankedterview-backend/internal/utils"
+	"github.com/PRM710/Rankedterview-backend/internal/websocket"
 )
 
+// How long copying an interview's recording files into the bucket may take
+const recordingStoreTimeout = 30 * time.Minute
+
 type WebhookHandler struct {
 	interviewService  *services.InterviewService
+	transcriptService *services.TranscriptService
+	recordingService  *services.RecordingService
 	evaluationService *services.EvaluationService
 	rankingService    *services.RankingService
+	usageService      *services.UsageService
+	coachingService   *services.CoachingService
+	hub               *websocket.Hub
 	config            *config.Config
 }
 
 func NewWebhookHandler(
 	interviewService *services.InterviewService,
+	transcriptService *services.TranscriptService,
+	recordingService *services.RecordingService,
 	rankingService *services.RankingService,
+	usageService *services.UsageService,
+	coachingService *services.CoachingService,
+	hub *websocket.Hub,
 	cfg *config.Config,
 ) *WebhookHandler {
 	return &WebhookHandler{
 		interviewService:  interviewService,
+		transcriptService: transcriptService,
+		recordingService:  recordingService,
 		evaluationService: services.NewEvaluationService(cfg),
 		rankingService:    rankingService,
+		usageService:      usageService,
+		coachingService:   coachingService,
+		hub:               hub,
 		config:            cfg,
 	}
 }
 
-// RecallWebhook handles webhooks from Recall.ai
+// RecallWebhook handles webhooks from Recall.ai. Signatures are checked by
+// the VerifyRecallWebhook middleware.
 func (h *WebhookHandler) RecallWebhook(c *gin.Context) {
-	// Verify webhook secret
-	secret := c.GetHeader("X-Recall-Secret")
-	if secret != h.config.RecallWebhookSecret {
-		utils.UnauthorizedResponse(c, "Invalid webhook secret")
-		return
+	var payload struct {
+		Event         string `json:"event"`
+		InterviewID   string `json:"interview_id"`
+		VideoURL      string `json:"video_url"`
+		AudioURL      string `json:"audio_url"`
+		TranscriptURL string `json:"transcript_url"`
+		Data          struct {
+			Bot struct {
+				ID       string            `json:"id"`
+				Metadata map[string]string `json:"metadata"`
+			} `json:"bot"`
+			Data struct {
+				SubCode string `json:"sub_code"`
+				Message string `json:"message"`
+			} `json:"data"`
+		} `json:"data"`
 	}
 
-	var payload map[string]interface{}
 	if err := c.ShouldBindJSON(&payload); err != nil {
 		utils.BadRequestResponse(c, "Invalid payload")
 		return
 	}
 
 	// Extract interview ID from payload
-	interviewID, ok := payload["interview_id"].(string)
-	if !ok {
+	interviewID := payload.InterviewID
+	if interviewID == "" {
+		interviewID = payload.Data.Bot.Metadata["interview_id"]
+	}
+	if interviewID == "" {
 		utils.BadRequestResponse(c, "Missing interview_id")
 		return
 	}
 
+	// Deliveries without an event type predate event dispatch and mean the
+	// recording is done
+	eventType := payload.Event
+	if eventType == "" {
+		eventType = services.RecallEventDone
+	}
+
+	failure := payload.Data.Data.Message
+	if failure == "" {
+		failure = payload.Data.Data.SubCode
+	}
+
+	event := services.RecallEvent{
+		Type:          eventType,
+		BotID:         payload.Data.Bot.ID,
+		VideoURL:      payload.VideoURL,
+		AudioURL:      payload.AudioURL,
+		TranscriptURL: payload.TranscriptURL,
+		Error:         failure,
+	}
+
 	// Process the webhook asynchronously
-	go h.processRecallWebhook(interviewID, payload)
+	go h.processRecallWebhook(interviewID, event)
 
 	// Return success immediately
 	c.JSON(http.StatusOK, gin.H{
@@ -63,44 +121,213 @@ func (h *WebhookHandler) RecallWebhook(c *gin.Context) {
 	})
 }
 
-// processRecallWebhook processes the webhook data
-func (h *WebhookHandler) processRecallWebhook(interviewID string, payload map[string]interface{}) {
+// RecallRealtimeWebhook receives real-time transcript events from a Recall bot
+// during a call and feeds them to live coaching
+func (h *WebhookHandler) RecallRealtimeWebhook(c *gin.Context) {
+	var payload struct {
+		Event  string `json:"event"`
+		RoomID string `json:"room_id"`
+		Data   struct {
+			Bot struct {
+				Metadata map[string]string `json:"metadata"`
+			} `json:"bot"`
+			Data struct {
+				Words []struct {
+					Text           string `json:"text"`
+					StartTimestamp struct {
+						Relative float64 `json:"relative"`
+					} `json:"start_timestamp"`
+					EndTimestamp struct {
+						Relative float64 `json:"relative"`
+					} `json:"end_timestamp"`
+				} `json:"words"`
+				Participant struct {
+					Name string `json:"name"`
+				} `json:"participant"`
+			} `json:"data"`
+		} `json:"data"`
+	}
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.BadRequestResponse(c, "Invalid payload")
+		return
+	}
+
+	roomID := payload.RoomID
+	if roomID == "" {
+		roomID = payload.Data.Bot.Metadata["room_id"]
+	}
+
+	// Only finalized transcript segments are used; partials are acknowledged and ignored
+	words := payload.Data.Data.Words
+	if payload.Event == "transcript.data" && roomID != "" && len(words) > 0 {
+		texts := make([]string, len(words))
+		for i, word := range words {
+			texts[i] = word.Text
+		}
+
+		segment := models.TranscriptSegment{
+			Speaker:   payload.Data.Data.Participant.Name,
+			Text:      strings.Join(texts, " "),
+			StartTime: words[0].StartTimestamp.Relative,
+			EndTime:   words[len(words)-1].EndTimestamp.Relative,
+		}
+
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			h.coachingService.IngestSegment(ctx, roomID, segment)
+		}()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Webhook received",
+	})
+}
+
+// processRecallWebhook applies a Recall event to the interview and, once the
+// recording or transcript is ready, stores the files in our bucket and
+// evaluates it
+func (h *WebhookHandler) processRecallWebhook(interviewID string, event services.RecallEvent) {
 	ctx := context.Background()
 
 	// Step 1: Update recording information
-	err := h.interviewService.ProcessWebhook(ctx, interviewID, payload)
+	changed, err := h.interviewService.ApplyRecallEvent(ctx, interviewID, event)
 	if err != nil {
-		// Log error but don't fail
+		log.Printf("Recall event %s for interview %s not applied: %v", event.Type, interviewID, err)
 		return
 	}
+	if !changed {
+		log.Printf("Recall event %s for interview %s is stale or a duplicate", event.Type, interviewID)
+	}
 
-	// Step 2: Get transcript
-	transcript, err := h.interviewService.GetTranscript(ctx, interviewID)
-	if err != nil || transcript.Raw == "" {
+	if event.Type != services.RecallEventDone && event.Type != services.RecallEventTranscriptReady {
 		return
 	}
 
-	// Step 3: Evaluate interview with AI
-	evaluation, err := h.evaluationService.EvaluateInterview(ctx, transcript.Raw)
+	// Recall's URLs expire, so keep our own copy. Copying large files takes a
+	// while, so it runs alongside evaluation instead of ahead of it.
+	go h.storeRecording(interviewID)
+
+	h.evaluateInterview(ctx, interviewID)
+}
+
+// storeRecording copies the interview's recording files into our bucket
+func (h *WebhookHandler) storeRecording(interviewID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordingStoreTimeout)
+	defer cancel()
+
+	if err := h.recordingService.StoreRecording(ctx, interviewID); err != nil {
+		log.Printf("Recording of interview %s not fully stored: %v", interviewID, err)
+	}
+}
+
+// evaluateInterview scores an interview and updates its participants'
+// rankings. The evaluation claim makes sure this happens once per interview
+// however many deliveries trigger it.
+func (h *WebhookHandler) evaluateInterview(ctx context.Context, interviewID string) {
+	// Step 2: Get interview with its transcript and participants
+	interview, err := h.interviewService.GetInterview(ctx, interviewID)
+	if err != nil {
+		return
+	}
+
+	// Interviews without everyone's consent are never evaluated or ranked
+	if !interview.IsEvaluable() {
+		return
+	}
+
+	transcript := &interview.Transcript
+	needsIngest := transcript.Raw == "" && len(transcript.Segments) == 0
+	if needsIngest && interview.Recording.TranscriptURL == "" {
+		return
+	}
+
+	// Step 3: Claim the evaluation before ingesting, so concurrent deliveries
+	// don't download and save the transcript more than once
+	claimed, err := h.interviewService.ClaimEvaluation(ctx, interviewID)
+	if err != nil || !claimed {
+		return
+	}
+
+	if needsIngest {
+		transcript, err = h.transcriptService.Ingest(ctx, interview)
+		if err != nil {
+			log.Printf("Transcript of interview %s not ingested: %v", interviewID, err)
+			h.interviewService.FailEvaluation(ctx, interviewID)
+			return
+		}
+	}
+
+	userIDs := make([]string, len(interview.Participants))
+	for i, participant := range interview.Participants {
+		userIDs[i] = participant.UserID.Hex()
+	}
+
+	// Step 4: Pick a model within the participants' AI budgets
+	model, err := h.usageService.SelectModel(ctx, userIDs)
 	if err != nil {
-		// Log error
+		log.Printf("Skipping evaluation of interview %s: %v", interviewID, err)
+		h.interviewService.FailEvaluation(ctx, interviewID)
 		return
 	}
 
-	// Step 4: Save evaluation
-	err = h.interviewService.UpdateEvaluation(ctx, interviewID, *evaluation)
+	// Step 5: Evaluate interview with AI (long transcripts are chunked) and
+	// account for the tokens spent, even if evaluation failed part way
+	evaluation, usage, err := h.evaluationService.EvaluateTranscript(ctx, transcript, model)
+	cost, usageErr := h.usageService.Record(ctx, userIDs, usage)
+	if usageErr != nil {
+		log.Printf("Failed to record AI usage for interview %s: %v", interviewID, usageErr)
+	}
 	if err != nil {
+		log.Printf("Evaluation of interview %s failed: %v", interviewID, err)
+		h.interviewService.FailEvaluation(ctx, interviewID)
 		return
 	}
+	evaluation.CostUSD = cost
 
-	// Step 5: Get interview to find participants
-	interview, err := h.interviewService.GetInterview(ctx, interviewID)
+	// Step 6: Save evaluation; rankings only change if our claim still held
+	saved, err := h.interviewService.CompleteEvaluation(ctx, interviewID, *evaluation)
 	if err != nil {
+		h.interviewService.FailEvaluation(ctx, interviewID)
+		return
+	}
+	if !saved {
 		return
 	}
 
-	// Step 6: Update rankings for participants
+	// Step 7: Update rankings for participants (not for practice interviews)
+	// and push the results to each of them
 	for _, participant := range interview.Participants {
-		h.rankingService.UpdateUserRanking(ctx, participant.UserID.Hex(), evaluation.Scores)
+		userID := participant.UserID.Hex()
+
+		// Without a ranking update (practice, or on failure) still tell the
+		// user their feedback is ready
+		var update *services.RankingUpdate
+		if interview.IsRanked() {
+			update, _ = h.rankingService.UpdateUserRanking(ctx, userID, evaluation.Scores)
+		}
+		h.notifyEvaluationComplete(userID, interviewID, evaluation, update)
+	}
+}
+
+// notifyEvaluationComplete pushes the score summary and rating change to a participant
+func (h *WebhookHandler) notifyEvaluationComplete(userID, interviewID string, evaluation *models.Evaluation, update *services.RankingUpdate) {
+	event := websocket.EvaluationCompleteEvent{
+		Type:        websocket.EventEvaluationComplete,
+		InterviewID: interviewID,
+		Scores:      evaluation.Scores,
+		Summary:     evaluation.Feedback.Summary,
+	}
+
+	if update != nil {
+		eloChange := update.EloChange()
+		event.EloChange = &eloChange
+		event.NewElo = &update.NewElo
+		event.PreviousRank = &update.PreviousRank
+		event.NewRank = &update.NewRank
 	}
+
+	h.hub.BroadcastToUser(userID, event)
 }
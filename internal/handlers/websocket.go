@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 
+	"github.com/PRM710/Rankedterview-backend/internal/services"
+	"github.com/PRM710/Rankedterview-backend/internal/utils"
 	ws "github.com/PRM710/Rankedterview-backend/internal/websocket"
 )
 
@@ -17,40 +21,77 @@ var upgrader = websocket.Upgrader{
 		// In production, validate the origin properly
 		return true
 	},
+	// Echoed back when the token is sent as "bearer, <token>"
+	Subprotocols: []string{ws.BearerSubprotocol},
 }
 
 type WebSocketHandler struct {
-	hub *ws.Hub
+	hub               *ws.Hub
+	authService       *services.AuthService
+	moderationService *services.ModerationService
 }
 
-func NewWebSocketHandler(hub *ws.Hub) *WebSocketHandler {
+func NewWebSocketHandler(hub *ws.Hub, authService *services.AuthService, moderationService *services.ModerationService) *WebSocketHandler {
 	return &WebSocketHandler{
-		hub: hub,
+		hub:               hub,
+		authService:       authService,
+		moderationService: moderationService,
 	}
 }
 
-// HandleWebSocket handles WebSocket upgrade and connection
+// HandleWebSocket handles WebSocket upgrade and connection. The access token
+// may be sent as the "token" query parameter, through the bearer subprotocol,
+// or in an auth frame as the first message after the upgrade. Clients offer
+// the protocol versions they speak with "?protocol=", and resume after a
+// reconnect with "?lastSeq=" set to the last reliable event they processed.
 func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
-	// Extract user ID from query parameter
-	// In production, validate the user via JWT token
-	userID := c.Query("userId")
-	if userID == "" {
-		// Try to get from Authorization header
-		token := c.Query("token")
-		if token != "" {
-			// Validate token and extract userID
-			// claims, err := utils.ValidateToken(token, cfg.JWTSecret)
-			// if err == nil {
-			//     userID = claims.UserID
-			// }
+	protocolVersion, err := ws.NegotiateProtocol(c.Query("protocol"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":           false,
+			"error":             "Unsupported protocol version",
+			"code":              ws.ErrorUnsupportedProtocol,
+			"supportedVersions": ws.SupportedProtocolVersions,
+		})
+		return
+	}
+
+	var lastSeq int64 = -1
+	if value := c.Query("lastSeq"); value != "" {
+		lastSeq, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || lastSeq < 0 {
+			utils.BadRequestResponse(c, "lastSeq must be a sequence number")
+			return
 		}
 	}
 
-	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "User ID or token required",
-		})
-		return
+	token := ws.TokenFromRequest(c.Request)
+
+	// Reject bad tokens before upgrading when we have one
+	var claims *utils.JWTClaims
+	if token != "" {
+		claims, err = h.hub.Authenticate(token)
+		if err != nil {
+			utils.UnauthorizedResponse(c, "Invalid or expired token")
+			return
+		}
+
+		revoked, err := h.authService.IsSessionRevoked(c.Request.Context(), claims.SessionID)
+		if err != nil {
+			utils.InternalServerErrorResponse(c, "Failed to check session")
+			return
+		}
+		if revoked {
+			utils.UnauthorizedResponse(c, "Session has been revoked")
+			return
+		}
+
+		if err := h.moderationService.CheckNotBanned(c.Request.Context(), claims.UserID); err != nil {
+			if !banErrorResponse(c, err) {
+				utils.InternalServerErrorResponse(c, "Failed to check account status")
+			}
+			return
+		}
 	}
 
 	// Upgrade connection to WebSocket
@@ -60,8 +101,39 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
-	// Create new client
-	client := ws.NewClient(h.hub, conn, userID)
+	if claims == nil {
+		claims, err = h.hub.ReadAuthFrame(conn)
+		if err != nil {
+			ws.RejectConnection(conn, "authentication failed")
+			return
+		}
+
+		revoked, err := h.authService.IsSessionRevoked(c.Request.Context(), claims.SessionID)
+		if err != nil {
+			ws.CloseConnection(conn, websocket.CloseInternalServerErr, "failed to check session")
+			return
+		}
+		if revoked {
+			ws.RejectConnection(conn, "session revoked")
+			return
+		}
+
+		if err := h.moderationService.CheckNotBanned(c.Request.Context(), claims.UserID); err != nil {
+			if errors.Is(err, services.ErrAccountBanned) {
+				ws.CloseConnection(conn, ws.CloseAccountBanned, "account banned")
+			} else {
+				ws.CloseConnection(conn, websocket.CloseInternalServerErr, "failed to check account status")
+			}
+			return
+		}
+	}
+
+	// Create new client for the authenticated user
+	client := ws.NewClient(h.hub, conn, claims.UserID, protocolVersion)
+	if lastSeq >= 0 {
+		client.ResumeFrom(lastSeq)
+	}
+	client.SetTokenExpiry(claims.ExpiresAt.Time)
 
 	// Register client with hub
 	h.hub.Register(client)
@@ -1,15 +1,45 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/services"
 )
 
-// AuthMiddleware validates JWT tokens
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// APITokenAuthenticator validates personal access tokens (implemented by the
+// API token service). AuthenticateAPIToken returns services.ErrInvalidAPIToken
+// for unknown or inactive tokens and a *services.BanError for banned users.
+type APITokenAuthenticator interface {
+	IsAPIToken(token string) bool
+	AuthenticateAPIToken(ctx context.Context, token, ip string) (*models.APIToken, error)
+	AllowAPITokenRequest(ctx context.Context, tokenID string) (limit, remaining int, allowed bool, err error)
+}
+
+// SessionChecker reports whether the session an access token was issued for
+// has been revoked (implemented by the auth service)
+type SessionChecker interface {
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+// BanChecker looks up a user's active ban, nil if there is none (implemented
+// by the moderation service, which caches it)
+type BanChecker interface {
+	ActiveBan(ctx context.Context, userID string) (*models.Ban, error)
+}
+
+// AuthMiddleware validates JWT tokens and personal access tokens. Access
+// tokens of revoked sessions or banned users are rejected. Personal access
+// tokens only reach routes listed in routeScopes, which maps
+// "METHOD /full/route/path" to the scope the token needs.
+func AuthMiddleware(jwtSecret string, sessions SessionChecker, bans BanChecker, apiTokens APITokenAuthenticator, routeScopes map[string]string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get token from Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -33,6 +63,11 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 
 		tokenString := parts[1]
 
+		if apiTokens.IsAPIToken(tokenString) {
+			authenticateAPIToken(c, apiTokens, routeScopes, tokenString)
+			return
+		}
+
 		// Parse and validate token
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 			// Validate signing method
@@ -78,13 +113,159 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
+		sessionID, _ := claims["sid"].(string)
+		revoked, err := sessions.IsSessionRevoked(c.Request.Context(), sessionID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to check session",
+			})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Session has been revoked",
+			})
+			c.Abort()
+			return
+		}
+
+		ban, err := bans.ActiveBan(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to check ban",
+			})
+			c.Abort()
+			return
+		}
+		if ban != nil {
+			abortBanned(c, ban)
+			return
+		}
+
 		c.Set("userId", userID)
 		c.Set("userEmail", claims["email"])
+		if sessionID != "" {
+			c.Set("sessionId", sessionID)
+		}
+
+		role, _ := claims["role"].(string)
+		if role == "" {
+			role = models.RoleUser
+		}
+		c.Set("userRole", role)
 
 		c.Next()
 	}
 }
 
+// authenticateAPIToken authenticates a personal access token and checks it
+// against the route's scope and the token's rate limit
+func authenticateAPIToken(c *gin.Context, apiTokens APITokenAuthenticator, routeScopes map[string]string, tokenString string) {
+	ctx := c.Request.Context()
+
+	token, err := apiTokens.AuthenticateAPIToken(ctx, tokenString, c.ClientIP())
+	if err != nil {
+		var banErr *services.BanError
+		switch {
+		case errors.As(err, &banErr):
+			abortBanned(c, banErr.Ban)
+		case errors.Is(err, services.ErrInvalidAPIToken):
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid, expired or revoked API token",
+			})
+			c.Abort()
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to authenticate API token",
+			})
+			c.Abort()
+		}
+		return
+	}
+
+	scope, ok := routeScopes[c.Request.Method+" "+c.FullPath()]
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "This endpoint can't be used with an API token",
+		})
+		c.Abort()
+		return
+	}
+	if !token.HasScope(scope) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "API token is missing the " + scope + " scope",
+		})
+		c.Abort()
+		return
+	}
+
+	limit, remaining, allowed, err := apiTokens.AllowAPITokenRequest(ctx, token.ID.Hex())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check rate limit",
+		})
+		c.Abort()
+		return
+	}
+
+	c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+
+	if !allowed {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": "API token rate limit exceeded. Please try again later.",
+		})
+		c.Abort()
+		return
+	}
+
+	c.Set("userId", token.UserID.Hex())
+	c.Set("userEmail", "")
+	c.Set("userRole", models.RoleUser)
+	c.Set("apiTokenId", token.ID.Hex())
+
+	c.Next()
+}
+
+// abortBanned rejects a banned user's request with their ban
+func abortBanned(c *gin.Context, ban *models.Ban) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"success": false,
+		"error":   "Your account is banned",
+		"code":    "ACCOUNT_BANNED",
+		"ban":     ban.ToResponse(),
+	})
+	c.Abort()
+}
+
+// RequireRole only lets through users whose role is at least the given role
+// (user < moderator < admin). Must run after AuthMiddleware. Roles come from
+// the access token, so a role change applies on the user's next refresh.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRole, ok := GetUserRole(c)
+		if !ok || !models.RoleAtLeast(userRole, role) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Insufficient permissions",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// GetUserRole extracts the user role from context
+func GetUserRole(c *gin.Context) (string, bool) {
+	role, exists := c.Get("userRole")
+	if !exists {
+		return "", false
+	}
+	return role.(string), true
+}
+
 // GetUserID extracts the user ID from context
 func GetUserID(c *gin.Context) (string, bool) {
 	userID, exists := c.Get("userId")
@@ -94,6 +275,25 @@ func GetUserID(c *gin.Context) (string, bool) {
 	return userID.(string), true
 }
 
+// GetAPITokenID extracts the ID of the personal access token the request
+// was made with; it is absent for JWT-authenticated requests
+func GetAPITokenID(c *gin.Context) (string, bool) {
+	tokenID, exists := c.Get("apiTokenId")
+	if !exists {
+		return "", false
+	}
+	return tokenID.(string), true
+}
+
+// GetSessionID extracts the session ID of the access token from context
+func GetSessionID(c *gin.Context) (string, bool) {
+	sessionID, exists := c.Get("sessionId")
+	if !exists {
+		return "", false
+	}
+	return sessionID.(string), true
+}
+
 // GetUserEmail extracts the user email from context
 func GetUserEmail(c *gin.Context) (string, bool) {
 	email, exists := c.Get("userEmail")
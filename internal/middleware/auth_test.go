@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/services"
+	"github.com/PRM710/Rankedterview-backend/internal/utils"
+)
+
+const testJWTSecret = "test-secret"
+
+type stubSessions struct {
+	revoked map[string]bool
+}
+
+func (s stubSessions) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	return s.revoked[sessionID], nil
+}
+
+type stubBans struct {
+	bans map[string]*models.Ban
+}
+
+func (s stubBans) ActiveBan(ctx context.Context, userID string) (*models.Ban, error) {
+	return s.bans[userID], nil
+}
+
+type stubAPITokens struct {
+	token *models.APIToken
+	err   error
+}
+
+func (s stubAPITokens) IsAPIToken(token string) bool {
+	return len(token) > 4 && token[:4] == "rtk_"
+}
+
+func (s stubAPITokens) AuthenticateAPIToken(ctx context.Context, token, ip string) (*models.APIToken, error) {
+	return s.token, s.err
+}
+
+func (s stubAPITokens) AllowAPITokenRequest(ctx context.Context, tokenID string) (int, int, bool, error) {
+	return 60, 59, true, nil
+}
+
+// newTestRouter serves GET /api/v1/users/me behind AuthMiddleware
+func newTestRouter(sessions SessionChecker, bans BanChecker, apiTokens APITokenAuthenticator) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(AuthMiddleware(testJWTSecret, sessions, bans, apiTokens, map[string]string{
+		"GET /api/v1/users/me": models.ScopeProfileRead,
+	}))
+	router.GET("/api/v1/users/me", func(c *gin.Context) {
+		userID, _ := GetUserID(c)
+		c.JSON(http.StatusOK, gin.H{"userId": userID})
+	})
+	router.GET("/api/v1/rooms/:roomId", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func get(t *testing.T, router *gin.Engine, path, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func accessToken(t *testing.T, userID, sessionID string) string {
+	t.Helper()
+	token, err := utils.GenerateToken(userID, userID+"@example.com", models.RoleUser, sessionID, testJWTSecret, time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return token
+}
+
+func TestAuthMiddlewareRejectsBannedUser(t *testing.T) {
+	ban := &models.Ban{Reason: "spam"}
+	router := newTestRouter(stubSessions{}, stubBans{bans: map[string]*models.Ban{"banned": ban}}, stubAPITokens{})
+
+	rec := get(t, router, "/api/v1/users/me", accessToken(t, "banned", "s1"))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("banned user got %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code != "ACCOUNT_BANNED" {
+		t.Errorf("banned user got body %s, want code ACCOUNT_BANNED", rec.Body)
+	}
+
+	if rec := get(t, router, "/api/v1/users/me", accessToken(t, "alice", "s2")); rec.Code != http.StatusOK {
+		t.Errorf("user without a ban got %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestAuthMiddlewareRejectsRevokedSession(t *testing.T) {
+	router := newTestRouter(stubSessions{revoked: map[string]bool{"old": true}}, stubBans{}, stubAPITokens{})
+
+	if rec := get(t, router, "/api/v1/users/me", accessToken(t, "alice", "old")); rec.Code != http.StatusUnauthorized {
+		t.Errorf("revoked session got %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := get(t, router, "/api/v1/users/me", accessToken(t, "alice", "current")); rec.Code != http.StatusOK {
+		t.Errorf("active session got %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestAuthMiddlewareChecksAPITokenScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		path   string
+		want   int
+	}{
+		{"scope allows the route", []string{models.ScopeProfileRead}, "/api/v1/users/me", http.StatusOK},
+		{"scope doesn't allow the route", []string{models.ScopeRankingsRead}, "/api/v1/users/me", http.StatusForbidden},
+		{"route not open to API tokens", []string{models.ScopeProfileRead}, "/api/v1/rooms/r1", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		token := &models.APIToken{UserID: primitive.NewObjectID(), Scopes: tt.scopes}
+		router := newTestRouter(stubSessions{}, stubBans{}, stubAPITokens{token: token})
+
+		if rec := get(t, router, tt.path, "rtk_secret"); rec.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestAuthMiddlewareAPITokenErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+		code string
+	}{
+		{"invalid token", services.ErrInvalidAPIToken, http.StatusUnauthorized, ""},
+		{"banned user", &services.BanError{Ban: &models.Ban{Reason: "spam"}}, http.StatusForbidden, "ACCOUNT_BANNED"},
+		{"lookup failure", errors.New("connection refused"), http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		router := newTestRouter(stubSessions{}, stubBans{}, stubAPITokens{err: tt.err})
+
+		rec := get(t, router, "/api/v1/users/me", "rtk_secret")
+		if rec.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, rec.Code, tt.want)
+		}
+		var body struct {
+			Code string `json:"code"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code != tt.code {
+			t.Errorf("%s: got body %s, want code %q", tt.name, rec.Body, tt.code)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/PRM710/Rankedterview-backend/internal/database"
+	"github.com/PRM710/Rankedterview-backend/internal/utils"
+)
+
+// Largest webhook body that will be read for verification
+const maxWebhookBodySize = 5 << 20 // 5MB
+
+// VerifyRecallWebhook verifies the HMAC signature of a Recall.ai delivery over
+// its raw body and rejects deliveries that are outside the timestamp tolerance.
+// A delivery whose webhook-id was already handled is acknowledged without
+// being processed again; the ID is forgotten if the handler fails, so a retry
+// of that delivery goes through. The body is restored for the handler.
+func VerifyRecallWebhook(secret string, tolerance time.Duration, redis *database.RedisClient) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodySize))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Failed to read webhook body",
+			})
+			c.Abort()
+			return
+		}
+
+		deliveryID := c.GetHeader("webhook-id")
+		err = utils.VerifyWebhookSignature(
+			secret,
+			deliveryID,
+			c.GetHeader("webhook-timestamp"),
+			c.GetHeader("webhook-signature"),
+			body,
+			tolerance,
+			time.Now(),
+		)
+		if err != nil {
+			log.Printf("Rejected Recall webhook %q: %v", deliveryID, err)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid webhook signature",
+			})
+			c.Abort()
+			return
+		}
+
+		// Signed deliveries older than the tolerance are already rejected, so
+		// IDs only need to be remembered for that long (both directions)
+		deliveryKey := "webhook:recall:" + deliveryID
+		first, err := redis.Client.SetNX(c.Request.Context(), deliveryKey, 1, 2*tolerance).Result()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to check webhook delivery",
+			})
+			c.Abort()
+			return
+		}
+		if !first {
+			// Acknowledge so the sender stops retrying
+			c.JSON(http.StatusOK, gin.H{
+				"success": true,
+				"message": "Webhook delivery already processed",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+
+		if status := c.Writer.Status(); status < 200 || status >= 300 {
+			if err := redis.Del(context.Background(), deliveryKey); err != nil {
+				log.Printf("Failed to forget failed Recall webhook %q: %v", deliveryID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audit actions
+const (
+	AuditRecordingPurged = "recording.purged"
+)
+
+// Reasons recording files are purged
+const (
+	PurgeReasonRetention   = "retention"
+	PurgeReasonUserRequest = "user_request"
+)
+
+// AuditEntry records something done to users' data. Entries made by
+// scheduled jobs have no actor.
+type AuditEntry struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Action      string             `bson:"action" json:"action"`
+	ActorID     primitive.ObjectID `bson:"actorId,omitempty" json:"actorId,omitempty"`
+	InterviewID primitive.ObjectID `bson:"interviewId,omitempty" json:"interviewId,omitempty"`
+	Reason      string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	Details     map[string]string  `bson:"details,omitempty" json:"details,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Ban blocks a user from signing in, matchmaking and connecting. A ban
+// without an expiry is permanent; a lifted ban no longer applies.
+type Ban struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Reason    string             `bson:"reason" json:"reason"`
+	IssuedBy  primitive.ObjectID `bson:"issuedBy" json:"issuedBy"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt *time.Time         `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	LiftedAt  *time.Time         `bson:"liftedAt,omitempty" json:"liftedAt,omitempty"`
+	LiftedBy  primitive.ObjectID `bson:"liftedBy,omitempty" json:"liftedBy,omitempty"`
+}
+
+// IsPermanent reports whether the ban never expires
+func (b *Ban) IsPermanent() bool {
+	return b.ExpiresAt == nil
+}
+
+// IsActive reports whether the ban currently applies
+func (b *Ban) IsActive() bool {
+	if b.LiftedAt != nil {
+		return false
+	}
+	return b.ExpiresAt == nil || time.Now().Before(*b.ExpiresAt)
+}
+
+// BanResponse is what a banned user is told about their ban
+type BanResponse struct {
+	Reason    string     `json:"reason"`
+	Permanent bool       `json:"permanent"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// ToResponse converts Ban to BanResponse
+func (b *Ban) ToResponse() BanResponse {
+	return BanResponse{
+		Reason:    b.Reason,
+		Permanent: b.IsPermanent(),
+		ExpiresAt: b.ExpiresAt,
+	}
+}
@@ -10,8 +10,9 @@ import (
 type Interview struct {
 	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	RoomID         string             `bson:"roomId" json:"roomId"`
+	Mode           string             `bson:"mode,omitempty" json:"mode,omitempty"` // the room's mode: "ranked", "practice" (empty means ranked)
 	Participants   []Participant      `bson:"participants" json:"participants"`
-	Status         string             `bson:"status" json:"status"` // "pending", "in_progress", "completed", "failed"
+	Status         string             `bson:"status" json:"status"` // "pending", "in_progress", "completed", "failed", "forfeited", "abandoned"
 	StartedAt      time.Time          `bson:"startedAt" json:"startedAt"`
 	EndedAt        time.Time          `bson:"endedAt" json:"endedAt"`
 	Duration       int                `bson:"duration" json:"duration"` // seconds
@@ -19,24 +20,152 @@ type Interview struct {
 	Transcript     Transcript         `bson:"transcript" json:"transcript"`
 	Evaluation     Evaluation         `bson:"evaluation" json:"evaluation"`
 	RankingImpact  RankingImpact      `bson:"rankingImpact" json:"rankingImpact"`
+
+	// Guards evaluation so duplicate webhook deliveries evaluate and rate only once
+	EvaluationStatus    string    `bson:"evaluationStatus,omitempty" json:"evaluationStatus,omitempty"` // "running", "completed", "failed"
+	EvaluationStartedAt time.Time `bson:"evaluationStartedAt,omitempty" json:"-"`
+
+	// Rooms are only recorded and ranked once every participant has consented.
+	// Interviews from before consent capture have no status.
+	ConsentStatus string `bson:"consentStatus,omitempty" json:"consentStatus,omitempty"` // "pending", "granted", "declined"
+
+	// Participant who dropped out of the call and didn't reconnect in time
+	AbandonedBy string `bson:"abandonedBy,omitempty" json:"abandonedBy,omitempty"`
+}
+
+// Statuses of interviews a participant left before the call ended. Leaving
+// a ranked interview forfeits it; any other interview is just abandoned.
+const (
+	InterviewStatusForfeited = "forfeited"
+	InterviewStatusAbandoned = "abandoned"
+)
+
+// Recording consent statuses
+const (
+	ConsentStatusPending  = "pending"
+	ConsentStatusGranted  = "granted"
+	ConsentStatusDeclined = "declined"
+)
+
+// IsEvaluable reports whether the interview may be evaluated. Interviews
+// without everyone's consent and interviews a participant left never are.
+func (i *Interview) IsEvaluable() bool {
+	return i.ConsentStatus != ConsentStatusPending && i.ConsentStatus != ConsentStatusDeclined &&
+		i.AbandonedBy == ""
 }
 
+// IsRanked reports whether the interview's evaluation counts towards
+// rankings. Practice interviews are evaluated but never ranked.
+func (i *Interview) IsRanked() bool {
+	return i.Mode != RoomModePractice && i.IsEvaluable()
+}
+
+// Evaluation statuses
+const (
+	EvaluationStatusRunning   = "running"
+	EvaluationStatusCompleted = "completed"
+	EvaluationStatusFailed    = "failed"
+)
+
 // Participant represents a participant in an interview
 type Participant struct {
 	UserID   primitive.ObjectID `bson:"userId" json:"userId"`
 	Role     string             `bson:"role" json:"role"` // "interviewer", "interviewee"
 	JoinedAt time.Time          `bson:"joinedAt" json:"joinedAt"`
 	LeftAt   time.Time          `bson:"leftAt" json:"leftAt"`
+	Consent  *RecordingConsent  `bson:"consent,omitempty" json:"consent,omitempty"`
 }
 
-// Recording holds recording information
+// RecordingConsent is a participant's answer to the recording consent
+// request, and the version of the recording policy they were shown
+type RecordingConsent struct {
+	Granted       bool      `bson:"granted" json:"granted"`
+	PolicyVersion string    `bson:"policyVersion" json:"policyVersion"`
+	AnsweredAt    time.Time `bson:"answeredAt" json:"answeredAt"`
+}
+
+// Recording holds recording information. Recall's download URLs expire, so
+// clients only get presigned URLs to our copies of the files.
 type Recording struct {
-	RecallBotID   string    `bson:"recallBotId" json:"recallBotId"`
-	Status        string    `bson:"status" json:"status"` // "recording", "processing", "completed", "failed"
-	VideoURL      string    `bson:"videoUrl" json:"videoUrl"`
-	AudioURL      string    `bson:"audioUrl" json:"audioUrl"`
-	TranscriptURL string    `bson:"transcriptUrl" json:"transcriptUrl"`
-	Metadata      string    `bson:"metadata" json:"metadata"`
+	RecallBotID     string    `bson:"recallBotId" json:"recallBotId"`
+	Status          string    `bson:"status" json:"status"` // "joined", "recording", "processing", "completed", "failed"
+	VideoURL        string    `bson:"videoUrl" json:"-"`
+	AudioURL        string    `bson:"audioUrl" json:"-"`
+	TranscriptURL   string    `bson:"transcriptUrl" json:"-"`
+	TranscriptReady bool      `bson:"transcriptReady" json:"transcriptReady"`
+	FailureReason   string    `bson:"failureReason,omitempty" json:"failureReason,omitempty"`
+	UpdatedAt       time.Time `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+	Metadata        string    `bson:"metadata" json:"metadata"`
+
+	// Copies of the files in our bucket, served through presigned URLs.
+	// Retention periods run from when the first file was stored.
+	VideoKey      string    `bson:"videoKey,omitempty" json:"-"`
+	AudioKey      string    `bson:"audioKey,omitempty" json:"-"`
+	TranscriptKey string    `bson:"transcriptKey,omitempty" json:"-"`
+	StoredAt      time.Time `bson:"storedAt,omitempty" json:"-"`
+}
+
+// Recording files, as named in storage keys and deletion requests
+const (
+	RecordingFileVideo      = "video"
+	RecordingFileAudio      = "audio"
+	RecordingFileTranscript = "transcript"
+)
+
+// IsValidRecordingFile reports whether file names a recording file
+func IsValidRecordingFile(file string) bool {
+	switch file {
+	case RecordingFileVideo, RecordingFileAudio, RecordingFileTranscript:
+		return true
+	}
+	return false
+}
+
+// FileKey returns the storage key of one of the recording files
+func (r *Recording) FileKey(file string) string {
+	switch file {
+	case RecordingFileVideo:
+		return r.VideoKey
+	case RecordingFileAudio:
+		return r.AudioKey
+	case RecordingFileTranscript:
+		return r.TranscriptKey
+	}
+	return ""
+}
+
+// Recording statuses, in the order a recording moves through them
+const (
+	RecordingStatusJoined     = "joined"
+	RecordingStatusRecording  = "recording"
+	RecordingStatusProcessing = "processing"
+	RecordingStatusCompleted  = "completed"
+	RecordingStatusFailed     = "failed"
+)
+
+// recordingStatusOrder ranks the non-terminal statuses; "" means no event yet
+var recordingStatusOrder = []string{
+	"",
+	RecordingStatusJoined,
+	RecordingStatusRecording,
+	RecordingStatusProcessing,
+	RecordingStatusCompleted,
+}
+
+// RecordingStatusesBefore lists the statuses a recording may move to status
+// from. Statuses only move forward; completed and failed are final.
+func RecordingStatusesBefore(status string) []string {
+	if status == RecordingStatusFailed {
+		// Any recording that hasn't finished can fail
+		return recordingStatusOrder[:len(recordingStatusOrder)-1]
+	}
+
+	for i, s := range recordingStatusOrder {
+		if s == status {
+			return recordingStatusOrder[:i]
+		}
+	}
+	return nil
 }
 
 // Transcript holds the interview transcript
@@ -48,6 +177,7 @@ type Transcript struct {
 // TranscriptSegment represents a segment of the transcript
 type TranscriptSegment struct {
 	Speaker    string  `bson:"speaker" json:"speaker"`
+	UserID     string  `bson:"userId,omitempty" json:"userId,omitempty"` // participant the speaker was mapped to
 	Text       string  `bson:"text" json:"text"`
 	StartTime  float64 `bson:"startTime" json:"startTime"`
 	EndTime    float64 `bson:"endTime" json:"endTime"`
@@ -61,6 +191,9 @@ type Evaluation struct {
 	Feedback    Feedback   `bson:"feedback" json:"feedback"`
 	AIModel     string     `bson:"aiModel" json:"aiModel"`
 	TokensUsed  int        `bson:"tokensUsed" json:"tokensUsed"`
+	PromptTokens     int     `bson:"promptTokens" json:"promptTokens"`
+	CompletionTokens int     `bson:"completionTokens" json:"completionTokens"`
+	CostUSD          float64 `bson:"costUsd" json:"costUsd"`
 }
 
 // Scores holds evaluation scores
@@ -80,11 +213,17 @@ type Feedback struct {
 	Highlights   []Highlight `bson:"highlights" json:"highlights"`
 }
 
-// Highlight represents a timestamped highlight
+// Highlight represents a timestamped highlight. When the transcript has
+// segments, every highlight points at the segment(s) it refers to and its
+// timestamps are taken from them.
 type Highlight struct {
-	Timestamp float64 `bson:"timestamp" json:"timestamp"`
-	Type      string  `bson:"type" json:"type"` // "good", "improve"
-	Comment   string  `bson:"comment" json:"comment"`
+	Timestamp      float64 `bson:"timestamp" json:"timestamp"`
+	EndTimestamp   float64 `bson:"endTimestamp,omitempty" json:"endTimestamp,omitempty"`
+	Type           string  `bson:"type" json:"type"` // "good", "improve"
+	Comment        string  `bson:"comment" json:"comment"`
+	SegmentIndexes []int   `bson:"segmentIndexes,omitempty" json:"segmentIndexes,omitempty"` // indexes into Transcript.Segments
+	Speaker        string  `bson:"speaker,omitempty" json:"speaker,omitempty"`
+	Quote          string  `bson:"quote,omitempty" json:"quote,omitempty"`
 }
 
 // RankingImpact holds ranking changes
@@ -97,6 +236,7 @@ type RankingImpact struct {
 type InterviewResponse struct {
 	ID            string        `json:"id"`
 	RoomID        string        `json:"roomId"`
+	Mode          string        `json:"mode,omitempty"`
 	Participants  []Participant `json:"participants"`
 	Status        string        `json:"status"`
 	StartedAt     time.Time     `json:"startedAt"`
@@ -106,6 +246,8 @@ type InterviewResponse struct {
 	Transcript    Transcript    `json:"transcript"`
 	Evaluation    Evaluation    `json:"evaluation"`
 	RankingImpact RankingImpact `json:"rankingImpact"`
+	ConsentStatus string        `json:"consentStatus,omitempty"`
+	AbandonedBy   string        `json:"abandonedBy,omitempty"`
 }
 
 // ToResponse converts Interview to InterviewResponse
@@ -113,6 +255,7 @@ func (i *Interview) ToResponse() InterviewResponse {
 	return InterviewResponse{
 		ID:            i.ID.Hex(),
 		RoomID:        i.RoomID,
+		Mode:          i.Mode,
 		Participants:  i.Participants,
 		Status:        i.Status,
 		StartedAt:     i.StartedAt,
@@ -122,5 +265,7 @@ func (i *Interview) ToResponse() InterviewResponse {
 		Transcript:    i.Transcript,
 		Evaluation:    i.Evaluation,
 		RankingImpact: i.RankingImpact,
+		ConsentStatus: i.ConsentStatus,
+		AbandonedBy:   i.AbandonedBy,
 	}
 }
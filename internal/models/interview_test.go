@@ -0,0 +1,30 @@
+package models
+
+import "testing"
+
+func TestInterviewIsRanked(t *testing.T) {
+	tests := []struct {
+		name      string
+		interview Interview
+		evaluable bool
+		want      bool
+	}{
+		{"ranked with consent", Interview{Mode: RoomModeRanked, ConsentStatus: ConsentStatusGranted}, true, true},
+		{"no mode with consent", Interview{ConsentStatus: ConsentStatusGranted}, true, true},
+		{"from before consent capture", Interview{Mode: RoomModeRanked}, true, true},
+		{"practice with consent", Interview{Mode: RoomModePractice, ConsentStatus: ConsentStatusGranted}, true, false},
+		{"practice from before consent capture", Interview{Mode: RoomModePractice}, true, false},
+		{"consent pending", Interview{Mode: RoomModeRanked, ConsentStatus: ConsentStatusPending}, false, false},
+		{"consent declined", Interview{Mode: RoomModeRanked, ConsentStatus: ConsentStatusDeclined}, false, false},
+		{"abandoned", Interview{Mode: RoomModeRanked, ConsentStatus: ConsentStatusGranted, AbandonedBy: "alice"}, false, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.interview.IsEvaluable(); got != tt.evaluable {
+			t.Errorf("%s: IsEvaluable() = %v, want %v", tt.name, got, tt.evaluable)
+		}
+		if got := tt.interview.IsRanked(); got != tt.want {
+			t.Errorf("%s: IsRanked() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -21,10 +21,12 @@ type Ranking struct {
 
 // RankingHistory tracks ranking changes over time
 type RankingHistory struct {
-	Date  time.Time `bson:"date" json:"date"`
-	Rank  int       `bson:"rank" json:"rank"`
-	Score float64   `bson:"score" json:"score"`
-	Elo   int       `bson:"elo" json:"elo"`
+	Date       time.Time `bson:"date" json:"date"`
+	Rank       int       `bson:"rank" json:"rank"`
+	Score      float64   `bson:"score" json:"score"`
+	Elo        int       `bson:"elo" json:"elo"`
+	Reason     string    `bson:"reason,omitempty" json:"reason,omitempty"`         // set on manual corrections
+	AdjustedBy string    `bson:"adjustedBy,omitempty" json:"adjustedBy,omitempty"` // admin user ID
 }
 
 // LeaderboardEntry represents a leaderboard entry
@@ -64,3 +66,16 @@ func (r *Ranking) ToResponse() RankingResponse {
 		History:  r.History,
 	}
 }
+
+// RankingCategories are the categories users are ranked in
+var RankingCategories = []string{"overall", "communication", "technical", "confidence", "structure"}
+
+// IsValidRankingCategory reports whether category is a known ranking category
+func IsValidRankingCategory(category string) bool {
+	for _, known := range RankingCategories {
+		if category == known {
+			return true
+		}
+	}
+	return false
+}
@@ -24,6 +24,18 @@ type RoomMetadata struct {
 	Topic      string `bson:"topic" json:"topic"`
 	Difficulty string `bson:"difficulty" json:"difficulty"` // "easy", "medium", "hard"
 	Type       string `bson:"type" json:"type"`             // "technical", "behavioral"
+	Mode       string `bson:"mode" json:"mode"`             // "ranked", "practice" (empty means ranked)
+}
+
+// Room modes
+const (
+	RoomModeRanked   = "ranked"
+	RoomModePractice = "practice"
+)
+
+// IsRanked reports whether the room's interview counts towards rankings
+func (m RoomMetadata) IsRanked() bool {
+	return m.Mode != RoomModePractice
 }
 
 // RoomResponse is the response format
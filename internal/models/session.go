@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a login on one device. Its refresh token is rotated on every use;
+// earlier hashes are kept so a replayed token can be detected and the whole
+// session revoked.
+type Session struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         primitive.ObjectID `bson:"userId" json:"userId"`
+	TokenHash      string             `bson:"tokenHash" json:"-"`
+	PreviousHashes []string           `bson:"previousHashes" json:"-"`
+	UserAgent      string             `bson:"userAgent" json:"userAgent"`
+	IP             string             `bson:"ip" json:"ip"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+	LastUsedAt     time.Time          `bson:"lastUsedAt" json:"lastUsedAt"`
+	ExpiresAt      time.Time          `bson:"expiresAt" json:"expiresAt"`
+	RevokedAt      *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+	RevokedReason  string             `bson:"revokedReason,omitempty" json:"revokedReason,omitempty"` // "logout", "revoked", "reuse"
+}
+
+// IsActive reports whether the session can still be refreshed
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// SessionResponse is the response format for a user's session
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
+
+// ToResponse converts Session to SessionResponse
+func (s *Session) ToResponse(currentSessionID string) SessionResponse {
+	return SessionResponse{
+		ID:         s.ID.Hex(),
+		UserAgent:  s.UserAgent,
+		IP:         s.IP,
+		CreatedAt:  s.CreatedAt,
+		LastUsedAt: s.LastUsedAt,
+		ExpiresAt:  s.ExpiresAt,
+		Current:    s.ID.Hex() == currentSessionID,
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TokenUsage is the OpenAI token usage of one or more calls to a model
+type TokenUsage struct {
+	Model            string `bson:"model" json:"model"`
+	PromptTokens     int    `bson:"promptTokens" json:"promptTokens"`
+	CompletionTokens int    `bson:"completionTokens" json:"completionTokens"`
+	TotalTokens      int    `bson:"totalTokens" json:"totalTokens"`
+}
+
+// AIUsage aggregates AI token usage and cost per user, day and model
+type AIUsage struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID           primitive.ObjectID `bson:"userId" json:"userId"`
+	Day              string             `bson:"day" json:"day"` // UTC, "2006-01-02"
+	Model            string             `bson:"model" json:"model"`
+	PromptTokens     int64              `bson:"promptTokens" json:"promptTokens"`
+	CompletionTokens int64              `bson:"completionTokens" json:"completionTokens"`
+	TotalTokens      int64              `bson:"totalTokens" json:"totalTokens"`
+	CostUSD          float64            `bson:"costUsd" json:"costUsd"`
+	Requests         int64              `bson:"requests" json:"requests"`
+	UpdatedAt        time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// UsageReportEntry is one row of the admin usage report. Fields that were
+// not part of the grouping are left empty.
+type UsageReportEntry struct {
+	UserID           string  `bson:"userId,omitempty" json:"userId,omitempty"`
+	Day              string  `bson:"day,omitempty" json:"day,omitempty"`
+	Model            string  `bson:"model,omitempty" json:"model,omitempty"`
+	PromptTokens     int64   `bson:"promptTokens" json:"promptTokens"`
+	CompletionTokens int64   `bson:"completionTokens" json:"completionTokens"`
+	TotalTokens      int64   `bson:"totalTokens" json:"totalTokens"`
+	CostUSD          float64 `bson:"costUsd" json:"costUsd"`
+	Requests         int64   `bson:"requests" json:"requests"`
+}
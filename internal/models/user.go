@@ -14,12 +14,93 @@ type User struct {
 	Avatar         string             `bson:"avatar" json:"avatar"`
 	OAuthProvider  string             `bson:"oauthProvider" json:"oauthProvider"` // "google", "github"
 	OAuthID        string             `bson:"oauthId" json:"oauthId"`
+	Identities     []Identity         `bson:"identities,omitempty" json:"identities"`
+	PasswordHash   string             `bson:"passwordHash,omitempty" json:"-"`
+	Role           string             `bson:"role,omitempty" json:"role"` // "user", "moderator", "admin" (empty means user)
+	EmailVerified  bool               `bson:"emailVerified" json:"emailVerified"`
 	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
 	LastLoginAt    time.Time          `bson:"lastLoginAt" json:"lastLoginAt"`
 	Stats          UserStats          `bson:"stats" json:"stats"`
 	Settings       UserSettings       `bson:"settings" json:"settings"`
 }
 
+// User roles, from least to most privileged
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// roleLevels orders roles so that a higher role includes the lower ones
+var roleLevels = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+// IsValidRole reports whether role is a known role
+func IsValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// RoleAtLeast reports whether role grants at least the privileges of required.
+// An empty role is treated as RoleUser.
+func RoleAtLeast(role, required string) bool {
+	if role == "" {
+		role = RoleUser
+	}
+	return roleLevels[role] >= roleLevels[required]
+}
+
+// Identity is an OAuth provider account linked to a user
+type Identity struct {
+	Provider   string    `bson:"provider" json:"provider"` // "google", "github"
+	ProviderID string    `bson:"providerId" json:"providerId"`
+	Email      string    `bson:"email" json:"email"`
+	LinkedAt   time.Time `bson:"linkedAt" json:"linkedAt"`
+}
+
+// LinkedIdentities returns the user's OAuth identities, including the single
+// OAuthProvider/OAuthID pair of accounts created before identities were tracked
+func (u *User) LinkedIdentities() []Identity {
+	identities := make([]Identity, 0, len(u.Identities)+1)
+	legacyLinked := u.OAuthProvider == ""
+
+	for _, identity := range u.Identities {
+		if identity.Provider == u.OAuthProvider && identity.ProviderID == u.OAuthID {
+			legacyLinked = true
+		}
+		identities = append(identities, identity)
+	}
+
+	if !legacyLinked {
+		identities = append(identities, Identity{
+			Provider:   u.OAuthProvider,
+			ProviderID: u.OAuthID,
+			Email:      u.Email,
+			LinkedAt:   u.CreatedAt,
+		})
+	}
+
+	return identities
+}
+
+// IsEmailVerified reports whether the user has proven they own their email.
+// Accounts created through OAuth before verification was tracked got their
+// email from the provider, so they count as verified.
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerified || u.OAuthProvider != ""
+}
+
+// EffectiveRole returns the user's role, defaulting to RoleUser
+func (u *User) EffectiveRole() string {
+	if u.Role == "" {
+		return RoleUser
+	}
+	return u.Role
+}
+
 // UserStats holds user statistics
 type UserStats struct {
 	TotalInterviews int     `bson:"totalInterviews" json:"totalInterviews"`
@@ -57,13 +138,18 @@ type UserResponse struct {
 	Name          string       `json:"name"`
 	Avatar        string       `json:"avatar"`
 	OAuthProvider string       `json:"oauthProvider"`
+	EmailVerified bool         `json:"emailVerified"`
+	HasPassword   bool         `json:"hasPassword"`
+	Identities    []Identity   `json:"identities,omitempty"` // only shown to the user themselves
+	Role          string       `json:"role"`
 	CreatedAt     time.Time    `json:"createdAt"`
 	LastLoginAt   time.Time    `json:"lastLoginAt"`
 	Stats         UserStats    `json:"stats"`
 	Settings      UserSettings `json:"settings"`
 }
 
-// ToResponse converts User to UserResponse
+// ToResponse converts User to UserResponse. Linked identities are left out;
+// only ToSelfResponse includes them.
 func (u *User) ToResponse() UserResponse {
 	return UserResponse{
 		ID:            u.ID.Hex(),
@@ -71,9 +157,20 @@ func (u *User) ToResponse() UserResponse {
 		Name:          u.Name,
 		Avatar:        u.Avatar,
 		OAuthProvider: u.OAuthProvider,
+		EmailVerified: u.IsEmailVerified(),
+		HasPassword:   u.PasswordHash != "",
+		Role:          u.EffectiveRole(),
 		CreatedAt:     u.CreatedAt,
 		LastLoginAt:   u.LastLoginAt,
 		Stats:         u.Stats,
 		Settings:      u.Settings,
 	}
 }
+
+// ToSelfResponse converts User to the UserResponse shown to the user
+// themselves, which includes the provider accounts linked to it
+func (u *User) ToSelfResponse() UserResponse {
+	response := u.ToResponse()
+	response.Identities = u.LinkedIdentities()
+	return response
+}
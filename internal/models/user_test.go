@@ -0,0 +1,38 @@
+package models
+
+import "testing"
+
+func TestUserIsEmailVerified(t *testing.T) {
+	tests := []struct {
+		name string
+		user User
+		want bool
+	}{
+		{"verified", User{EmailVerified: true}, true},
+		{"unverified password account", User{PasswordHash: "hash"}, false},
+		{"OAuth account from before verification was tracked", User{OAuthProvider: "github", OAuthID: "42"}, true},
+		{"claimed account", User{EmailVerified: true, Identities: []Identity{{Provider: "google", ProviderID: "7"}}}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.user.IsEmailVerified(); got != tt.want {
+			t.Errorf("%s: IsEmailVerified() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUserIdentitiesOnlyInSelfResponse(t *testing.T) {
+	user := User{
+		Email:         "alice@example.com",
+		OAuthProvider: "github",
+		OAuthID:       "42",
+		Identities:    []Identity{{Provider: "google", ProviderID: "7", Email: "alice@example.com"}},
+	}
+
+	if identities := user.ToResponse().Identities; len(identities) != 0 {
+		t.Errorf("ToResponse() identities = %v, want none", identities)
+	}
+	if identities := user.ToSelfResponse().Identities; len(identities) != 2 {
+		t.Errorf("ToSelfResponse() identities = %v, want the google and legacy github identities", identities)
+	}
+}
@@ -0,0 +1,158 @@
+package recall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Attempts per request; 429s, 5xx and network errors are retried
+const maxAttempts = 3
+
+// Delay before the first retry; it doubles for each retry after that
+var retryBackoff = time.Second
+
+// ErrNotConfigured is returned when no API key is set
+var ErrNotConfigured = errors.New("recall: API key not configured")
+
+// APIError is a non-2xx response from the Recall API
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("recall: API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether the request may succeed if retried
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Client calls the Recall.ai bot API. The base URL is configurable so it can
+// point at a region or a local stub server.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+func NewClient(baseURL, apiKey string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Configured reports whether an API key is set
+func (c *Client) Configured() bool {
+	return c.apiKey != ""
+}
+
+// CreateBotRequest describes the bot to send into a call
+type CreateBotRequest struct {
+	MeetingURL string            `json:"meeting_url"`
+	BotName    string            `json:"bot_name,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// Bot is a Recall bot
+type Bot struct {
+	ID       string            `json:"id"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// CreateBot sends a bot into a call
+func (c *Client) CreateBot(ctx context.Context, req CreateBotRequest) (*Bot, error) {
+	var bot Bot
+	if err := c.do(ctx, http.MethodPost, "/api/v1/bot/", req, &bot); err != nil {
+		return nil, err
+	}
+	return &bot, nil
+}
+
+// LeaveCall tells a bot to leave its call, which ends the recording
+func (c *Client) LeaveCall(ctx context.Context, botID string) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/bot/"+botID+"/leave_call/", nil, nil)
+}
+
+// do sends a JSON request, retrying temporary failures with backoff, and
+// decodes a successful response into out
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	if !c.Configured() {
+		return ErrNotConfigured
+	}
+
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	delay := retryBackoff
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err = c.attempt(ctx, method, path, payload, out)
+
+		var apiErr *APIError
+		if err == nil || (errors.As(err, &apiErr) && !apiErr.Temporary()) || attempt == maxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+
+	return err
+}
+
+func (c *Client) attempt(ctx context.Context, method, path string, payload []byte, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+c.apiKey)
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
@@ -0,0 +1,108 @@
+package recall
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func init() {
+	retryBackoff = time.Millisecond
+}
+
+// newTestServer answers the n-th request with statuses[n-1], repeating the
+// last status after that, and counts the requests it received
+func newTestServer(t *testing.T, statuses ...int) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if got := r.Header.Get("Authorization"); got != "Token test-key" {
+			t.Errorf("Authorization = %q, want %q", got, "Token test-key")
+		}
+
+		status := statuses[len(statuses)-1]
+		if requests <= len(statuses) {
+			status = statuses[requests-1]
+		}
+		if status != http.StatusCreated {
+			http.Error(w, `{"detail":"nope"}`, status)
+			return
+		}
+
+		var req CreateBotRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(Bot{ID: "bot-1", Metadata: req.Metadata})
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestCreateBotRetriesTemporaryFailures(t *testing.T) {
+	server, requests := newTestServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusCreated)
+	client := NewClient(server.URL+"/", "test-key")
+
+	bot, err := client.CreateBot(context.Background(), CreateBotRequest{
+		MeetingURL: "https://meet.example.com/room",
+		Metadata:   map[string]string{"room_id": "r1"},
+	})
+	if err != nil {
+		t.Fatalf("CreateBot: %v", err)
+	}
+	if bot.ID != "bot-1" || bot.Metadata["room_id"] != "r1" {
+		t.Errorf("CreateBot returned %+v", bot)
+	}
+	if *requests != 3 {
+		t.Errorf("made %d requests, want 3", *requests)
+	}
+}
+
+func TestCreateBotGivesUpAfterMaxAttempts(t *testing.T) {
+	server, requests := newTestServer(t, http.StatusBadGateway)
+	client := NewClient(server.URL, "test-key")
+
+	_, err := client.CreateBot(context.Background(), CreateBotRequest{MeetingURL: "https://meet.example.com/room"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("CreateBot error = %v, want a 502 APIError", err)
+	}
+	if *requests != maxAttempts {
+		t.Errorf("made %d requests, want %d", *requests, maxAttempts)
+	}
+}
+
+func TestCreateBotDoesNotRetryClientErrors(t *testing.T) {
+	server, requests := newTestServer(t, http.StatusBadRequest)
+	client := NewClient(server.URL, "test-key")
+
+	_, err := client.CreateBot(context.Background(), CreateBotRequest{MeetingURL: "not a url"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("CreateBot error = %v, want a 400 APIError", err)
+	}
+	if apiErr.Temporary() {
+		t.Error("a 400 is reported as temporary")
+	}
+	if *requests != 1 {
+		t.Errorf("made %d requests, want 1", *requests)
+	}
+}
+
+func TestLeaveCallWithoutAPIKey(t *testing.T) {
+	server, requests := newTestServer(t, http.StatusOK)
+	client := NewClient(server.URL, "")
+
+	if err := client.LeaveCall(context.Background(), "bot-1"); err != ErrNotConfigured {
+		t.Errorf("LeaveCall error = %v, want %v", err, ErrNotConfigured)
+	}
+	if *requests != 0 {
+		t.Errorf("made %d requests, want none", *requests)
+	}
+}
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/PRM710/Rankedterview-backend/internal/database"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
+type APITokenRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAPITokenRepository(db *database.MongoDB) *APITokenRepository {
+	return &APITokenRepository{
+		collection: db.Collection("api_tokens"),
+	}
+}
+
+// Create creates a new API token
+func (r *APITokenRepository) Create(ctx context.Context, token *models.APIToken) error {
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+// FindByHash finds a token by the hash of its secret
+func (r *APITokenRepository) FindByHash(ctx context.Context, hash string) (*models.APIToken, error) {
+	var token models.APIToken
+	err := r.collection.FindOne(ctx, bson.M{"tokenHash": hash}).Decode(&token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// activeTokenFilter matches a user's tokens that are neither revoked nor expired
+func activeTokenFilter(userID primitive.ObjectID) bson.M {
+	return bson.M{
+		"userId":    userID,
+		"revokedAt": bson.M{"$exists": false},
+		"$or": []bson.M{
+			{"expiresAt": bson.M{"$exists": false}},
+			{"expiresAt": bson.M{"$gt": time.Now()}},
+		},
+	}
+}
+
+// ListActiveByUser lists a user's usable tokens, newest first
+func (r *APITokenRepository) ListActiveByUser(ctx context.Context, userID primitive.ObjectID) ([]*models.APIToken, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, activeTokenFilter(userID), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tokens := []*models.APIToken{}
+	if err = cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// CountActiveByUser counts a user's usable tokens
+func (r *APITokenRepository) CountActiveByUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	return r.collection.CountDocuments(ctx, activeTokenFilter(userID))
+}
+
+// Revoke revokes one of a user's tokens, reporting whether it was found
+func (r *APITokenRepository) Revoke(ctx context.Context, id, userID primitive.ObjectID) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "userId": userID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount > 0, nil
+}
+
+// TouchLastUsed records when and from where a token was last used
+func (r *APITokenRepository) TouchLastUsed(ctx context.Context, id primitive.ObjectID, ip string) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"lastUsedAt": time.Now(), "lastUsedIp": ip}},
+	)
+	return err
+}
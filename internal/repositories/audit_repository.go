@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/PRM710/Rankedterview-backend/internal/database"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
+// AuditRepository stores the audit trail. Entries are only ever appended.
+type AuditRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAuditRepository(db *database.MongoDB) *AuditRepository {
+	return &AuditRepository{
+		collection: db.Collection("audit_log"),
+	}
+}
+
+// Create appends an entry to the audit trail
+func (r *AuditRepository) Create(ctx context.Context, entry *models.AuditEntry) error {
+	entry.ID = primitive.NewObjectID()
+	entry.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, entry)
+	return err
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/PRM710/Rankedterview-backend/internal/database"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
+type BanRepository struct {
+	collection *mongo.Collection
+}
+
+func NewBanRepository(db *database.MongoDB) *BanRepository {
+	return &BanRepository{
+		collection: db.Collection("bans"),
+	}
+}
+
+// Create creates a new ban
+func (r *BanRepository) Create(ctx context.Context, ban *models.Ban) error {
+	ban.ID = primitive.NewObjectID()
+	ban.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, ban)
+	return err
+}
+
+// FindActiveByUser finds the user's current ban, preferring one that lasts the longest
+func (r *BanRepository) FindActiveByUser(ctx context.Context, userID primitive.ObjectID) (*models.Ban, error) {
+	filter := bson.M{
+		"userId":   userID,
+		"liftedAt": bson.M{"$exists": false},
+		"$or": []bson.M{
+			{"expiresAt": bson.M{"$exists": false}},
+			{"expiresAt": bson.M{"$gt": time.Now()}},
+		},
+	}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var bans []*models.Ban
+	if err = cursor.All(ctx, &bans); err != nil {
+		return nil, err
+	}
+
+	var longest *models.Ban
+	for _, ban := range bans {
+		if longest == nil || ban.IsPermanent() ||
+			(!longest.IsPermanent() && ban.ExpiresAt.After(*longest.ExpiresAt)) {
+			longest = ban
+		}
+		if longest.IsPermanent() {
+			break
+		}
+	}
+
+	if longest == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	return longest, nil
+}
+
+// LiftActive lifts every active ban of a user and returns how many were lifted
+func (r *BanRepository) LiftActive(ctx context.Context, userID, liftedBy primitive.ObjectID) (int64, error) {
+	result, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"userId": userID, "liftedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"liftedAt": time.Now(), "liftedBy": liftedBy}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// ListByUser lists a user's bans, newest first
+func (r *BanRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]*models.Ban, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, bson.M{"userId": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	bans := []*models.Ban{}
+	if err = cursor.All(ctx, &bans); err != nil {
+		return nil, err
+	}
+
+	return bans, nil
+}
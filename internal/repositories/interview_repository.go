@@ -138,6 +138,325 @@ func (r *InterviewRepository) UpdateRecording(ctx context.Context, id string, re
 	return err
 }
 
+// TransitionRecording moves the recording to changes.Status, along with the
+// non-empty URL, bot and failure fields of changes, but only if its current
+// status is one of from. It reports whether the recording changed, so stale
+// or duplicate events are no-ops.
+func (r *InterviewRepository) TransitionRecording(ctx context.Context, id string, from []string, changes models.Recording) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	set := bson.M{
+		"recording.status":    changes.Status,
+		"recording.updatedAt": time.Now(),
+	}
+	optional := map[string]string{
+		"recording.recallBotId":   changes.RecallBotID,
+		"recording.videoUrl":      changes.VideoURL,
+		"recording.audioUrl":      changes.AudioURL,
+		"recording.transcriptUrl": changes.TranscriptURL,
+		"recording.failureReason": changes.FailureReason,
+	}
+	for key, value := range optional {
+		if value != "" {
+			set[key] = value
+		}
+	}
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID, "recording.status": bson.M{"$in": from}},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount > 0, nil
+}
+
+// SetRecallBotID records the Recall bot recording the interview
+func (r *InterviewRepository) SetRecallBotID(ctx context.Context, id, botID string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$set": bson.M{"recording.recallBotId": botID, "recording.updatedAt": time.Now()}},
+	)
+	return err
+}
+
+// recordingFileFields are the fields holding each recording file: its storage
+// key and Recall URL, and for the transcript, its parsed content
+var recordingFileFields = map[string][]string{
+	models.RecordingFileVideo:      {"recording.videoKey", "recording.videoUrl"},
+	models.RecordingFileAudio:      {"recording.audioKey", "recording.audioUrl"},
+	models.RecordingFileTranscript: {"recording.transcriptKey", "recording.transcriptUrl", "transcript"},
+}
+
+// SetRecordingKeys records the bucket keys of the recording files copied so
+// far; empty keys are left as they are. The first call sets storedAt.
+func (r *InterviewRepository) SetRecordingKeys(ctx context.Context, id string, keys models.Recording) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	set := bson.M{}
+	optional := map[string]string{
+		"recording.videoKey":      keys.VideoKey,
+		"recording.audioKey":      keys.AudioKey,
+		"recording.transcriptKey": keys.TranscriptKey,
+	}
+	for key, value := range optional {
+		if value != "" {
+			set[key] = value
+		}
+	}
+
+	now := time.Now()
+	set["recording.updatedAt"] = now
+
+	_, err = r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$set": set, "$min": bson.M{"recording.storedAt": now}},
+	)
+	return err
+}
+
+// FindWithFileStoredBefore finds interviews that have the given recording
+// file stored, stored before the given time, leaving out those in skip
+func (r *InterviewRepository) FindWithFileStoredBefore(ctx context.Context, file string, before time.Time, skip []primitive.ObjectID, limit int64) ([]*models.Interview, error) {
+	fields, ok := recordingFileFields[file]
+	if !ok {
+		return nil, nil
+	}
+
+	filter := bson.M{
+		fields[0]:            bson.M{"$gt": ""},
+		"recording.storedAt": bson.M{"$lt": before},
+	}
+	if len(skip) > 0 {
+		filter["_id"] = bson.M{"$nin": skip}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "recording.storedAt", Value: 1}}).SetLimit(limit)
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var interviews []*models.Interview
+	if err = cursor.All(ctx, &interviews); err != nil {
+		return nil, err
+	}
+
+	return interviews, nil
+}
+
+// ClearRecordingFiles removes all trace of the given recording files from
+// the interview
+func (r *InterviewRepository) ClearRecordingFiles(ctx context.Context, id string, files []string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	unset := bson.M{}
+	for _, file := range files {
+		for _, field := range recordingFileFields[file] {
+			unset[field] = ""
+		}
+	}
+	if len(unset) == 0 {
+		return nil
+	}
+
+	_, err = r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$unset": unset, "$set": bson.M{"recording.updatedAt": time.Now()}},
+	)
+	return err
+}
+
+// MarkTranscriptReady records that the transcript is available, reporting
+// whether it wasn't already
+func (r *InterviewRepository) MarkTranscriptReady(ctx context.Context, id, transcriptURL string) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	set := bson.M{
+		"recording.transcriptReady": true,
+		"recording.updatedAt":       time.Now(),
+	}
+	if transcriptURL != "" {
+		set["recording.transcriptUrl"] = transcriptURL
+	}
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID, "recording.transcriptReady": bson.M{"$ne": true}},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount > 0, nil
+}
+
+// RecordConsent saves a participant's consent answer while the interview's
+// consent is pending. A participant can only answer once; it reports whether
+// the answer was saved.
+func (r *InterviewRepository) RecordConsent(ctx context.Context, id string, userID primitive.ObjectID, consent models.RecordingConsent) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.M{
+		"_id":           objectID,
+		"consentStatus": models.ConsentStatusPending,
+		"participants": bson.M{"$elemMatch": bson.M{
+			"userId":  userID,
+			"consent": bson.M{"$exists": false},
+		}},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"participants.$.consent": consent}})
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount > 0, nil
+}
+
+// SetConsentStatus decides a pending consent, reporting whether it was still pending
+func (r *InterviewRepository) SetConsentStatus(ctx context.Context, id, status string) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID, "consentStatus": models.ConsentStatusPending},
+		bson.M{"$set": bson.M{"consentStatus": status}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount > 0, nil
+}
+
+// ClaimEvaluation marks an interview's evaluation as running and reports
+// whether this caller got the claim. Evaluations that failed, or have been
+// running longer than staleAfter, can be claimed again.
+func (r *InterviewRepository) ClaimEvaluation(ctx context.Context, id string, staleAfter time.Duration) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	filter := bson.M{
+		"_id": objectID,
+		"$or": []bson.M{
+			{"evaluationStatus": bson.M{"$exists": false}},
+			{"evaluationStatus": models.EvaluationStatusFailed},
+			{"evaluationStatus": models.EvaluationStatusRunning, "evaluationStartedAt": bson.M{"$lt": now.Add(-staleAfter)}},
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+		"evaluationStatus":    models.EvaluationStatusRunning,
+		"evaluationStartedAt": now,
+	}})
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount > 0, nil
+}
+
+// CompleteEvaluation saves the evaluation of a claimed interview and reports
+// whether the claim was still held; rankings should only change if it was
+func (r *InterviewRepository) CompleteEvaluation(ctx context.Context, id string, evaluation models.Evaluation) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID, "evaluationStatus": models.EvaluationStatusRunning},
+		bson.M{"$set": bson.M{
+			"evaluation":       evaluation,
+			"evaluationStatus": models.EvaluationStatusCompleted,
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount > 0, nil
+}
+
+// Abandon ends an interview a participant left, reporting whether it was
+// still running. Completed interviews, and ones already left, are unchanged.
+func (r *InterviewRepository) Abandon(ctx context.Context, id, userID, status string) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":         objectID,
+			"status":      bson.M{"$ne": "completed"},
+			"abandonedBy": bson.M{"$exists": false},
+		},
+		bson.M{"$set": bson.M{
+			"status":      status,
+			"abandonedBy": userID,
+			"endedAt":     time.Now(),
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount > 0, nil
+}
+
+// FailEvaluation releases a claimed evaluation so a later delivery can retry it
+func (r *InterviewRepository) FailEvaluation(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID, "evaluationStatus": models.EvaluationStatusRunning},
+		bson.M{"$set": bson.M{"evaluationStatus": models.EvaluationStatusFailed}},
+	)
+	return err
+}
+
 // UpdateTranscript updates the transcript
 func (r *InterviewRepository) UpdateTranscript(ctx context.Context, id string, transcript models.Transcript) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
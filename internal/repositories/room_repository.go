@@ -7,6 +7,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/PRM710/Rankedterview-backend/internal/database"
 	"github.com/PRM710/Rankedterview-backend/internal/models"
@@ -152,6 +153,38 @@ func (r *RoomRepository) FindActiveRooms(ctx context.Context) ([]*models.Room, e
 	return rooms, nil
 }
 
+// List lists rooms, optionally filtered by status, newest first
+func (r *RoomRepository) List(ctx context.Context, status string, skip, limit int64) ([]*models.Room, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetSkip(skip).SetLimit(limit)
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rooms []*models.Room
+	if err = cursor.All(ctx, &rooms); err != nil {
+		return nil, err
+	}
+
+	return rooms, nil
+}
+
+// Count counts rooms, optionally filtered by status
+func (r *RoomRepository) Count(ctx context.Context, status string) (int64, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	return r.collection.CountDocuments(ctx, filter)
+}
+
 // CleanupOldRooms deletes rooms older than the specified duration
 func (r *RoomRepository) CleanupOldRooms(ctx context.Context, olderThan time.Duration) error {
 	cutoff := time.Now().Add(-olderThan)
@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/PRM710/Rankedterview-backend/internal/database"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
+// Number of rotated-out token hashes kept per session for reuse detection
+const sessionHashHistory = 50
+
+type SessionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewSessionRepository(db *database.MongoDB) *SessionRepository {
+	return &SessionRepository{
+		collection: db.Collection("sessions"),
+	}
+}
+
+// EnsureIndexes creates the indexes refresh token lookups and session
+// listings rely on
+func (r *SessionRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}},
+		{Keys: bson.D{{Key: "previousHashes", Value: 1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "lastUsedAt", Value: -1}}},
+	})
+	return err
+}
+
+// Create creates a new session
+func (r *SessionRepository) Create(ctx context.Context, session *models.Session) error {
+	session.ID = primitive.NewObjectID()
+	session.CreatedAt = time.Now()
+	session.LastUsedAt = session.CreatedAt
+	session.PreviousHashes = []string{}
+
+	_, err := r.collection.InsertOne(ctx, session)
+	return err
+}
+
+// FindByID finds a session by ID
+func (r *SessionRepository) FindByID(ctx context.Context, id string) (*models.Session, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var session models.Session
+	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// FindByTokenHash finds the session a refresh token hash belongs to, whether
+// it is the current token or one that was already rotated out
+func (r *SessionRepository) FindByTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	filter := bson.M{"$or": []bson.M{
+		{"tokenHash": hash},
+		{"previousHashes": hash},
+	}}
+
+	var session models.Session
+	err := r.collection.FindOne(ctx, filter).Decode(&session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// Rotate replaces the current token hash if it is still oldHash and the
+// session is not revoked. It returns false when another request won the race.
+func (r *SessionRepository) Rotate(ctx context.Context, id primitive.ObjectID, oldHash, newHash string, expiresAt time.Time) (bool, error) {
+	filter := bson.M{
+		"_id":       id,
+		"tokenHash": oldHash,
+		"revokedAt": bson.M{"$exists": false},
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"tokenHash":  newHash,
+			"lastUsedAt": time.Now(),
+			"expiresAt":  expiresAt,
+		},
+		"$push": bson.M{
+			"previousHashes": bson.M{"$each": []string{oldHash}, "$slice": -sessionHashHistory},
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+// Revoke revokes a session if it is not already revoked
+func (r *SessionRepository) Revoke(ctx context.Context, id primitive.ObjectID, reason string) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now(), "revokedReason": reason}},
+	)
+	return err
+}
+
+// RevokeAllForUser revokes every active session of a user
+func (r *SessionRepository) RevokeAllForUser(ctx context.Context, userID primitive.ObjectID, reason string) error {
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"userId": userID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now(), "revokedReason": reason}},
+	)
+	return err
+}
+
+// ListActiveByUser lists a user's unrevoked, unexpired sessions, most recently used first
+func (r *SessionRepository) ListActiveByUser(ctx context.Context, userID primitive.ObjectID) ([]*models.Session, error) {
+	filter := bson.M{
+		"userId":    userID,
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "lastUsedAt", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var sessions []*models.Session
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,142 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/PRM710/Rankedterview-backend/internal/database"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
+type UsageRepository struct {
+	collection *mongo.Collection
+}
+
+func NewUsageRepository(db *database.MongoDB) *UsageRepository {
+	return &UsageRepository{
+		collection: db.Collection("ai_usage"),
+	}
+}
+
+// Increment adds token usage and cost to a user's daily total for a model
+func (r *UsageRepository) Increment(ctx context.Context, userID primitive.ObjectID, day string, usage models.TokenUsage, cost float64) error {
+	filter := bson.M{
+		"userId": userID,
+		"day":    day,
+		"model":  usage.Model,
+	}
+
+	update := bson.M{
+		"$inc": bson.M{
+			"promptTokens":     usage.PromptTokens,
+			"completionTokens": usage.CompletionTokens,
+			"totalTokens":      usage.TotalTokens,
+			"costUsd":          cost,
+			"requests":         1,
+		},
+		"$set": bson.M{"updatedAt": time.Now()},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// SumUserDay returns a user's total tokens and cost for a day across all models
+func (r *UsageRepository) SumUserDay(ctx context.Context, userID, day string) (int64, float64, error) {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return r.sum(ctx, bson.M{"userId": objectID, "day": day})
+}
+
+// SumDay returns the total tokens and cost for a day across all users and models
+func (r *UsageRepository) SumDay(ctx context.Context, day string) (int64, float64, error) {
+	return r.sum(ctx, bson.M{"day": day})
+}
+
+// sum totals tokens and cost over the documents matching filter
+func (r *UsageRepository) sum(ctx context.Context, filter bson.M) (int64, float64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id":         nil,
+			"totalTokens": bson.M{"$sum": "$totalTokens"},
+			"costUsd":     bson.M{"$sum": "$costUsd"},
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result struct {
+		TotalTokens int64   `bson:"totalTokens"`
+		CostUSD     float64 `bson:"costUsd"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return result.TotalTokens, result.CostUSD, cursor.Err()
+}
+
+// Report aggregates usage between two days (inclusive) grouped by any of
+// "userId", "day" and "model"
+func (r *UsageRepository) Report(ctx context.Context, from, to string, groupBy []string) ([]models.UsageReportEntry, error) {
+	groupID := bson.M{}
+	project := bson.M{
+		"_id":              0,
+		"promptTokens":     1,
+		"completionTokens": 1,
+		"totalTokens":      1,
+		"costUsd":          1,
+		"requests":         1,
+	}
+
+	for _, field := range groupBy {
+		groupID[field] = "$" + field
+		if field == "userId" {
+			project[field] = bson.M{"$toString": "$_id." + field}
+		} else {
+			project[field] = "$_id." + field
+		}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"day": bson.M{"$gte": from, "$lte": to}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":              groupID,
+			"promptTokens":     bson.M{"$sum": "$promptTokens"},
+			"completionTokens": bson.M{"$sum": "$completionTokens"},
+			"totalTokens":      bson.M{"$sum": "$totalTokens"},
+			"costUsd":          bson.M{"$sum": "$costUsd"},
+			"requests":         bson.M{"$sum": "$requests"},
+		}}},
+		{{Key: "$project", Value: project}},
+		{{Key: "$sort", Value: bson.D{{Key: "day", Value: 1}, {Key: "costUsd", Value: -1}}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []models.UsageReportEntry{}
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
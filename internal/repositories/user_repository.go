@@ -7,6 +7,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/PRM710/Rankedterview-backend/internal/database"
 	"github.com/PRM710/Rankedterview-backend/internal/models"
@@ -74,14 +75,15 @@ func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*models
 	return &user, nil
 }
 
-// FindByOAuthID finds a user by OAuth provider and ID
+// FindByOAuthID finds a user by a linked OAuth provider and ID
 func (r *UserRepository) FindByOAuthID(ctx context.Context, provider, oauthID string) (*models.User, error) {
 	var user models.User
-	err := r.collection.FindOne(ctx, bson.M{
-		"oauthProvider": provider,
-		"oauthId":       oauthID,
-	}).Decode(&user)
-	
+	err := r.collection.FindOne(ctx, bson.M{"$or": []bson.M{
+		{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "providerId": oauthID}}},
+		// Accounts created before identities were tracked
+		{"oauthProvider": provider, "oauthId": oauthID},
+	}}).Decode(&user)
+
 	if err != nil {
 		return nil, err
 	}
@@ -89,6 +91,65 @@ func (r *UserRepository) FindByOAuthID(ctx context.Context, provider, oauthID st
 	return &user, nil
 }
 
+// AddIdentity links an OAuth identity to a user. It returns false if the user
+// already has an identity for that provider.
+func (r *UserRepository) AddIdentity(ctx context.Context, userID primitive.ObjectID, identity models.Identity) (bool, error) {
+	filter := bson.M{
+		"_id":                 userID,
+		"identities.provider": bson.M{"$ne": identity.Provider},
+		"oauthProvider":       bson.M{"$ne": identity.Provider},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{
+		"$push": bson.M{"identities": identity},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+// RemoveIdentity unlinks a provider from a user
+func (r *UserRepository) RemoveIdentity(ctx context.Context, userID primitive.ObjectID, provider string) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$pull": bson.M{"identities": bson.M{"provider": provider}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Clear the legacy pair too if it points at this provider
+	_, err = r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "oauthProvider": provider},
+		bson.M{"$set": bson.M{"oauthProvider": "", "oauthId": ""}},
+	)
+	return err
+}
+
+// ClaimUnverified hands an account whose email was never verified to the
+// person who just proved they own it: the unproven password and identities
+// are removed and the email marked verified. Accounts created through OAuth
+// before verification was tracked are verified and left alone.
+func (r *UserRepository) ClaimUnverified(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":           userID,
+			"emailVerified": bson.M{"$ne": true},
+			"oauthProvider": bson.M{"$in": bson.A{"", nil}},
+		},
+		bson.M{
+			"$set":   bson.M{"emailVerified": true, "oauthProvider": "", "oauthId": ""},
+			"$unset": bson.M{"passwordHash": "", "identities": ""},
+		},
+	)
+	return err
+}
+
 // Update updates a user
 func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
 	_, err := r.collection.UpdateOne(
@@ -114,6 +175,57 @@ func (r *UserRepository) UpdateLastLogin(ctx context.Context, userID string) err
 	return err
 }
 
+// UpdatePassword sets a user's password hash
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$set": bson.M{"passwordHash": passwordHash}},
+	)
+	return err
+}
+
+// MarkEmailVerified marks a user's email address as verified
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, userID string) error {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$set": bson.M{"emailVerified": true}},
+	)
+	return err
+}
+
+// UpdateRole sets a user's role
+func (r *UserRepository) UpdateRole(ctx context.Context, userID, role string) error {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$set": bson.M{"role": role}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
 // UpdateStats updates user statistics
 func (r *UserRepository) UpdateStats(ctx context.Context, userID string, stats models.UserStats) error {
 	objectID, err := primitive.ObjectIDFromHex(userID)
@@ -145,7 +257,7 @@ func (r *UserRepository) List(ctx context.Context, skip, limit int64) ([]*models
 	cursor, err := r.collection.Find(
 		ctx,
 		bson.M{},
-		// options.Find().SetSkip(skip).SetLimit(limit),
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetSkip(skip).SetLimit(limit),
 	)
 	if err != nil {
 		return nil, err
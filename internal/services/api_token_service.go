@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/PRM710/Rankedterview-backend/internal/config"
+	"github.com/PRM710/Rankedterview-backend/internal/database"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/repositories"
+	"github.com/PRM710/Rankedterview-backend/internal/utils"
+)
+
+var (
+	ErrInvalidAPIToken  = errors.New("invalid, expired or revoked API token")
+	ErrInvalidScope     = errors.New("invalid token scope")
+	ErrTooManyAPITokens = errors.New("too many active API tokens")
+	ErrAPITokenNotFound = errors.New("API token not found")
+)
+
+const (
+	// Marks personal access tokens so they aren't mistaken for JWTs
+	APITokenPrefix = "rtk_"
+
+	// Last-used tracking is written at most this often per token
+	apiTokenTouchInterval = time.Minute
+)
+
+// countRequestScript counts a request in the current rate limit window and
+// starts the window on its first request. Setting the expiry in the same step
+// means a failure can't leave a counter that never resets.
+var countRequestScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count`)
+
+type APITokenService struct {
+	tokenRepo  *repositories.APITokenRepository
+	redis      *database.RedisClient
+	moderation *ModerationService
+	config     *config.Config
+}
+
+func NewAPITokenService(
+	tokenRepo *repositories.APITokenRepository,
+	redis *database.RedisClient,
+	moderation *ModerationService,
+	cfg *config.Config,
+) *APITokenService {
+	return &APITokenService{
+		tokenRepo:  tokenRepo,
+		redis:      redis,
+		moderation: moderation,
+		config:     cfg,
+	}
+}
+
+// CreateToken creates a personal access token and returns it along with its
+// secret, which is only ever shown this once. A zero expiresIn never expires.
+func (s *APITokenService) CreateToken(ctx context.Context, userID, name string, scopes []string, expiresIn time.Duration) (*models.APIToken, string, error) {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(scopes) == 0 {
+		return nil, "", ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !models.IsValidScope(scope) {
+			return nil, "", ErrInvalidScope
+		}
+	}
+
+	count, err := s.tokenRepo.CountActiveByUser(ctx, objectID)
+	if err != nil {
+		return nil, "", err
+	}
+	if count >= int64(s.config.APITokenMaxPerUser) {
+		return nil, "", ErrTooManyAPITokens
+	}
+
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	raw := APITokenPrefix + secret
+
+	token := &models.APIToken{
+		UserID:    objectID,
+		Name:      name,
+		TokenHash: hashToken(raw),
+		Prefix:    displayPrefix(raw),
+		Scopes:    scopes,
+	}
+	if expiresIn > 0 {
+		expiresAt := time.Now().Add(expiresIn)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := s.tokenRepo.Create(ctx, token); err != nil {
+		return nil, "", err
+	}
+
+	return token, raw, nil
+}
+
+// ListTokens lists a user's active tokens
+func (s *APITokenService) ListTokens(ctx context.Context, userID string) ([]*models.APIToken, error) {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tokenRepo.ListActiveByUser(ctx, objectID)
+}
+
+// RevokeToken revokes one of a user's tokens
+func (s *APITokenService) RevokeToken(ctx context.Context, userID, tokenID string) error {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	tokenObjID, err := primitive.ObjectIDFromHex(tokenID)
+	if err != nil {
+		return ErrAPITokenNotFound
+	}
+
+	revoked, err := s.tokenRepo.Revoke(ctx, tokenObjID, userObjID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPITokenNotFound
+	}
+
+	return nil
+}
+
+// IsAPIToken reports whether a bearer token is a personal access token
+func (s *APITokenService) IsAPIToken(raw string) bool {
+	return strings.HasPrefix(raw, APITokenPrefix)
+}
+
+// AuthenticateAPIToken looks up an active token by its secret and records its use
+func (s *APITokenService) AuthenticateAPIToken(ctx context.Context, raw, ip string) (*models.APIToken, error) {
+	token, err := s.tokenRepo.FindByHash(ctx, hashToken(raw))
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInvalidAPIToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.IsActive() {
+		return nil, ErrInvalidAPIToken
+	}
+
+	if err := s.moderation.CheckNotBanned(ctx, token.UserID.Hex()); err != nil {
+		return nil, err
+	}
+
+	// Only write last-used once per interval so busy tokens don't hammer Mongo
+	touchKey := "apitoken:" + token.ID.Hex() + ":touched"
+	if first, err := s.redis.Client.SetNX(ctx, touchKey, 1, apiTokenTouchInterval).Result(); err == nil && first {
+		if err := s.tokenRepo.TouchLastUsed(ctx, token.ID, ip); err != nil {
+			log.Printf("Failed to record use of API token %s: %v", token.ID.Hex(), err)
+		}
+	}
+
+	return token, nil
+}
+
+// AllowAPITokenRequest counts a request against the token's per-minute limit
+// and returns the limit, how many requests remain and whether this one may proceed
+func (s *APITokenService) AllowAPITokenRequest(ctx context.Context, tokenID string) (int, int, bool, error) {
+	limit := s.config.APITokenRateLimit
+	key := "ratelimit:apitoken:" + tokenID
+
+	count, err := countRequestScript.Run(ctx, s.redis.Client, []string{key}, time.Minute.Milliseconds()).Int64()
+	if err != nil {
+		return limit, 0, false, err
+	}
+
+	remaining := limit - int(count)
+	if remaining < 0 {
+		return limit, 0, false, nil
+	}
+
+	return limit, remaining, true, nil
+}
+
+// displayPrefix is the start of a token that is stored in the clear so users
+// can tell their tokens apart
+func displayPrefix(raw string) string {
+	return raw[:len(APITokenPrefix)+8]
+}
@@ -0,0 +1,45 @@
+package services
+
+import "testing"
+
+func TestIsAPIToken(t *testing.T) {
+	s := &APITokenService{}
+
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{"rtk_0123456789abcdef", true},
+		{"rtk_", true},
+		{"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.e30.sig", false},
+		{"RTK_0123456789abcdef", false},
+		{"rtk0123456789abcdef", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := s.IsAPIToken(tt.token); got != tt.want {
+			t.Errorf("IsAPIToken(%q) = %v, want %v", tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestDisplayPrefix(t *testing.T) {
+	if got, want := displayPrefix("rtk_0123456789abcdef"), "rtk_01234567"; got != want {
+		t.Errorf("displayPrefix() = %q, want %q", got, want)
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	// SHA-256 of "abc"
+	if got, want := hashToken("abc"), "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"; got != want {
+		t.Errorf("hashToken(abc) = %s, want %s", got, want)
+	}
+
+	if hashToken("rtk_a") == hashToken("rtk_b") {
+		t.Error("different tokens hash the same")
+	}
+	if hashToken("rtk_a") != hashToken("rtk_a") {
+		t.Error("hashing the same token twice differs")
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/websocket"
+)
+
+var (
+	ErrIdentityInUse     = errors.New("this provider account is linked to another user")
+	ErrProviderLinked    = errors.New("a different account from this provider is already linked")
+	ErrIdentityNotLinked = errors.New("provider is not linked to this account")
+	ErrLastLoginMethod   = errors.New("cannot unlink the only way to sign in; set a password or link another provider first")
+)
+
+// LinkIdentity links the provider account that authorized code to the user
+func (s *AuthService) LinkIdentity(ctx context.Context, userID, provider, code, state string) (*models.User, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	profile, err := s.verifyOAuthCode(ctx, provider, code, state)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := s.userRepo.FindByOAuthID(ctx, provider, profile.ID)
+	if err == nil {
+		if owner.ID == user.ID {
+			return user, nil // already linked
+		}
+		return nil, ErrIdentityInUse
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	added, err := s.userRepo.AddIdentity(ctx, user.ID, models.Identity{
+		Provider:   provider,
+		ProviderID: profile.ID,
+		Email:      profile.Email,
+		LinkedAt:   time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		return nil, ErrProviderLinked
+	}
+
+	return s.userRepo.FindByID(ctx, userID)
+}
+
+// UnlinkIdentity removes a provider from the user, as long as another way to
+// sign in remains
+func (s *AuthService) UnlinkIdentity(ctx context.Context, userID, provider string) (*models.User, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	identities := user.LinkedIdentities()
+	linked := false
+	for _, identity := range identities {
+		if identity.Provider == provider {
+			linked = true
+		}
+	}
+
+	if !linked {
+		return nil, ErrIdentityNotLinked
+	}
+	if len(identities) == 1 && user.PasswordHash == "" {
+		return nil, ErrLastLoginMethod
+	}
+
+	if err := s.userRepo.RemoveIdentity(ctx, user.ID, provider); err != nil {
+		return nil, err
+	}
+
+	return s.userRepo.FindByID(ctx, userID)
+}
+
+// autoLinkByEmail links a new, provider-verified identity to the account that
+// already uses its email. If that account never verified its email, whoever
+// created it may not own the address, so its password and identities are
+// dropped and its sessions revoked before linking. Returns nil when there is no such account.
+func (s *AuthService) autoLinkByEmail(ctx context.Context, identity models.Identity) (*models.User, error) {
+	user, err := s.userRepo.FindByEmail(ctx, normalizeEmail(identity.Email))
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsEmailVerified() {
+		if err := s.userRepo.ClaimUnverified(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		if err := s.RevokeAllSessions(ctx, user.ID.Hex(), "account_claimed"); err != nil {
+			return nil, err
+		}
+		s.hub.DisconnectUser(user.ID.Hex(), websocket.CloseSessionRevoked, "account claimed")
+		log.Printf("User %s claimed by verified %s identity", user.ID.Hex(), identity.Provider)
+	}
+
+	added, err := s.userRepo.AddIdentity(ctx, user.ID, identity)
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		return nil, ErrProviderLinked
+	}
+
+	s.userRepo.UpdateLastLogin(ctx, user.ID.Hex())
+
+	return s.userRepo.FindByID(ctx, user.ID.Hex())
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/PRM710/Rankedterview-backend/internal/mailer"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/utils"
+	"github.com/PRM710/Rankedterview-backend/internal/websocket"
+)
+
+var (
+	ErrEmailNotVerified = errors.New("email address not verified")
+	ErrInvalidEmailLink = errors.New("invalid or expired link")
+	ErrWeakPassword     = errors.New("password must be between 8 and 72 characters")
+)
+
+const (
+	passwordMinLength = 8
+	passwordMaxLength = 72 // bcrypt ignores anything longer
+	passwordHashCost  = 12
+
+	// Minimum time between verification or reset emails to one address
+	emailResendInterval = time.Minute
+)
+
+// dummyPasswordHash is compared against when the account doesn't exist so
+// that login timing doesn't reveal which emails are registered
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("rankedterview-dummy-password"), passwordHashCost)
+
+// Register creates an email/password account and sends a verification email
+func (s *AuthService) Register(ctx context.Context, email, password, name string) (*models.User, error) {
+	email = normalizeEmail(email)
+	name = utils.SanitizeString(name)
+
+	if err := utils.ValidateEmail(email); err != nil {
+		return nil, err
+	}
+	if err := utils.ValidateRequired(name, "name"); err != nil {
+		return nil, err
+	}
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.userRepo.FindByEmail(ctx, email); err == nil {
+		return nil, ErrUserExists
+	} else if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Email:        email,
+		Name:         name,
+		PasswordHash: string(hash),
+	}
+
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		// The account exists; the user can ask for the email again
+		log.Printf("Failed to send verification email to user %s: %v", user.ID.Hex(), err)
+	}
+
+	return user, nil
+}
+
+// Login authenticates an email/password account and starts a new session
+func (s *AuthService) Login(ctx context.Context, email, password string, client ClientInfo) (*models.User, *AuthTokens, error) {
+	user, err := s.userRepo.FindByEmail(ctx, normalizeEmail(email))
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, nil, err
+	}
+
+	if user == nil || user.PasswordHash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	if s.config.RequireEmailVerification && !user.IsEmailVerified() {
+		return nil, nil, ErrEmailNotVerified
+	}
+
+	s.userRepo.UpdateLastLogin(ctx, user.ID.Hex())
+
+	tokens, err := s.startSession(ctx, user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, tokens, nil
+}
+
+// VerifyEmail consumes an email verification token
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	userID, err := s.consumeEmailToken(ctx, "verify", token)
+	if err != nil {
+		return err
+	}
+
+	return s.userRepo.MarkEmailVerified(ctx, userID)
+}
+
+// ResendVerification sends a new verification email. Unknown or already
+// verified addresses are silently ignored so they can't be probed.
+func (s *AuthService) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.userRepo.FindByEmail(ctx, normalizeEmail(email))
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if user.IsEmailVerified() || user.PasswordHash == "" {
+		return nil
+	}
+
+	return s.sendVerificationEmail(ctx, user)
+}
+
+// RequestPasswordReset emails a password reset link. Unknown addresses are
+// silently ignored so they can't be probed.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.userRepo.FindByEmail(ctx, normalizeEmail(email))
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !s.allowEmail(ctx, "reset", user.ID.Hex()) {
+		return nil
+	}
+
+	token, err := s.issueEmailToken(ctx, "reset", user.ID.Hex(), s.config.PasswordResetTTL, time.Hour)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your RANKEDterview password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to choose a new password. It expires in %s.\n\n%s\n\nIf you didn't ask for this, you can ignore this email.\n",
+			user.Name, s.config.PasswordResetTTL, s.emailLink("/reset-password", token),
+		),
+	})
+}
+
+// ResetPassword consumes a reset token, sets the new password and signs the
+// user out everywhere. Completing a reset also proves ownership of the email.
+func (s *AuthService) ResetPassword(ctx context.Context, token, password string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+
+	userID, err := s.consumeEmailToken(ctx, "reset", token)
+	if err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, userID, string(hash)); err != nil {
+		return err
+	}
+	if err := s.userRepo.MarkEmailVerified(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.RevokeAllSessions(ctx, userID, "password_reset"); err != nil {
+		return err
+	}
+	s.hub.DisconnectUser(userID, websocket.CloseSessionRevoked, "password reset")
+	return nil
+}
+
+// sendVerificationEmail issues a verification token and emails it
+func (s *AuthService) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	if !s.allowEmail(ctx, "verify", user.ID.Hex()) {
+		return nil
+	}
+
+	token, err := s.issueEmailToken(ctx, "verify", user.ID.Hex(), s.config.EmailVerificationTTL, 24*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your RANKEDterview email",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nConfirm your email address to finish setting up your account:\n\n%s\n\nThe link expires in %s.\n",
+			user.Name, s.emailLink("/verify-email", token), s.config.EmailVerificationTTL,
+		),
+	})
+}
+
+// allowEmail rate limits emails of one kind to a user
+func (s *AuthService) allowEmail(ctx context.Context, kind, userID string) bool {
+	ok, err := s.redis.Client.SetNX(ctx, fmt.Sprintf("auth:%s:sent:%s", kind, userID), 1, emailResendInterval).Result()
+	return err == nil && ok
+}
+
+// issueEmailToken stores a hashed single-use token of the given kind for a user
+func (s *AuthService) issueEmailToken(ctx context.Context, kind, userID, ttlSpec string, defaultTTL time.Duration) (string, error) {
+	ttl, err := utils.ParseDuration(ttlSpec)
+	if err != nil {
+		ttl = defaultTTL
+	}
+
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.redis.Set(ctx, fmt.Sprintf("auth:%s:%s", kind, hashToken(token)), userID, ttl); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeEmailToken redeems a token of the given kind and returns its user ID
+func (s *AuthService) consumeEmailToken(ctx context.Context, kind, token string) (string, error) {
+	if token == "" {
+		return "", ErrInvalidEmailLink
+	}
+
+	userID, err := s.redis.Client.GetDel(ctx, fmt.Sprintf("auth:%s:%s", kind, hashToken(token))).Result()
+	if err == redis.Nil {
+		return "", ErrInvalidEmailLink
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// emailLink builds a frontend link carrying a token
+func (s *AuthService) emailLink(path, token string) string {
+	return strings.TrimRight(s.config.AppBaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+func validatePassword(password string) error {
+	if len(password) < passwordMinLength || len(password) > passwordMaxLength {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -3,92 +3,188 @@ package services
 import (
 	"context"
 	"errors"
-	"fmt"
+	"net/http"
 	"net/url"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/PRM710/Rankedterview-backend/internal/config"
+	"github.com/PRM710/Rankedterview-backend/internal/database"
+	"github.com/PRM710/Rankedterview-backend/internal/mailer"
 	"github.com/PRM710/Rankedterview-backend/internal/models"
 	"github.com/PRM710/Rankedterview-backend/internal/repositories"
 	"github.com/PRM710/Rankedterview-backend/internal/utils"
+	"github.com/PRM710/Rankedterview-backend/internal/websocket"
 )
 
 var (
 	ErrUserExists         = errors.New("user already exists")
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserNotFound       = errors.New("user not found")
+	ErrUnsupportedOAuth   = errors.New("unsupported OAuth provider")
+	ErrInvalidOAuthState  = errors.New("invalid or expired OAuth state")
+	ErrOAuthEmailMissing  = errors.New("OAuth provider did not return a verified email")
+	ErrInvalidRefresh     = errors.New("invalid or expired refresh token")
+	ErrRefreshReused      = errors.New("refresh token reuse detected; session revoked")
+	ErrSessionNotFound    = errors.New("session not found")
 )
 
+// OAuthStateTTL is how long a login attempt may take between redirect and callback
+const OAuthStateTTL = 10 * time.Minute
+
 type AuthService struct {
-	userRepo *repositories.UserRepository
-	config   *config.Config
+	userRepo    *repositories.UserRepository
+	sessionRepo *repositories.SessionRepository
+	redis       *database.RedisClient
+	mailer      mailer.Mailer
+	moderation  *ModerationService
+	hub         *websocket.Hub
+	config      *config.Config
+	httpClient  *http.Client
 }
 
-func NewAuthService(userRepo *repositories.UserRepository, cfg *config.Config) *AuthService {
+func NewAuthService(
+	userRepo *repositories.UserRepository,
+	sessionRepo *repositories.SessionRepository,
+	redis *database.RedisClient,
+	mail mailer.Mailer,
+	moderation *ModerationService,
+	hub *websocket.Hub,
+	cfg *config.Config,
+) *AuthService {
 	return &AuthService{
-		userRepo: userRepo,
-		config:   cfg,
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		redis:       redis,
+		mailer:      mail,
+		moderation:  moderation,
+		hub:         hub,
+		config:      cfg,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AuthenticateWithOAuth completes an authorization-code login: it consumes the
+// state nonce issued by GetOAuthURL, exchanges the code with the provider and
+// registers or logs in the user the provider vouches for, starting a new session
+func (s *AuthService) AuthenticateWithOAuth(ctx context.Context, provider, code, state string, client ClientInfo) (*models.User, *AuthTokens, error) {
+	profile, err := s.verifyOAuthCode(ctx, provider, code, state)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if profile.Email == "" || !profile.EmailVerified {
+		return nil, nil, ErrOAuthEmailMissing
+	}
+
+	user, err := s.RegisterWithOAuth(ctx, provider, profile.ID, profile.Email, profile.Name, profile.Avatar)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.startSession(ctx, user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, tokens, nil
+}
+
+// verifyOAuthCode consumes the state nonce and exchanges the code for the
+// profile of the provider account that authorized it
+func (s *AuthService) verifyOAuthCode(ctx context.Context, provider, code, state string) (*oauthProfile, error) {
+	if err := s.consumeOAuthState(ctx, provider, state); err != nil {
+		return nil, err
+	}
+
+	switch provider {
+	case "google":
+		accessToken, err := s.exchangeCode(ctx, s.config.GoogleTokenURL, s.config.GoogleClientID, s.config.GoogleClientSecret, s.config.GoogleRedirectURI, code)
+		if err != nil {
+			return nil, err
+		}
+		return s.fetchGoogleProfile(ctx, accessToken)
+	case "github":
+		accessToken, err := s.exchangeCode(ctx, s.config.GitHubTokenURL, s.config.GitHubClientID, s.config.GitHubClientSecret, s.config.GitHubRedirectURI, code)
+		if err != nil {
+			return nil, err
+		}
+		return s.fetchGitHubProfile(ctx, accessToken)
+	default:
+		return nil, ErrUnsupportedOAuth
+	}
+}
+
+// consumeOAuthState checks that state was issued for provider and deletes it
+// so it cannot be replayed
+func (s *AuthService) consumeOAuthState(ctx context.Context, provider, state string) error {
+	if state == "" {
+		return ErrInvalidOAuthState
+	}
+
+	issuedFor, err := s.redis.Client.GetDel(ctx, "oauth:state:"+state).Result()
+	if err == redis.Nil {
+		return ErrInvalidOAuthState
+	}
+	if err != nil {
+		return err
+	}
+
+	if issuedFor != provider {
+		return ErrInvalidOAuthState
 	}
+
+	return nil
 }
 
-// RegisterWithOAuth registers or logs in a user via OAuth
-func (s *AuthService) RegisterWithOAuth(ctx context.Context, provider, oauthID, email, name, avatar string) (*models.User, string, error) {
+// RegisterWithOAuth registers or logs in a user via OAuth. Callers must have
+// verified the identity, and that email is verified, with the provider first.
+func (s *AuthService) RegisterWithOAuth(ctx context.Context, provider, oauthID, email, name, avatar string) (*models.User, error) {
 	// Check if user already exists
 	existingUser, err := s.userRepo.FindByOAuthID(ctx, provider, oauthID)
 
 	if err == nil {
-		// User exists, update last login and return token
+		// User exists, update last login
 		s.userRepo.UpdateLastLogin(ctx, existingUser.ID.Hex())
-		token, err := s.generateToken(existingUser)
-		if err != nil {
-			return nil, "", err
-		}
-		return existingUser, token, nil
+		return existingUser, nil
 	}
 
 	if err != mongo.ErrNoDocuments {
 		// Real error occurred
-		return nil, "", err
+		return nil, err
+	}
+
+	identity := models.Identity{
+		Provider:   provider,
+		ProviderID: oauthID,
+		Email:      email,
+		LinkedAt:   time.Now(),
+	}
+
+	// An account with the same email belongs to the same person
+	linkedUser, err := s.autoLinkByEmail(ctx, identity)
+	if err != nil || linkedUser != nil {
+		return linkedUser, err
 	}
 
 	// User doesn't exist, create new user
 	user := &models.User{
-		Email:         email,
+		Email:         normalizeEmail(email),
 		Name:          name,
 		Avatar:        avatar,
 		OAuthProvider: provider,
 		OAuthID:       oauthID,
+		Identities:    []models.Identity{identity},
+		EmailVerified: true, // providers only hand out verified emails
 	}
 
 	if err := s.userRepo.Create(ctx, user); err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	// Generate JWT token
-	token, err := s.generateToken(user)
-	if err != nil {
-		return nil, "", err
-	}
-
-	return user, token, nil
-}
-
-// Login attempts to log in a user (for future email/password auth)
-func (s *AuthService) Login(ctx context.Context, email, password string) (*models.User, string, error) {
-	// TODO: Implement email/password auth when needed
-	return nil, "", errors.New("email/password auth not implemented")
-}
-
-// RefreshToken generates a new access token
-func (s *AuthService) RefreshToken(ctx context.Context, userID string) (string, error) {
-	user, err := s.userRepo.FindByID(ctx, userID)
-	if err != nil {
-		return "", ErrUserNotFound
-	}
-
-	return s.generateToken(user)
+	return user, nil
 }
 
 // ValidateToken validates a JWT token
@@ -96,37 +192,39 @@ func (s *AuthService) ValidateToken(tokenString string) (*utils.JWTClaims, error
 	return utils.ValidateToken(tokenString, s.config.JWTSecret)
 }
 
-// generateToken generates a JWT token for a user
-func (s *AuthService) generateToken(user *models.User) (string, error) {
-	expiration, err := utils.ParseDuration(s.config.JWTExpiration)
-	if err != nil {
-		expiration = 15 * time.Minute // Default to 15 minutes
+// GetOAuthURL generates the OAuth URL for a provider along with the state
+// nonce it embeds; the nonce must come back to AuthenticateWithOAuth
+func (s *AuthService) GetOAuthURL(ctx context.Context, provider string) (string, string, error) {
+	var authURL string
+	params := url.Values{
+		"response_type": {"code"},
 	}
 
-	return utils.GenerateToken(
-		user.ID.Hex(),
-		user.Email,
-		s.config.JWTSecret,
-		expiration,
-	)
-}
-
-// GetOAuthURL generates the OAuth URL for a provider
-func (s *AuthService) GetOAuthURL(provider string) (string, error) {
 	switch provider {
 	case "google":
-		return fmt.Sprintf(
-			"https://accounts.google.com/o/oauth2/v2/auth?client_id=%s&redirect_uri=%s&response_type=code&scope=openid%%20email%%20profile&access_type=offline",
-			s.config.GoogleClientID,
-			url.QueryEscape(s.config.GoogleRedirectURI),
-		), nil
+		authURL = s.config.GoogleAuthURL
+		params.Set("client_id", s.config.GoogleClientID)
+		params.Set("redirect_uri", s.config.GoogleRedirectURI)
+		params.Set("scope", "openid email profile")
+		params.Set("access_type", "offline")
 	case "github":
-		return fmt.Sprintf(
-			"https://github.com/login/oauth/authorize?client_id=%s&redirect_uri=%s&scope=user:email",
-			s.config.GitHubClientID,
-			url.QueryEscape(s.config.GitHubRedirectURI),
-		), nil
+		authURL = s.config.GitHubAuthURL
+		params.Set("client_id", s.config.GitHubClientID)
+		params.Set("redirect_uri", s.config.GitHubRedirectURI)
+		params.Set("scope", "read:user user:email")
 	default:
-		return "", errors.New("unsupported OAuth provider")
+		return "", "", ErrUnsupportedOAuth
+	}
+
+	state, err := utils.RandomToken(32)
+	if err != nil {
+		return "", "", err
 	}
+
+	if err := s.redis.Set(ctx, "oauth:state:"+state, provider, OAuthStateTTL); err != nil {
+		return "", "", err
+	}
+	params.Set("state", state)
+
+	return authURL + "?" + params.Encode(), state, nil
 }
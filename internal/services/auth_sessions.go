@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/utils"
+)
+
+// AuthTokens is the token pair handed to a client when a session starts or is refreshed
+type AuthTokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"` // access token lifetime in seconds
+}
+
+// ClientInfo describes the device a session is started from
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// startSession creates a session for a freshly authenticated user and issues its first tokens
+func (s *AuthService) startSession(ctx context.Context, user *models.User, client ClientInfo) (*AuthTokens, error) {
+	if err := s.moderation.CheckNotBanned(ctx, user.ID.Hex()); err != nil {
+		return nil, err
+	}
+
+	refreshToken, hash, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		UserID:    user.ID,
+		TokenHash: hash,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+		ExpiresAt: time.Now().Add(s.refreshExpiration()),
+	}
+
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, session.ID.Hex(), refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented token
+// is rotated out; presenting it again revokes the whole session, since either
+// the client or an attacker is holding a stolen copy.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*AuthTokens, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefresh
+	}
+
+	hash := hashToken(refreshToken)
+
+	session, err := s.sessionRepo.FindByTokenHash(ctx, hash)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInvalidRefresh
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if session.TokenHash != hash {
+		// An already rotated token was replayed
+		if err := s.revoke(ctx, session.ID, "reuse"); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshReused
+	}
+
+	if !session.IsActive() {
+		return nil, ErrInvalidRefresh
+	}
+
+	user, err := s.userRepo.FindByID(ctx, session.UserID.Hex())
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if err := s.moderation.CheckNotBanned(ctx, user.ID.Hex()); err != nil {
+		return nil, err
+	}
+
+	newToken, newHash, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := s.sessionRepo.Rotate(ctx, session.ID, hash, newHash, time.Now().Add(s.refreshExpiration()))
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// A concurrent request already used this token
+		if err := s.revoke(ctx, session.ID, "reuse"); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshReused
+	}
+
+	return s.issueTokens(user, session.ID.Hex(), newToken)
+}
+
+// Logout revokes the session an access token belongs to, along with every
+// access token issued for it
+func (s *AuthService) Logout(ctx context.Context, userID, sessionID string) error {
+	return s.RevokeSession(ctx, userID, sessionID, "logout")
+}
+
+// ListSessions lists a user's active sessions
+func (s *AuthService) ListSessions(ctx context.Context, userID string) ([]*models.Session, error) {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.sessionRepo.ListActiveByUser(ctx, objectID)
+}
+
+// RevokeSession revokes one of a user's sessions
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID, reason string) error {
+	session, err := s.sessionRepo.FindByID(ctx, sessionID)
+	if err != nil || session.UserID.Hex() != userID {
+		return ErrSessionNotFound
+	}
+
+	return s.revoke(ctx, session.ID, reason)
+}
+
+// RevokeAllSessions revokes every session of a user
+func (s *AuthService) RevokeAllSessions(ctx context.Context, userID, reason string) error {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := s.sessionRepo.ListActiveByUser(ctx, objectID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.sessionRepo.RevokeAllForUser(ctx, objectID, reason); err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if err := s.markRevoked(ctx, session.ID.Hex(), reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsSessionRevoked reports whether the session an access token was issued for
+// has been revoked. Tokens from before sessions existed have no session ID.
+func (s *AuthService) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	if sessionID == "" {
+		return false, nil
+	}
+	return s.redis.Exists(ctx, revokedSessionKey(sessionID))
+}
+
+// revoke revokes a session, and the access tokens issued for it
+func (s *AuthService) revoke(ctx context.Context, sessionID primitive.ObjectID, reason string) error {
+	if err := s.sessionRepo.Revoke(ctx, sessionID, reason); err != nil {
+		return err
+	}
+	return s.markRevoked(ctx, sessionID.Hex(), reason)
+}
+
+// markRevoked records a revoked session until the last access token issued
+// for it has expired, so AuthMiddleware can reject those tokens
+func (s *AuthService) markRevoked(ctx context.Context, sessionID, reason string) error {
+	return s.redis.Set(ctx, revokedSessionKey(sessionID), reason, s.accessExpiration())
+}
+
+func revokedSessionKey(sessionID string) string {
+	return "session:revoked:" + sessionID
+}
+
+// issueTokens signs an access token for the session and pairs it with the refresh token
+func (s *AuthService) issueTokens(user *models.User, sessionID, refreshToken string) (*AuthTokens, error) {
+	expiration := s.accessExpiration()
+
+	accessToken, err := utils.GenerateToken(
+		user.ID.Hex(),
+		user.Email,
+		s.RoleOf(user),
+		sessionID,
+		s.config.JWTSecret,
+		expiration,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(expiration.Seconds()),
+	}, nil
+}
+
+// RoleOf returns a user's role, as put in their tokens. Users listed in
+// ADMIN_USER_IDS are always admins so a fresh deployment has someone who can
+// hand out roles.
+func (s *AuthService) RoleOf(user *models.User) string {
+	for _, id := range s.config.AdminUserIDs {
+		if strings.TrimSpace(id) == user.ID.Hex() {
+			return models.RoleAdmin
+		}
+	}
+	return user.EffectiveRole()
+}
+
+// accessExpiration returns the configured access token lifetime
+func (s *AuthService) accessExpiration() time.Duration {
+	expiration, err := utils.ParseDuration(s.config.JWTExpiration)
+	if err != nil {
+		expiration = 15 * time.Minute // Default to 15 minutes
+	}
+	return expiration
+}
+
+// refreshExpiration returns the configured refresh token lifetime
+func (s *AuthService) refreshExpiration() time.Duration {
+	expiration, err := utils.ParseDuration(s.config.RefreshTokenExpiration)
+	if err != nil {
+		expiration = 7 * 24 * time.Hour // Default to 7 days
+	}
+	return expiration
+}
+
+// newRefreshToken returns a new opaque refresh token and the hash that is stored
+func newRefreshToken() (string, string, error) {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return token, hashToken(token), nil
+}
+
+// hashToken hashes a random token for storage; tokens are random so no salt is needed
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/PRM710/Rankedterview-backend/internal/config"
+	"github.com/PRM710/Rankedterview-backend/internal/database"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/utils"
+	"github.com/PRM710/Rankedterview-backend/internal/websocket"
+)
+
+var (
+	ErrCoachingDisabled = errors.New("live coaching is not available for this room")
+)
+
+const (
+	// Number of recent segments kept per room and sent to the model
+	coachingWindowSize = 30
+	// Minimum buffered segments before the first hint is generated
+	coachingMinSegments = 4
+	// How long buffered segments are kept after the last one arrives
+	coachingBufferTTL = 3 * time.Hour
+)
+
+type CoachingService struct {
+	redis             *database.RedisClient
+	evaluationService *EvaluationService
+	usageService      *UsageService
+	hub               *websocket.Hub
+	config            *config.Config
+}
+
+func NewCoachingService(
+	redis *database.RedisClient,
+	evaluationService *EvaluationService,
+	usageService *UsageService,
+	hub *websocket.Hub,
+	cfg *config.Config,
+) *CoachingService {
+	return &CoachingService{
+		redis:             redis,
+		evaluationService: evaluationService,
+		usageService:      usageService,
+		hub:               hub,
+		config:            cfg,
+	}
+}
+
+// IngestSegment buffers a partial transcript segment from a live call and,
+// when the room's rate limit allows, pushes a coaching hint to the
+// interviewee. Segment speakers are user IDs for client relays or provider
+// labels for Recall real-time events. Ranked rooms never get hints.
+func (s *CoachingService) IngestSegment(ctx context.Context, roomID string, segment models.TranscriptSegment) error {
+	if !s.config.CoachingEnabled || strings.TrimSpace(segment.Text) == "" {
+		return nil
+	}
+
+	roomState, err := s.redis.HGetAll(ctx, "room:"+roomID)
+	if err != nil {
+		return err
+	}
+
+	if len(roomState) == 0 || roomState["mode"] != models.RoomModePractice || roomState["status"] == "ended" {
+		return ErrCoachingDisabled
+	}
+
+	interviewee := roomState["interviewee"]
+	if interviewee == "" {
+		return ErrCoachingDisabled
+	}
+
+	// Buffer the segment
+	segmentsKey := fmt.Sprintf("coaching:%s:segments", roomID)
+	encoded, err := json.Marshal(segment)
+	if err != nil {
+		return err
+	}
+	if err := s.redis.Client.RPush(ctx, segmentsKey, encoded).Err(); err != nil {
+		return err
+	}
+	s.redis.Client.LTrim(ctx, segmentsKey, -coachingWindowSize, -1)
+	s.redis.Expire(ctx, segmentsKey, coachingBufferTTL)
+
+	window, err := s.redis.Client.LRange(ctx, segmentsKey, 0, -1).Result()
+	if err != nil || len(window) < coachingMinSegments {
+		return err
+	}
+
+	// Rate limit: one hint per interval, and a cap per room
+	interval, err := utils.ParseDuration(s.config.CoachingHintInterval)
+	if err != nil {
+		interval = 45 * time.Second
+	}
+
+	acquired, err := s.redis.Client.SetNX(ctx, fmt.Sprintf("coaching:%s:cooldown", roomID), 1, interval).Result()
+	if err != nil || !acquired {
+		return err
+	}
+
+	countKey := fmt.Sprintf("coaching:%s:hints", roomID)
+	count, err := s.redis.Client.Incr(ctx, countKey).Result()
+	if err != nil {
+		return err
+	}
+	s.redis.Expire(ctx, countKey, coachingBufferTTL)
+	if s.config.CoachingMaxHints > 0 && count > int64(s.config.CoachingMaxHints) {
+		return nil
+	}
+
+	return s.sendHint(ctx, roomID, interviewee, roomState["interviewer"], window)
+}
+
+// sendHint generates a hint from the buffered window and pushes it to the interviewee
+func (s *CoachingService) sendHint(ctx context.Context, roomID, interviewee, interviewer string, window []string) error {
+	lines := make([]string, 0, len(window))
+	for _, raw := range window {
+		var segment models.TranscriptSegment
+		if err := json.Unmarshal([]byte(raw), &segment); err != nil {
+			continue
+		}
+
+		speaker := segment.Speaker
+		switch speaker {
+		case interviewee:
+			speaker = "Candidate"
+		case interviewer:
+			speaker = "Interviewer"
+		}
+		lines = append(lines, speaker+": "+segment.Text)
+	}
+
+	// Coaching spend counts against the interviewee's AI budget
+	userIDs := []string{interviewee}
+	model, err := s.usageService.SelectModel(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+
+	hint, usage, err := s.evaluationService.GenerateQuickFeedback(ctx, strings.Join(lines, "\n"), model)
+	if _, usageErr := s.usageService.Record(ctx, userIDs, usage); usageErr != nil {
+		log.Printf("Failed to record coaching usage for room %s: %v", roomID, usageErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.hub.BroadcastToUser(interviewee, websocket.CoachingHintEvent{
+		Type:   websocket.EventCoachingHint,
+		RoomID: roomID,
+		Hint:   strings.TrimSpace(hint),
+	})
+
+	return nil
+}
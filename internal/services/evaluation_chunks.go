@@ -0,0 +1,404 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
+// transcriptChunk is a contiguous run of segments small enough for one prompt
+type transcriptChunk struct {
+	Segments []models.TranscriptSegment
+	Offset   int // index of the first segment in the full transcript
+	Start    float64
+	End      float64
+}
+
+// chunkAnalysis is the model's partial evaluation of a single chunk
+type chunkAnalysis struct {
+	Scores       models.Scores      `json:"scores"`
+	Strengths    []string           `json:"strengths"`
+	Improvements []string           `json:"improvements"`
+	Notes        string             `json:"notes"`
+	Highlights   []models.Highlight `json:"highlights"`
+}
+
+// EvaluateTranscript evaluates a stored transcript with the given model (the
+// configured model when empty). Short transcripts are evaluated in a single
+// pass; long ones are split into token-bounded chunks that are analysed
+// separately and then aggregated into one evaluation. Transcripts with only
+// raw text are chunked by line. Token usage is returned even when the
+// evaluation fails part way through.
+func (s *EvaluationService) EvaluateTranscript(ctx context.Context, transcript *models.Transcript, model string) (*models.Evaluation, models.TokenUsage, error) {
+	if model == "" {
+		model = s.config.OpenAIModel
+	}
+
+	budget := s.config.EvaluationChunkTokens
+	segments := transcript.Segments
+
+	if len(segments) == 0 {
+		if budget <= 0 || estimateTokens(transcript.Raw) <= budget {
+			return s.evaluateText(ctx, transcript.Raw, model)
+		}
+		segments = rawSegments(transcript.Raw, budget)
+	} else {
+		rendered := renderSegments(segments, 0)
+
+		if budget <= 0 || estimateTokens(rendered) <= budget {
+			evaluation, usage, err := s.evaluateText(ctx, rendered, model)
+			if err != nil {
+				return nil, usage, err
+			}
+			evaluation.Feedback.Highlights = verifyHighlights(evaluation.Feedback.Highlights, segments, 0)
+			return evaluation, usage, nil
+		}
+	}
+
+	// Map: analyse each chunk on its own
+	chunks := chunkSegments(segments, budget)
+	analyses := make([]chunkAnalysis, 0, len(chunks))
+	usage := models.TokenUsage{Model: model}
+
+	for i, chunk := range chunks {
+		analysis, resp, err := s.analyzeChunk(ctx, model, chunk, i, len(chunks))
+		addUsage(&usage, resp)
+		if err != nil {
+			return nil, usage, fmt.Errorf("failed to analyse chunk %d/%d: %w", i+1, len(chunks), err)
+		}
+
+		// Highlights must point at segments inside the chunk they came from
+		analysis.Highlights = verifyHighlights(analysis.Highlights, chunk.Segments, chunk.Offset)
+		analyses = append(analyses, *analysis)
+	}
+
+	// Reduce: merge the partial analyses into the final evaluation
+	evaluation, resp, err := s.aggregateChunks(ctx, model, chunks, analyses)
+	addUsage(&usage, resp)
+	if err != nil {
+		return nil, usage, fmt.Errorf("failed to aggregate chunk analyses: %w", err)
+	}
+
+	evaluation.ProcessedAt = time.Now()
+	applyUsage(evaluation, usage)
+
+	return evaluation, usage, nil
+}
+
+// analyzeChunk asks the model for a partial evaluation of one chunk
+func (s *EvaluationService) analyzeChunk(ctx context.Context, model string, chunk transcriptChunk, index, total int) (*chunkAnalysis, openai.Usage, error) {
+	prompt := fmt.Sprintf(`
+This is part %d of %d of a longer interview transcript, covering %s.
+Each line starts with its segment number and, when known, the time in seconds
+at which it was said, as [#number @seconds].
+
+TRANSCRIPT PART:
+%s
+
+Evaluate only what happens in this part (score 0-100 for each criterion):
+communication, technical, confidence, structure and overall.
+
+Also provide:
+- up to 3 strengths and up to 3 areas for improvement seen in this part
+- short notes (2-4 sentences) summarising what was discussed and how it went
+- up to 3 highlights; each MUST list the segment numbers of the line(s) above it
+  refers to in "segmentIndexes" and use the first line's time as its timestamp
+
+Format your response as JSON with this structure:
+{
+  "scores": {"communication": 0-100, "technical": 0-100, "confidence": 0-100, "structure": 0-100, "overall": 0-100},
+  "strengths": ["..."],
+  "improvements": ["..."],
+  "notes": "...",
+  "highlights": [{"segmentIndexes": [42, 43], "timestamp": 120.5, "type": "good", "comment": "..."}]
+}
+`, index+1, total, chunkSpan(chunk), renderSegments(chunk.Segments, chunk.Offset))
+
+	content, usage, err := s.complete(ctx, model, evaluatorSystemPrompt, prompt, s.config.OpenAIMaxTokens, 0.5)
+	if err != nil {
+		return nil, usage, err
+	}
+
+	jsonStr, err := extractJSON(content)
+	if err != nil {
+		return nil, usage, err
+	}
+
+	var analysis chunkAnalysis
+	if err := json.Unmarshal([]byte(jsonStr), &analysis); err != nil {
+		return nil, usage, fmt.Errorf("failed to parse chunk analysis: %w", err)
+	}
+
+	return &analysis, usage, nil
+}
+
+// aggregateChunks merges per-chunk analyses into a final evaluation. The model
+// picks highlights from the already-validated candidates by ID so that every
+// timestamp in the result still points at a real segment.
+func (s *EvaluationService) aggregateChunks(ctx context.Context, model string, chunks []transcriptChunk, analyses []chunkAnalysis) (*models.Evaluation, openai.Usage, error) {
+	var parts strings.Builder
+	var candidates []models.Highlight
+
+	for i, analysis := range analyses {
+		scores, _ := json.Marshal(analysis.Scores)
+		fmt.Fprintf(&parts, "PART %d (%s)\n", i+1, chunkSpan(chunks[i]))
+		fmt.Fprintf(&parts, "Scores: %s\n", scores)
+		fmt.Fprintf(&parts, "Strengths: %s\n", strings.Join(analysis.Strengths, "; "))
+		fmt.Fprintf(&parts, "Improvements: %s\n", strings.Join(analysis.Improvements, "; "))
+		fmt.Fprintf(&parts, "Notes: %s\n\n", analysis.Notes)
+
+		candidates = append(candidates, analysis.Highlights...)
+	}
+
+	var highlightList strings.Builder
+	for id, highlight := range candidates {
+		fmt.Fprintf(&highlightList, "%d: [%s] (%s) %s\n", id, formatClock(highlight.Timestamp), highlight.Type, highlight.Comment)
+	}
+
+	prompt := fmt.Sprintf(`
+A long interview was evaluated in %d consecutive parts. Combine the partial
+evaluations below into one evaluation of the whole interview.
+
+PARTIAL EVALUATIONS:
+%s
+CANDIDATE HIGHLIGHTS (id: [time] (type) comment):
+%s
+Provide final scores (0-100) for the whole interview, 3-5 key strengths,
+3-5 areas for improvement, an overall summary (2-3 sentences) and pick the
+2-4 most useful highlights by their id (you may reword the comment).
+
+Format your response as JSON with this structure:
+{
+  "scores": {"communication": 0-100, "technical": 0-100, "confidence": 0-100, "structure": 0-100, "overall": 0-100},
+  "feedback": {
+    "strengths": ["..."],
+    "improvements": ["..."],
+    "summary": "...",
+    "highlights": [{"id": 0, "type": "good", "comment": "..."}]
+  }
+}
+`, len(analyses), parts.String(), highlightList.String())
+
+	content, usage, err := s.complete(ctx, model, evaluatorSystemPrompt, prompt, s.config.OpenAIMaxTokens, 0.5)
+	if err != nil {
+		return nil, usage, err
+	}
+
+	jsonStr, err := extractJSON(content)
+	if err != nil {
+		return nil, usage, err
+	}
+
+	var result struct {
+		Scores   models.Scores `json:"scores"`
+		Feedback struct {
+			Strengths    []string `json:"strengths"`
+			Improvements []string `json:"improvements"`
+			Summary      string   `json:"summary"`
+			Highlights   []struct {
+				ID      int    `json:"id"`
+				Type    string `json:"type"`
+				Comment string `json:"comment"`
+			} `json:"highlights"`
+		} `json:"feedback"`
+	}
+
+	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
+		return nil, usage, fmt.Errorf("failed to parse aggregated evaluation: %w", err)
+	}
+
+	// Fall back to a duration-weighted mean if the model returned no scores
+	if result.Scores == (models.Scores{}) {
+		result.Scores = weightedChunkScores(chunks, analyses)
+	}
+	if result.Scores.Overall == 0 {
+		result.Scores.Overall = (result.Scores.Communication +
+			result.Scores.Technical +
+			result.Scores.Confidence +
+			result.Scores.Structure) / 4.0
+	}
+
+	// Resolve chosen highlights back to their validated timestamps
+	highlights := make([]models.Highlight, 0, len(result.Feedback.Highlights))
+	for _, chosen := range result.Feedback.Highlights {
+		if chosen.ID < 0 || chosen.ID >= len(candidates) {
+			continue
+		}
+		highlight := candidates[chosen.ID]
+		if chosen.Type != "" {
+			highlight.Type = chosen.Type
+		}
+		if chosen.Comment != "" {
+			highlight.Comment = chosen.Comment
+		}
+		highlights = append(highlights, highlight)
+	}
+
+	evaluation := &models.Evaluation{
+		Scores: result.Scores,
+		Feedback: models.Feedback{
+			Strengths:    result.Feedback.Strengths,
+			Improvements: result.Feedback.Improvements,
+			Summary:      result.Feedback.Summary,
+			Highlights:   highlights,
+		},
+	}
+
+	if evaluation.Feedback.Summary == "" {
+		return nil, usage, errors.New("aggregated evaluation has no summary")
+	}
+
+	return evaluation, usage, nil
+}
+
+// chunkSegments splits segments into consecutive chunks of at most maxTokens
+// (estimated). A single oversized segment gets a chunk of its own.
+func chunkSegments(segments []models.TranscriptSegment, maxTokens int) []transcriptChunk {
+	var chunks []transcriptChunk
+	var current transcriptChunk
+	currentTokens := 0
+
+	for i, segment := range segments {
+		cost := estimateTokens(formatSegment(i, segment))
+
+		if len(current.Segments) > 0 && currentTokens+cost > maxTokens {
+			chunks = append(chunks, current)
+			current = transcriptChunk{}
+			currentTokens = 0
+		}
+
+		if len(current.Segments) == 0 {
+			current.Offset = i
+			current.Start = segment.StartTime
+		}
+		current.Segments = append(current.Segments, segment)
+		current.End = segment.EndTime
+		currentTokens += cost
+	}
+
+	if len(current.Segments) > 0 {
+		chunks = append(chunks, current)
+	}
+
+	return chunks
+}
+
+// rawSegments splits a transcript that only has raw text into untimed
+// segments, one per "Speaker: text" line. Lines longer than maxTokens are
+// split between words so that every segment fits in a chunk.
+func rawSegments(raw string, maxTokens int) []models.TranscriptSegment {
+	maxChars := maxTokens * 4
+
+	var segments []models.TranscriptSegment
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		speaker, text, ok := strings.Cut(line, ": ")
+		if !ok {
+			speaker, text = "", line
+		}
+
+		var piece strings.Builder
+		for _, word := range strings.Fields(text) {
+			if piece.Len() > 0 && piece.Len()+1+len(word) > maxChars {
+				segments = append(segments, models.TranscriptSegment{Speaker: speaker, Text: piece.String()})
+				piece.Reset()
+			}
+			if piece.Len() > 0 {
+				piece.WriteByte(' ')
+			}
+			piece.WriteString(word)
+		}
+		if piece.Len() > 0 {
+			segments = append(segments, models.TranscriptSegment{Speaker: speaker, Text: piece.String()})
+		}
+	}
+
+	return segments
+}
+
+// weightedChunkScores averages chunk scores weighted by chunk duration
+func weightedChunkScores(chunks []transcriptChunk, analyses []chunkAnalysis) models.Scores {
+	var total models.Scores
+	var weightSum float64
+
+	for i, analysis := range analyses {
+		weight := chunks[i].End - chunks[i].Start
+		if weight <= 0 {
+			weight = 1
+		}
+		total.Communication += analysis.Scores.Communication * weight
+		total.Technical += analysis.Scores.Technical * weight
+		total.Confidence += analysis.Scores.Confidence * weight
+		total.Structure += analysis.Scores.Structure * weight
+		total.Overall += analysis.Scores.Overall * weight
+		weightSum += weight
+	}
+
+	if weightSum == 0 {
+		return total
+	}
+
+	return models.Scores{
+		Communication: total.Communication / weightSum,
+		Technical:     total.Technical / weightSum,
+		Confidence:    total.Confidence / weightSum,
+		Structure:     total.Structure / weightSum,
+		Overall:       total.Overall / weightSum,
+	}
+}
+
+// renderSegments renders segments one per line, numbered from offset
+func renderSegments(segments []models.TranscriptSegment, offset int) string {
+	lines := make([]string, len(segments))
+	for i, segment := range segments {
+		lines[i] = formatSegment(offset+i, segment)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatSegment renders a segment so the model can cite its number and start
+// time. Segments split from raw text have no time or, sometimes, speaker.
+func formatSegment(index int, segment models.TranscriptSegment) string {
+	prefix := fmt.Sprintf("[#%d @%.1f]", index, segment.StartTime)
+	if !isTimed(segment) {
+		prefix = fmt.Sprintf("[#%d]", index)
+	}
+	if segment.Speaker == "" {
+		return prefix + " " + segment.Text
+	}
+	return fmt.Sprintf("%s %s: %s", prefix, segment.Speaker, segment.Text)
+}
+
+func isTimed(segment models.TranscriptSegment) bool {
+	return segment.StartTime != 0 || segment.EndTime != 0
+}
+
+// chunkSpan describes the part of the transcript a chunk covers
+func chunkSpan(chunk transcriptChunk) string {
+	if chunk.End == 0 {
+		return fmt.Sprintf("segments #%d to #%d", chunk.Offset, chunk.Offset+len(chunk.Segments)-1)
+	}
+	return formatClock(chunk.Start) + " to " + formatClock(chunk.End)
+}
+
+// formatClock formats seconds as m:ss
+func formatClock(seconds float64) string {
+	total := int(seconds)
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
+// estimateTokens roughly estimates the token count of a string (~4 characters per token)
+func estimateTokens(s string) int {
+	return len(s)/4 + 1
+}
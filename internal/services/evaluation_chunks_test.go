@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/PRM710/Rankedterview-backend/internal/config"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
+// timedSegments returns n segments of equal length, one per second
+func timedSegments(n int) []models.TranscriptSegment {
+	segments := make([]models.TranscriptSegment, n)
+	for i := range segments {
+		segments[i] = models.TranscriptSegment{
+			Speaker:   "Alice",
+			Text:      "I would start by clarifying the requirements.",
+			StartTime: float64(i + 1),
+			EndTime:   float64(i + 1),
+		}
+	}
+	return segments
+}
+
+func TestChunkSegments(t *testing.T) {
+	segments := timedSegments(5)
+	cost := estimateTokens(formatSegment(0, segments[0]))
+
+	oversized := timedSegments(3)
+	oversized[1].Text = strings.Repeat("word ", 10*cost)
+
+	tests := []struct {
+		name      string
+		segments  []models.TranscriptSegment
+		maxTokens int
+		sizes     []int
+	}{
+		{"budget fits exactly two segments", segments, 2 * cost, []int{2, 2, 1}},
+		{"budget one token short of two segments", segments, 2*cost - 1, []int{1, 1, 1, 1, 1}},
+		{"budget fits three segments", segments, 3 * cost, []int{3, 2}},
+		{"budget fits everything", segments, 5 * cost, []int{5}},
+		{"oversized segment gets its own chunk", oversized, 2 * cost, []int{1, 1, 1}},
+		{"no segments", nil, cost, nil},
+	}
+
+	for _, tt := range tests {
+		chunks := chunkSegments(tt.segments, tt.maxTokens)
+
+		var sizes []int
+		offset := 0
+		for _, chunk := range chunks {
+			sizes = append(sizes, len(chunk.Segments))
+			if chunk.Offset != offset {
+				t.Errorf("%s: chunk offset = %d, want %d", tt.name, chunk.Offset, offset)
+			}
+			if chunk.Start != tt.segments[offset].StartTime || chunk.End != tt.segments[offset+len(chunk.Segments)-1].EndTime {
+				t.Errorf("%s: chunk at %d spans %v-%v, want its segments' times", tt.name, offset, chunk.Start, chunk.End)
+			}
+			offset += len(chunk.Segments)
+		}
+		if !reflect.DeepEqual(sizes, tt.sizes) {
+			t.Errorf("%s: chunk sizes = %v, want %v", tt.name, sizes, tt.sizes)
+		}
+	}
+}
+
+func TestRawSegments(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		maxTokens int
+		want      []models.TranscriptSegment
+	}{
+		{
+			name:      "one segment per line",
+			raw:       "Alice: Hello there\n\n  Bob: Hi  \n",
+			maxTokens: 100,
+			want:      []models.TranscriptSegment{{Speaker: "Alice", Text: "Hello there"}, {Speaker: "Bob", Text: "Hi"}},
+		},
+		{
+			name:      "line without a speaker",
+			raw:       "just some text",
+			maxTokens: 100,
+			want:      []models.TranscriptSegment{{Text: "just some text"}},
+		},
+		{
+			name:      "long line split between words",
+			raw:       "Alice: one two three",
+			maxTokens: 2,
+			want:      []models.TranscriptSegment{{Speaker: "Alice", Text: "one two"}, {Speaker: "Alice", Text: "three"}},
+		},
+		{
+			name:      "word longer than the budget kept whole",
+			raw:       "Alice: extraordinarily",
+			maxTokens: 1,
+			want:      []models.TranscriptSegment{{Speaker: "Alice", Text: "extraordinarily"}},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := rawSegments(tt.raw, tt.maxTokens); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: rawSegments() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWeightedChunkScores(t *testing.T) {
+	analyses := []chunkAnalysis{
+		{Scores: models.Scores{Communication: 40, Technical: 40, Confidence: 40, Structure: 40, Overall: 40}},
+		{Scores: models.Scores{Communication: 80, Technical: 80, Confidence: 80, Structure: 80, Overall: 80}},
+	}
+
+	tests := []struct {
+		name   string
+		chunks []transcriptChunk
+		want   float64
+	}{
+		{"weighted by duration", []transcriptChunk{{Start: 0, End: 10}, {Start: 10, End: 40}}, 70},
+		{"untimed chunks weigh the same", []transcriptChunk{{}, {}}, 60},
+	}
+
+	for _, tt := range tests {
+		got := weightedChunkScores(tt.chunks, analyses)
+		want := models.Scores{Communication: tt.want, Technical: tt.want, Confidence: tt.want, Structure: tt.want, Overall: tt.want}
+		if got != want {
+			t.Errorf("%s: weightedChunkScores() = %+v, want %+v", tt.name, got, want)
+		}
+	}
+}
+
+// newTestEvaluationService returns an evaluation service whose OpenAI API
+// answers every completion with reply
+func newTestEvaluationService(t *testing.T, reply string) *EvaluationService {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	clientConfig := openai.DefaultConfig("test-key")
+	clientConfig.BaseURL = server.URL + "/v1"
+	return &EvaluationService{
+		openaiClient: openai.NewClientWithConfig(clientConfig),
+		config:       &config.Config{OpenAIModel: "test-model"},
+	}
+}
+
+func TestAggregateChunksPicksHighlightsByID(t *testing.T) {
+	chunks := []transcriptChunk{{Start: 0, End: 10}, {Start: 10, End: 40}}
+	analyses := []chunkAnalysis{
+		{
+			Scores:     models.Scores{Overall: 40},
+			Highlights: []models.Highlight{{Timestamp: 2, SegmentIndexes: []int{1}, Type: "good", Comment: "clear intro"}},
+		},
+		{
+			Scores: models.Scores{Overall: 80},
+			Highlights: []models.Highlight{
+				{Timestamp: 12, SegmentIndexes: []int{5}, Type: "improve", Comment: "vague answer"},
+				{Timestamp: 30, SegmentIndexes: []int{8, 9}, Type: "good", Comment: "solid design"},
+			},
+		},
+	}
+
+	reply := `{"feedback":{"summary":"Good overall.","highlights":[` +
+		`{"id":2,"comment":"great system design"},{"id":0,"type":"improve"},{"id":3},{"id":-1}]}}`
+	s := newTestEvaluationService(t, reply)
+
+	evaluation, _, err := s.aggregateChunks(context.Background(), "test-model", chunks, analyses)
+	if err != nil {
+		t.Fatalf("aggregateChunks() error = %v", err)
+	}
+
+	want := []models.Highlight{
+		{Timestamp: 30, SegmentIndexes: []int{8, 9}, Type: "good", Comment: "great system design"},
+		{Timestamp: 2, SegmentIndexes: []int{1}, Type: "improve", Comment: "clear intro"},
+	}
+	if got := evaluation.Feedback.Highlights; !reflect.DeepEqual(got, want) {
+		t.Errorf("highlights = %+v, want %+v", got, want)
+	}
+
+	// Without scores from the model the duration-weighted mean is used
+	if overall := evaluation.Scores.Overall; overall != 70 {
+		t.Errorf("overall score = %v, want the weighted mean 70", overall)
+	}
+}
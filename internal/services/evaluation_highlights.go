@@ -0,0 +1,133 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
+const (
+	// Highlights further than this from any segment are rejected rather than snapped
+	highlightSnapTolerance = 15.0 // seconds
+	// Maximum length of the quote attached to a highlight
+	highlightQuoteMaxLength = 280
+)
+
+// verifyHighlights checks AI highlights against the transcript segments they
+// claim to refer to. segments is a contiguous slice of the transcript starting
+// at index offset; SegmentIndexes are indexes into the full transcript.
+//
+// A highlight that cites valid segments keeps them. One that doesn't is matched
+// by timestamp: first to the segment that contains it, otherwise to the nearest
+// segment start within highlightSnapTolerance. Untimed segments (split from raw
+// text) are never matched by timestamp. Anything else is dropped.
+// Verified highlights get their timestamps, speaker and quote from the segments.
+func verifyHighlights(highlights []models.Highlight, segments []models.TranscriptSegment, offset int) []models.Highlight {
+	if len(segments) == 0 {
+		return highlights
+	}
+
+	verified := make([]models.Highlight, 0, len(highlights))
+	for _, highlight := range highlights {
+		local := citedSegments(highlight.SegmentIndexes, len(segments), offset)
+
+		if len(local) == 0 {
+			index, ok := segmentAt(segments, highlight.Timestamp)
+			if !ok {
+				continue
+			}
+			local = []int{index}
+		}
+
+		first := segments[local[0]]
+		last := segments[local[len(local)-1]]
+
+		speakers := make([]string, 0, 1)
+		quotes := make([]string, 0, len(local))
+		highlight.SegmentIndexes = make([]int, len(local))
+		for i, index := range local {
+			segment := segments[index]
+			highlight.SegmentIndexes[i] = offset + index
+			quotes = append(quotes, segment.Text)
+			if !containsString(speakers, segment.Speaker) {
+				speakers = append(speakers, segment.Speaker)
+			}
+		}
+
+		highlight.Timestamp = first.StartTime
+		highlight.EndTimestamp = last.EndTime
+		highlight.Speaker = strings.Join(speakers, ", ")
+		highlight.Quote = truncate(strings.Join(quotes, " "), highlightQuoteMaxLength)
+
+		verified = append(verified, highlight)
+	}
+
+	return verified
+}
+
+// citedSegments converts cited transcript indexes to sorted, unique local
+// indexes, dropping any that fall outside the segment slice
+func citedSegments(indexes []int, count, offset int) []int {
+	seen := make(map[int]bool, len(indexes))
+	local := make([]int, 0, len(indexes))
+
+	for _, index := range indexes {
+		i := index - offset
+		if i < 0 || i >= count || seen[i] {
+			continue
+		}
+		seen[i] = true
+		local = append(local, i)
+	}
+
+	sort.Ints(local)
+	return local
+}
+
+// segmentAt finds the segment containing a timestamp, or the one starting
+// nearest to it within highlightSnapTolerance. Untimed segments are skipped.
+func segmentAt(segments []models.TranscriptSegment, timestamp float64) (int, bool) {
+	nearest := -1
+	nearestDistance := math.Inf(1)
+
+	for i, segment := range segments {
+		if !isTimed(segment) {
+			continue
+		}
+		if timestamp >= segment.StartTime && timestamp <= segment.EndTime {
+			return i, true
+		}
+
+		distance := math.Abs(segment.StartTime - timestamp)
+		if distance < nearestDistance {
+			nearest = i
+			nearestDistance = distance
+		}
+	}
+
+	if nearest == -1 || nearestDistance > highlightSnapTolerance {
+		return 0, false
+	}
+
+	return nearest, true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate shortens s to at most max runes, marking the cut with an ellipsis
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
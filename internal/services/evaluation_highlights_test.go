@@ -0,0 +1,82 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
+func TestCitedSegments(t *testing.T) {
+	tests := []struct {
+		name    string
+		indexes []int
+		count   int
+		offset  int
+		want    []int
+	}{
+		{"no offset", []int{2, 0}, 3, 0, []int{0, 2}},
+		{"offset into the transcript", []int{11, 10}, 3, 10, []int{0, 1}},
+		{"duplicates", []int{1, 1, 0, 1}, 3, 0, []int{0, 1}},
+		{"out of range", []int{-1, 3, 9, 13, 1}, 3, 10, []int{}},
+		{"mixed in and out of range", []int{9, 12, 13}, 3, 10, []int{2}},
+	}
+
+	for _, tt := range tests {
+		if got := citedSegments(tt.indexes, tt.count, tt.offset); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: citedSegments(%v) = %v, want %v", tt.name, tt.indexes, got, tt.want)
+		}
+	}
+}
+
+func TestSegmentAt(t *testing.T) {
+	timed := []models.TranscriptSegment{
+		{StartTime: 10, EndTime: 20},
+		{StartTime: 30, EndTime: 40},
+		{StartTime: 100, EndTime: 110},
+	}
+	untimed := []models.TranscriptSegment{{Text: "Alice: hi"}, {Text: "Bob: hello"}}
+
+	tests := []struct {
+		name      string
+		segments  []models.TranscriptSegment
+		timestamp float64
+		want      int
+		wantOK    bool
+	}{
+		{"inside a segment", timed, 35, 1, true},
+		{"on a segment's end", timed, 20, 0, true},
+		{"snapped to the nearest start", timed, 25, 1, true},
+		{"snapped within tolerance", timed, 100 - highlightSnapTolerance, 2, true},
+		{"beyond tolerance", timed, 100 - highlightSnapTolerance - 1, 0, false},
+		{"untimed segments at 0", untimed, 0, 0, false},
+		{"untimed segments", untimed, 5, 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := segmentAt(tt.segments, tt.timestamp)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("%s: segmentAt(%v) = %d, %v; want %d, %v", tt.name, tt.timestamp, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestVerifyHighlightsOnUntimedSegments(t *testing.T) {
+	segments := []models.TranscriptSegment{
+		{Speaker: "Alice", Text: "Let me think."},
+		{Speaker: "Bob", Text: "Take your time."},
+	}
+	highlights := []models.Highlight{
+		{Timestamp: 0, Comment: "uncited"},
+		{Timestamp: 3, Comment: "uncited, near the start"},
+		{SegmentIndexes: []int{1}, Comment: "cited"},
+	}
+
+	verified := verifyHighlights(highlights, segments, 0)
+	if len(verified) != 1 {
+		t.Fatalf("verifyHighlights() kept %+v, want only the cited highlight", verified)
+	}
+	if got := verified[0]; got.Comment != "cited" || got.Speaker != "Bob" || got.Quote != "Take your time." {
+		t.Errorf("verifyHighlights() = %+v, want Bob's line", got)
+	}
+}
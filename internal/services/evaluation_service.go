@@ -13,6 +13,8 @@ import (
 	"github.com/PRM710/Rankedterview-backend/internal/models"
 )
 
+const evaluatorSystemPrompt = "You are an expert interview evaluator. Analyze the interview transcript and provide detailed feedback."
+
 type EvaluationService struct {
 	openaiClient *openai.Client
 	config       *config.Config
@@ -28,53 +30,72 @@ func NewEvaluationService(cfg *config.Config) *EvaluationService {
 
 // EvaluateInterview evaluates an interview using AI
 func (s *EvaluationService) EvaluateInterview(ctx context.Context, transcript string) (*models.Evaluation, error) {
+	evaluation, _, err := s.evaluateText(ctx, transcript, s.config.OpenAIModel)
+	return evaluation, err
+}
+
+// evaluateText evaluates a transcript in a single prompt with the given model.
+// Token usage is returned even when the evaluation fails.
+func (s *EvaluationService) evaluateText(ctx context.Context, transcript, model string) (*models.Evaluation, models.TokenUsage, error) {
+	usage := models.TokenUsage{Model: model}
+
 	if transcript == "" {
-		return nil, errors.New("transcript is empty")
+		return nil, usage, errors.New("transcript is empty")
 	}
 
 	// Create evaluation prompt
 	prompt := s.buildEvaluationPrompt(transcript)
 
 	// Call OpenAI API
+	content, resp, err := s.complete(ctx, model, evaluatorSystemPrompt, prompt, s.config.OpenAIMaxTokens, 0.7)
+	addUsage(&usage, resp)
+	if err != nil {
+		return nil, usage, err
+	}
+
+	// Parse the AI response
+	evaluation, err := s.parseEvaluation(content)
+	if err != nil {
+		return nil, usage, err
+	}
+
+	// Add metadata
+	evaluation.ProcessedAt = time.Now()
+	applyUsage(evaluation, usage)
+
+	return evaluation, usage, nil
+}
+
+// complete sends a single system+user prompt to OpenAI and returns the reply text
+func (s *EvaluationService) complete(ctx context.Context, model, system, prompt string, maxTokens int, temperature float32) (string, openai.Usage, error) {
 	resp, err := s.openaiClient.CreateChatCompletion(
 		ctx,
 		openai.ChatCompletionRequest{
-			Model: s.config.OpenAIModel,
+			Model: model,
 			Messages: []openai.ChatCompletionMessage{
 				{
 					Role:    openai.ChatMessageRoleSystem,
-					Content: "You are an expert interview evaluator. Analyze the interview transcript and provide detailed feedback.",
+					Content: system,
 				},
 				{
 					Role:    openai.ChatMessageRoleUser,
 					Content: prompt,
 				},
 			},
-			MaxTokens:   s.config.OpenAIMaxTokens,
-			Temperature: 0.7,
+			MaxTokens:   maxTokens,
+			Temperature: temperature,
 		},
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("OpenAI API error: %w", err)
+		return "", openai.Usage{}, fmt.Errorf("OpenAI API error: %w", err)
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, errors.New("no response from OpenAI")
-	}
-
-	// Parse the AI response
-	evaluation, err := s.parseEvaluation(resp.Choices[0].Message.Content)
-	if err != nil {
-		return nil, err
+		return "", resp.Usage, errors.New("no response from OpenAI")
 	}
 
-	// Add metadata
-	evaluation.ProcessedAt = time.Now()
-	evaluation.AIModel = s.config.OpenAIModel
-	evaluation.TokensUsed = resp.Usage.TotalTokens
-
-	return evaluation, nil
+	return resp.Choices[0].Message.Content, resp.Usage, nil
 }
 
 // buildEvaluationPrompt creates the prompt for interview evaluation
@@ -95,7 +116,9 @@ Also provide:
 - 3-5 key strengths
 - 3-5 areas for improvement
 - Overall summary (2-3 sentences)
-- 2-3 timestamped highlights (good moments and areas to improve)
+- 2-3 timestamped highlights (good moments and areas to improve); if transcript
+  lines start with [#number @seconds], each highlight must list the numbers of the
+  line(s) it refers to in "segmentIndexes" and use the first line's seconds as its timestamp
 
 Format your response as JSON with this structure:
 {
@@ -111,8 +134,8 @@ Format your response as JSON with this structure:
     "improvements": ["improvement 1", "improvement 2", ...],
     "summary": "overall summary",
     "highlights": [
-      {"timestamp": 120.5, "type": "good", "comment": "excellent explanation"},
-      {"timestamp": 305.2, "type": "improve", "comment": "could be clearer"}
+      {"segmentIndexes": [12], "timestamp": 120.5, "type": "good", "comment": "excellent explanation"},
+      {"segmentIndexes": [31, 32], "timestamp": 305.2, "type": "improve", "comment": "could be clearer"}
     ]
   }
 }
@@ -121,32 +144,18 @@ Format your response as JSON with this structure:
 
 // parseEvaluation parses the AI response into an Evaluation model
 func (s *EvaluationService) parseEvaluation(aiResponse string) (*models.Evaluation, error) {
-	// Try to extract JSON from response (AI might add explanation text)
-	start := -1
-	end := -1
-	
-	for i, char := range aiResponse {
-		if char == '{' && start == -1 {
-			start = i
-		}
-		if char == '}' {
-			end = i + 1
-		}
-	}
-
-	if start == -1 || end == -1 {
-		return nil, errors.New("could not find JSON in AI response")
+	jsonStr, err := extractJSON(aiResponse)
+	if err != nil {
+		return nil, err
 	}
 
-	jsonStr := aiResponse[start:end]
-
 	// Parse JSON response
 	var result struct {
 		Scores   models.Scores   `json:"scores"`
 		Feedback models.Feedback `json:"feedback"`
 	}
 
-	err := json.Unmarshal([]byte(jsonStr), &result)
+	err = json.Unmarshal([]byte(jsonStr), &result)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse AI response: %w", err)
 	}
@@ -167,34 +176,63 @@ func (s *EvaluationService) parseEvaluation(aiResponse string) (*models.Evaluati
 	return evaluation, nil
 }
 
+// addUsage adds the usage reported for one OpenAI call to a running total
+func addUsage(total *models.TokenUsage, usage openai.Usage) {
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+}
+
+// applyUsage copies model and token counts onto an evaluation
+func applyUsage(evaluation *models.Evaluation, usage models.TokenUsage) {
+	evaluation.AIModel = usage.Model
+	evaluation.TokensUsed = usage.TotalTokens
+	evaluation.PromptTokens = usage.PromptTokens
+	evaluation.CompletionTokens = usage.CompletionTokens
+}
+
+// extractJSON pulls the outermost JSON object out of an AI response
+// (the model might add explanation text around it)
+func extractJSON(aiResponse string) (string, error) {
+	start := -1
+	end := -1
+
+	for i, char := range aiResponse {
+		if char == '{' && start == -1 {
+			start = i
+		}
+		if char == '}' {
+			end = i + 1
+		}
+	}
+
+	if start == -1 || end == -1 || end < start {
+		return "", errors.New("could not find JSON in AI response")
+	}
+
+	return aiResponse[start:end], nil
+}
+
 // GenerateQuickFeedback generates quick feedback without full evaluation
-func (s *EvaluationService) GenerateQuickFeedback(ctx context.Context, transcript string) (string, error) {
-	resp, err := s.openaiClient.CreateChatCompletion(
+func (s *EvaluationService) GenerateQuickFeedback(ctx context.Context, transcript, model string) (string, models.TokenUsage, error) {
+	if model == "" {
+		model = s.config.OpenAIModel
+	}
+	usage := models.TokenUsage{Model: model}
+
+	content, resp, err := s.complete(
 		ctx,
-		openai.ChatCompletionRequest{
-			Model: s.config.OpenAIModel,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: "You are an interview coach. Provide brief, actionable feedback.",
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: fmt.Sprintf("Provide 3 quick tips to improve based on this interview:\n\n%s", transcript),
-				},
-			},
-			MaxTokens:   500,
-			Temperature: 0.8,
-		},
+		model,
+		"You are an interview coach. Provide brief, actionable feedback.",
+		fmt.Sprintf("Give up to 3 very short tips (one line each) to improve, based on this interview:\n\n%s", transcript),
+		200,
+		0.8,
 	)
+	addUsage(&usage, resp)
 
 	if err != nil {
-		return "", err
-	}
-
-	if len(resp.Choices) == 0 {
-		return "", errors.New("no response from OpenAI")
+		return "", usage, err
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	return content, usage, nil
 }
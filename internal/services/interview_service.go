@@ -5,14 +5,49 @@ import (
 	"errors"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
 	"github.com/PRM710/Rankedterview-backend/internal/models"
 	"github.com/PRM710/Rankedterview-backend/internal/repositories"
 )
 
 var (
-	ErrInterviewNotFound = errors.New("interview not found")
+	ErrInterviewNotFound  = errors.New("interview not found")
+	ErrUnknownRecallEvent = errors.New("unknown Recall event type")
+)
+
+// Recall.ai webhook event types
+const (
+	RecallEventBotJoined        = "bot.in_call_not_recording"
+	RecallEventRecordingStarted = "bot.in_call_recording"
+	RecallEventProcessing       = "bot.call_ended"
+	RecallEventDone             = "bot.done"
+	RecallEventFailed           = "bot.fatal"
+	RecallEventTranscriptReady  = "transcript.done"
 )
 
+// recallEventStatuses maps bot events to the recording status they move to
+var recallEventStatuses = map[string]string{
+	RecallEventBotJoined:        models.RecordingStatusJoined,
+	RecallEventRecordingStarted: models.RecordingStatusRecording,
+	RecallEventProcessing:       models.RecordingStatusProcessing,
+	RecallEventDone:             models.RecordingStatusCompleted,
+	RecallEventFailed:           models.RecordingStatusFailed,
+}
+
+// An evaluation claim older than this is assumed to have died with its process
+const evaluationClaimTimeout = 15 * time.Minute
+
+// RecallEvent is a Recall.ai webhook delivery about an interview's bot
+type RecallEvent struct {
+	Type          string
+	BotID         string
+	VideoURL      string
+	AudioURL      string
+	TranscriptURL string
+	Error         string
+}
+
 type InterviewService struct {
 	interviewRepo *repositories.InterviewRepository
 	roomRepo      *repositories.RoomRepository
@@ -25,13 +60,17 @@ func NewInterviewService(interviewRepo *repositories.InterviewRepository, roomRe
 	}
 }
 
-// CreateInterview creates a new interview for a room
-func (s *InterviewService) CreateInterview(ctx context.Context, roomID string, participants []models.Participant) (*models.Interview, error) {
+// CreateInterview creates a new interview for a room played in the given
+// mode. It isn't recorded or ranked until every participant consents, and
+// practice interviews are never ranked.
+func (s *InterviewService) CreateInterview(ctx context.Context, roomID, mode string, participants []models.Participant) (*models.Interview, error) {
 	interview := &models.Interview{
-		RoomID:       roomID,
-		Participants: participants,
-		Status:       "in_progress",
-		StartedAt:    time.Now(),
+		RoomID:        roomID,
+		Mode:          mode,
+		Participants:  participants,
+		Status:        "in_progress",
+		StartedAt:     time.Now(),
+		ConsentStatus: models.ConsentStatusPending,
 	}
 
 	err := s.interviewRepo.Create(ctx, interview)
@@ -129,23 +168,93 @@ func (s *InterviewService) CountUserInterviews(ctx context.Context, userID strin
 	return s.interviewRepo.CountByUserID(ctx, userID)
 }
 
-// ProcessWebhook processes a webhook from Recall.ai
-func (s *InterviewService) ProcessWebhook(ctx context.Context, interviewID string, webhookData map[string]interface{}) error {
-	// Extract recording information from webhook
-	recording := models.Recording{
-		Status:        "completed",
-		VideoURL:      getStringOrEmpty(webhookData, "video_url"),
-		AudioURL:      getStringOrEmpty(webhookData, "audio_url"),
-		TranscriptURL: getStringOrEmpty(webhookData, "transcript_url"),
+// ApplyRecallEvent applies a Recall.ai event to the interview's recording and
+// reports whether anything changed. Recording statuses only move forward, so
+// duplicate and out-of-order deliveries leave the recording as it is.
+func (s *InterviewService) ApplyRecallEvent(ctx context.Context, interviewID string, event RecallEvent) (bool, error) {
+	if event.Type == RecallEventTranscriptReady {
+		return s.interviewRepo.MarkTranscriptReady(ctx, interviewID, event.TranscriptURL)
 	}
 
-	return s.UpdateRecording(ctx, interviewID, recording)
+	status, ok := recallEventStatuses[event.Type]
+	if !ok {
+		return false, ErrUnknownRecallEvent
+	}
+
+	changes := models.Recording{
+		Status:      status,
+		RecallBotID: event.BotID,
+	}
+
+	switch status {
+	case models.RecordingStatusCompleted:
+		changes.VideoURL = event.VideoURL
+		changes.AudioURL = event.AudioURL
+		changes.TranscriptURL = event.TranscriptURL
+	case models.RecordingStatusFailed:
+		changes.FailureReason = event.Error
+	}
+
+	return s.interviewRepo.TransitionRecording(ctx, interviewID, models.RecordingStatusesBefore(status), changes)
+}
+
+// AttachRecallBot records the Recall bot recording the interview
+func (s *InterviewService) AttachRecallBot(ctx context.Context, interviewID, botID string) error {
+	return s.interviewRepo.SetRecallBotID(ctx, interviewID, botID)
 }
 
-// Helper function
-func getStringOrEmpty(m map[string]interface{}, key string) string {
-	if val, ok := m[key].(string); ok {
-		return val
+// SetRecordingKeys records where the recording files were copied in the bucket
+func (s *InterviewService) SetRecordingKeys(ctx context.Context, interviewID string, keys models.Recording) error {
+	return s.interviewRepo.SetRecordingKeys(ctx, interviewID, keys)
+}
+
+// ListWithFileStoredBefore lists interviews whose given recording file was
+// stored before the given time, oldest first, leaving out those in skip
+func (s *InterviewService) ListWithFileStoredBefore(ctx context.Context, file string, before time.Time, skip []primitive.ObjectID, limit int64) ([]*models.Interview, error) {
+	return s.interviewRepo.FindWithFileStoredBefore(ctx, file, before, skip, limit)
+}
+
+// ClearRecordingFiles forgets the given recording files of an interview
+func (s *InterviewService) ClearRecordingFiles(ctx context.Context, interviewID string, files []string) error {
+	return s.interviewRepo.ClearRecordingFiles(ctx, interviewID, files)
+}
+
+// RecordConsent saves a participant's answer to the recording consent
+// request, reporting whether it was saved. Answers are final, and once the
+// interview's consent is decided later answers are ignored.
+func (s *InterviewService) RecordConsent(ctx context.Context, interviewID, userID string, consent models.RecordingConsent) (bool, error) {
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return false, err
 	}
-	return ""
+	return s.interviewRepo.RecordConsent(ctx, interviewID, userObjectID, consent)
+}
+
+// DecideConsent moves the interview's pending consent to granted or
+// declined, reporting whether it was still pending
+func (s *InterviewService) DecideConsent(ctx context.Context, interviewID, status string) (bool, error) {
+	return s.interviewRepo.SetConsentStatus(ctx, interviewID, status)
+}
+
+// AbandonInterview records that a participant left the interview before the
+// call ended, reporting whether it was still running
+func (s *InterviewService) AbandonInterview(ctx context.Context, interviewID, userID, status string) (bool, error) {
+	return s.interviewRepo.Abandon(ctx, interviewID, userID, status)
+}
+
+// ClaimEvaluation reports whether the caller may evaluate the interview. Only
+// one caller gets the claim until it completes or fails the evaluation.
+func (s *InterviewService) ClaimEvaluation(ctx context.Context, interviewID string) (bool, error) {
+	return s.interviewRepo.ClaimEvaluation(ctx, interviewID, evaluationClaimTimeout)
+}
+
+// CompleteEvaluation saves a claimed evaluation, reporting whether the claim
+// was still held (and so whether rankings should be updated)
+func (s *InterviewService) CompleteEvaluation(ctx context.Context, interviewID string, evaluation models.Evaluation) (bool, error) {
+	return s.interviewRepo.CompleteEvaluation(ctx, interviewID, evaluation)
+}
+
+// FailEvaluation releases a claimed evaluation so it can be retried
+func (s *InterviewService) FailEvaluation(ctx context.Context, interviewID string) error {
+	return s.interviewRepo.FailEvaluation(ctx, interviewID)
 }
@@ -22,19 +22,26 @@ var (
 )
 
 type MatchmakingService struct {
-	redis    *database.RedisClient
-	roomRepo *repositories.RoomRepository
+	redis      *database.RedisClient
+	roomRepo   *repositories.RoomRepository
+	moderation *ModerationService
 }
 
-func NewMatchmakingService(redis *database.RedisClient, roomRepo *repositories.RoomRepository) *MatchmakingService {
+func NewMatchmakingService(redis *database.RedisClient, roomRepo *repositories.RoomRepository, moderation *ModerationService) *MatchmakingService {
 	return &MatchmakingService{
-		redis:    redis,
-		roomRepo: roomRepo,
+		redis:      redis,
+		roomRepo:   roomRepo,
+		moderation: moderation,
 	}
 }
 
-// JoinQueue adds a user to the matchmaking queue
-func (s *MatchmakingService) JoinQueue(ctx context.Context, userID string, skillLevel int) error {
+// JoinQueue adds a user to the matchmaking queue for the given room mode
+// ("ranked" or "practice"); users are only matched with others in the same mode
+func (s *MatchmakingService) JoinQueue(ctx context.Context, userID string, skillLevel int, mode string) error {
+	if err := s.moderation.CheckNotBanned(ctx, userID); err != nil {
+		return err
+	}
+
 	// Check if user is already in queue
 	inQueue, err := s.IsInQueue(ctx, userID)
 	if err != nil {
@@ -60,6 +67,7 @@ func (s *MatchmakingService) JoinQueue(ctx context.Context, userID string, skill
 	metaKey := fmt.Sprintf("matchmaking:user:%s", userID)
 	err = s.redis.HSet(ctx, metaKey,
 		"skillLevel", skillLevel,
+		"mode", mode,
 		"joinedAt", time.Now().Unix(),
 	)
 	if err != nil {
@@ -127,6 +135,16 @@ func (s *MatchmakingService) FindMatch(ctx context.Context, userID string) (stri
 		return "", "", err
 	}
 
+	// Only match users queued for the same mode
+	mode := s.queueMode(ctx, userID)
+	sameMode := members[:0]
+	for _, member := range members {
+		if s.queueMode(ctx, member) == mode {
+			sameMode = append(sameMode, member)
+		}
+	}
+	members = sameMode
+
 	if len(members) < 2 {
 		return "", "", ErrNoMatchFound
 	}
@@ -149,7 +167,7 @@ func (s *MatchmakingService) FindMatch(ctx context.Context, userID string) (stri
 	}
 
 	// Create a room for the matched users
-	roomID, err := s.CreateRoomForMatch(ctx, user1, user2)
+	roomID, err := s.CreateRoomForMatch(ctx, user1, user2, mode)
 	if err != nil {
 		return "", "", err
 	}
@@ -161,8 +179,18 @@ func (s *MatchmakingService) FindMatch(ctx context.Context, userID string) (stri
 	return roomID, user2, nil
 }
 
-// CreateRoomForMatch creates a room for matched users
-func (s *MatchmakingService) CreateRoomForMatch(ctx context.Context, user1ID, user2ID string) (string, error) {
+// queueMode returns the mode a queued user asked for, defaulting to ranked
+func (s *MatchmakingService) queueMode(ctx context.Context, userID string) string {
+	mode, err := s.redis.HGet(ctx, fmt.Sprintf("matchmaking:user:%s", userID), "mode")
+	if err != nil || mode == "" {
+		return models.RoomModeRanked
+	}
+	return mode
+}
+
+// CreateRoomForMatch creates a room for matched users. The first user is the
+// interviewee and the second the interviewer.
+func (s *MatchmakingService) CreateRoomForMatch(ctx context.Context, user1ID, user2ID, mode string) (string, error) {
 	// Generate unique room ID
 	roomID, err := generateRoomID()
 	if err != nil {
@@ -189,6 +217,7 @@ func (s *MatchmakingService) CreateRoomForMatch(ctx context.Context, user1ID, us
 			Topic:      "Technical Interview",
 			Difficulty: "medium",
 			Type:       "technical",
+			Mode:       mode,
 		},
 	}
 
@@ -203,6 +232,9 @@ func (s *MatchmakingService) CreateRoomForMatch(ctx context.Context, user1ID, us
 		"status", "waiting",
 		"user1", user1ID,
 		"user2", user2ID,
+		"interviewee", user1ID,
+		"interviewer", user2ID,
+		"mode", mode,
 		"createdAt", time.Now().Unix(),
 	)
 	s.redis.Expire(ctx, roomStateKey, 2*time.Hour)
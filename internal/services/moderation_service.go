@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/PRM710/Rankedterview-backend/internal/database"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/repositories"
+	"github.com/PRM710/Rankedterview-backend/internal/websocket"
+)
+
+var (
+	ErrAccountBanned = errors.New("account is banned")
+	ErrNoActiveBan   = errors.New("user has no active ban")
+)
+
+const (
+	// How long "not banned" is cached; bans themselves are cached until they expire
+	banCacheTTL = time.Minute
+	// Cache marker for users without an active ban
+	banCacheNone = "none"
+)
+
+// BanError is returned when a banned user tries to do something a ban blocks.
+// errors.Is(err, ErrAccountBanned) matches it.
+type BanError struct {
+	Ban *models.Ban
+}
+
+func (e *BanError) Error() string {
+	return ErrAccountBanned.Error() + ": " + e.Ban.Reason
+}
+
+func (e *BanError) Is(target error) bool {
+	return target == ErrAccountBanned
+}
+
+type ModerationService struct {
+	banRepo *repositories.BanRepository
+	redis   *database.RedisClient
+	hub     *websocket.Hub
+}
+
+func NewModerationService(banRepo *repositories.BanRepository, redis *database.RedisClient, hub *websocket.Hub) *ModerationService {
+	return &ModerationService{
+		banRepo: banRepo,
+		redis:   redis,
+		hub:     hub,
+	}
+}
+
+// BanUser bans a user for duration (zero means permanently) and disconnects
+// their live WebSocket session
+func (s *ModerationService) BanUser(ctx context.Context, userID, issuedBy, reason string, duration time.Duration) (*models.Ban, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+	issuerObjID, err := primitive.ObjectIDFromHex(issuedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	ban := &models.Ban{
+		UserID:   userObjID,
+		Reason:   reason,
+		IssuedBy: issuerObjID,
+	}
+	if duration > 0 {
+		expiresAt := time.Now().Add(duration)
+		ban.ExpiresAt = &expiresAt
+	}
+
+	if err := s.banRepo.Create(ctx, ban); err != nil {
+		return nil, err
+	}
+
+	// Drop the cache so the new ban (or a longer existing one) is picked up
+	s.redis.Del(ctx, banCacheKey(userID))
+
+	s.hub.DisconnectUser(userID, websocket.CloseAccountBanned, "account banned")
+	log.Printf("User %s banned by %s: %s", userID, issuedBy, reason)
+
+	return ban, nil
+}
+
+// LiftBan lifts all of a user's active bans
+func (s *ModerationService) LiftBan(ctx context.Context, userID, liftedBy string) error {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	lifterObjID, err := primitive.ObjectIDFromHex(liftedBy)
+	if err != nil {
+		return err
+	}
+
+	lifted, err := s.banRepo.LiftActive(ctx, userObjID, lifterObjID)
+	if err != nil {
+		return err
+	}
+
+	s.redis.Del(ctx, banCacheKey(userID))
+
+	if lifted == 0 {
+		return ErrNoActiveBan
+	}
+	return nil
+}
+
+// ListBans lists a user's ban history
+func (s *ModerationService) ListBans(ctx context.Context, userID string) ([]*models.Ban, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.banRepo.ListByUser(ctx, userObjID)
+}
+
+// ActiveBan returns the user's active ban, or nil if they aren't banned.
+// Lookups are cached in Redis since this runs on every authenticated request.
+func (s *ModerationService) ActiveBan(ctx context.Context, userID string) (*models.Ban, error) {
+	key := banCacheKey(userID)
+
+	if cached, err := s.redis.Get(ctx, key); err == nil {
+		if cached == banCacheNone {
+			return nil, nil
+		}
+		var ban models.Ban
+		if err := json.Unmarshal([]byte(cached), &ban); err == nil && ban.IsActive() {
+			return &ban, nil
+		}
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ban, err := s.banRepo.FindActiveByUser(ctx, userObjID)
+	if err == mongo.ErrNoDocuments {
+		s.redis.Set(ctx, key, banCacheNone, banCacheTTL)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache until it expires (permanent bans are re-checked daily)
+	ttl := 24 * time.Hour
+	if ban.ExpiresAt != nil {
+		ttl = time.Until(*ban.ExpiresAt)
+	}
+	if encoded, err := json.Marshal(ban); err == nil && ttl > 0 {
+		s.redis.Set(ctx, key, encoded, ttl)
+	}
+
+	return ban, nil
+}
+
+// CheckNotBanned returns a *BanError if the user has an active ban
+func (s *ModerationService) CheckNotBanned(ctx context.Context, userID string) error {
+	ban, err := s.ActiveBan(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if ban != nil {
+		return &BanError{Ban: ban}
+	}
+	return nil
+}
+
+func banCacheKey(userID string) string {
+	return "ban:" + userID
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// oauthProfile is the identity a provider vouches for after a code exchange
+type oauthProfile struct {
+	ID            string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Avatar        string
+}
+
+// exchangeCode trades an authorization code for an access token at the
+// provider's token endpoint
+func (s *AuthService) exchangeCode(ctx context.Context, tokenURL, clientID, clientSecret, redirectURI, code string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"redirect_uri":  {redirectURI},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := s.doJSON(req, &result); err != nil {
+		return "", fmt.Errorf("token exchange failed: %w", err)
+	}
+
+	// GitHub reports errors with a 200 status
+	if result.Error != "" {
+		return "", fmt.Errorf("token exchange failed: %s: %s", result.Error, result.ErrorDescription)
+	}
+	if result.AccessToken == "" {
+		return "", errors.New("token exchange failed: no access token returned")
+	}
+
+	return result.AccessToken, nil
+}
+
+// fetchGoogleProfile loads the OpenID Connect userinfo for an access token
+func (s *AuthService) fetchGoogleProfile(ctx context.Context, accessToken string) (*oauthProfile, error) {
+	req, err := s.authorizedRequest(ctx, s.config.GoogleUserInfoURL, "Bearer "+accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var info struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+		Picture       string `json:"picture"`
+	}
+	if err := s.doJSON(req, &info); err != nil {
+		return nil, fmt.Errorf("failed to fetch Google profile: %w", err)
+	}
+
+	if info.Sub == "" {
+		return nil, errors.New("google profile has no subject")
+	}
+
+	return &oauthProfile{
+		ID:            info.Sub,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified,
+		Name:          info.Name,
+		Avatar:        info.Picture,
+	}, nil
+}
+
+// fetchGitHubProfile loads the GitHub user and their primary verified email
+func (s *AuthService) fetchGitHubProfile(ctx context.Context, accessToken string) (*oauthProfile, error) {
+	apiURL := strings.TrimRight(s.config.GitHubAPIURL, "/")
+
+	req, err := s.authorizedRequest(ctx, apiURL+"/user", "Bearer "+accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var user struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := s.doJSON(req, &user); err != nil {
+		return nil, fmt.Errorf("failed to fetch GitHub profile: %w", err)
+	}
+
+	if user.ID == 0 {
+		return nil, errors.New("github profile has no id")
+	}
+
+	// The public profile email may be empty or unverified, so ask for the
+	// account's emails explicitly
+	req, err = s.authorizedRequest(ctx, apiURL+"/user/emails", "Bearer "+accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := s.doJSON(req, &emails); err != nil {
+		return nil, fmt.Errorf("failed to fetch GitHub emails: %w", err)
+	}
+
+	profile := &oauthProfile{
+		ID:     strconv.FormatInt(user.ID, 10),
+		Name:   user.Name,
+		Avatar: user.AvatarURL,
+	}
+	if profile.Name == "" {
+		profile.Name = user.Login
+	}
+
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			profile.Email = email.Email
+			profile.EmailVerified = true
+			break
+		}
+	}
+
+	return profile, nil
+}
+
+// authorizedRequest builds a GET request with an Authorization header
+func (s *AuthService) authorizedRequest(ctx context.Context, endpoint, authorization string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+// doJSON performs a request and decodes a successful JSON response into v
+func (s *AuthService) doJSON(req *http.Request, v interface{}) error {
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("provider returned status %d", resp.StatusCode)
+	}
+
+	return json.Unmarshal(body, v)
+}
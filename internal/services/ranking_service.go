@@ -2,9 +2,11 @@ package services
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"github.com/redis/go-redis/v9"
 
 	"github.com/PRM710/Rankedterview-backend/internal/database"
@@ -12,6 +14,24 @@ import (
 	"github.com/PRM710/Rankedterview-backend/internal/repositories"
 )
 
+var (
+	ErrRankingNotFound = errors.New("ranking not found")
+	ErrInvalidCategory = errors.New("invalid ranking category")
+)
+
+// RankingUpdate summarizes how an interview moved a user's overall ranking
+type RankingUpdate struct {
+	PreviousElo  int `json:"previousElo"`
+	NewElo       int `json:"newElo"`
+	PreviousRank int `json:"previousRank"`
+	NewRank      int `json:"newRank"`
+}
+
+// EloChange returns the overall Elo delta
+func (u *RankingUpdate) EloChange() int {
+	return u.NewElo - u.PreviousElo
+}
+
 type RankingService struct {
 	rankingRepo *repositories.RankingRepository
 	userRepo    *repositories.UserRepository
@@ -25,17 +45,25 @@ func NewRankingService(rankingRepo *repositories.RankingRepository, redis *datab
 	}
 }
 
-// UpdateUserRanking updates a user's ranking after an interview
-func (s *RankingService) UpdateUserRanking(ctx context.Context, userID string, scores models.Scores) error {
+// UpdateUserRanking updates a user's ranking after an interview and reports
+// the resulting change to the overall ranking
+func (s *RankingService) UpdateUserRanking(ctx context.Context, userID string, scores models.Scores) (*RankingUpdate, error) {
 	userObjID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Snapshot the overall ranking before it changes (new users start at 1000, unranked)
+	update := &RankingUpdate{PreviousElo: 1000}
+	if before, err := s.rankingRepo.FindByUserID(ctx, userID, "overall", "all_time"); err == nil {
+		update.PreviousElo = before.Elo
+		update.PreviousRank = before.Rank
 	}
 
 	// Update overall ranking
 	err = s.updateRanking(ctx, userObjID, "overall", "all_time", scores.Overall)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Update category rankings
@@ -57,7 +85,14 @@ func (s *RankingService) UpdateUserRanking(ctx context.Context, userID string, s
 	// Recalculate ranks
 	s.RecalculateRanks(ctx, "overall", "all_time")
 
-	return nil
+	after, err := s.rankingRepo.FindByUserID(ctx, userID, "overall", "all_time")
+	if err != nil {
+		return nil, err
+	}
+	update.NewElo = after.Elo
+	update.NewRank = after.Rank
+
+	return update, nil
 }
 
 // updateRanking updates a single ranking entry
@@ -163,6 +198,45 @@ func (s *RankingService) RecalculateRanks(ctx context.Context, category, period
 	return nil
 }
 
+// AdjustRanking manually corrects a user's all-time Elo in a category and
+// records who did it and why in the ranking history
+func (s *RankingService) AdjustRanking(ctx context.Context, userID, category string, elo int, reason, adjustedBy string) (*models.Ranking, error) {
+	if !models.IsValidRankingCategory(category) {
+		return nil, ErrInvalidCategory
+	}
+	if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+		return nil, ErrRankingNotFound
+	}
+
+	ranking, err := s.rankingRepo.FindByUserID(ctx, userID, category, "all_time")
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRankingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ranking.Elo = elo
+	ranking.History = append(ranking.History, models.RankingHistory{
+		Date:       time.Now(),
+		Rank:       ranking.Rank,
+		Score:      ranking.Score,
+		Elo:        elo,
+		Reason:     reason,
+		AdjustedBy: adjustedBy,
+	})
+
+	if err := s.rankingRepo.Update(ctx, ranking); err != nil {
+		return nil, err
+	}
+
+	if err := s.RecalculateRanks(ctx, category, "all_time"); err != nil {
+		return nil, err
+	}
+
+	return s.rankingRepo.FindByUserID(ctx, userID, category, "all_time")
+}
+
 // Helper: Get leaderboard from Redis cache
 func (s *RankingService) getLeaderboardFromCache(ctx context.Context, key string, limit int64) ([]*models.Ranking, error) {
 	// This is a simplified version - in production you'd serialize/deserialize properly
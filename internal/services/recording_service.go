@@ -0,0 +1,558 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/PRM710/Rankedterview-backend/internal/config"
+	"github.com/PRM710/Rankedterview-backend/internal/database"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/recall"
+	"github.com/PRM710/Rankedterview-backend/internal/storage"
+	"github.com/PRM710/Rankedterview-backend/internal/utils"
+	"github.com/PRM710/Rankedterview-backend/internal/websocket"
+)
+
+const (
+	// How long Recall calls made for a hub event may take, retries included
+	recordingCallTimeout = time.Minute
+
+	// Lifetime of the presigned URLs handed to participants
+	defaultRecordingURLTTL = 15 * time.Minute
+)
+
+var (
+	// ErrRecordingNotStored is returned when none of an interview's recording
+	// files have been copied to the bucket yet
+	ErrRecordingNotStored = errors.New("recording not stored yet")
+
+	// ErrConsentPolicyStale is returned when consent was given to a recording
+	// policy that has since changed
+	ErrConsentPolicyStale = errors.New("consent given to an outdated recording policy")
+)
+
+// RecordingURLs are presigned download URLs for an interview's recording
+// files; files that haven't been stored have no URL
+type RecordingURLs struct {
+	Status        string
+	VideoURL      string
+	AudioURL      string
+	TranscriptURL string
+	ExpiresAt     time.Time
+}
+
+// RecordingService asks both users of a call for consent to record once they
+// have accepted the match, sends a Recall bot into the call once they both
+// consent and pulls it out again when the call ends, or when a user leaves
+// it for good. It is the hub's RoomObserver. Finished recordings are copied
+// into our bucket and served to participants through presigned URLs.
+type RecordingService struct {
+	recall           *recall.Client
+	storage          *storage.Client
+	interviewService *InterviewService
+	roomService      *RoomService
+	rankingService   *RankingService
+	hub              *websocket.Hub
+	redis            *database.RedisClient
+	config           *config.Config
+}
+
+func NewRecordingService(
+	recallClient *recall.Client,
+	storageClient *storage.Client,
+	interviewService *InterviewService,
+	roomService *RoomService,
+	rankingService *RankingService,
+	hub *websocket.Hub,
+	redis *database.RedisClient,
+	cfg *config.Config,
+) *RecordingService {
+	return &RecordingService{
+		recall:           recallClient,
+		storage:          storageClient,
+		interviewService: interviewService,
+		roomService:      roomService,
+		rankingService:   rankingService,
+		hub:              hub,
+		redis:            redis,
+		config:           cfg,
+	}
+}
+
+// RoomReady starts the interview of a room whose users both accepted the
+// match and asks them for consent to record it
+func (s *RecordingService) RoomReady(roomID string, userIDs []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordingCallTimeout)
+	defer cancel()
+
+	if err := s.StartInterview(ctx, roomID, userIDs); err != nil {
+		log.Printf("Interview of room %s not started: %v", roomID, err)
+	}
+}
+
+// RecordingConsent records a participant's answer to the consent request
+func (s *RecordingService) RecordingConsent(roomID, userID string, granted bool, policyVersion string) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordingCallTimeout)
+	defer cancel()
+
+	err := s.RecordConsent(ctx, roomID, userID, granted, policyVersion)
+	if err == ErrConsentPolicyStale {
+		// Ask again with the policy in force
+		event := websocket.NewErrorEvent(websocket.ErrorConsentPolicyStale, "The recording policy has changed; please review it again")
+		event.Event = websocket.EventRecordingConsent
+		event.RoomID = roomID
+		s.hub.BroadcastToUser(userID, event)
+
+		if interview, err := s.interviewService.GetInterviewByRoomID(ctx, roomID); err == nil {
+			s.hub.BroadcastToUser(userID, s.consentRequest(interview))
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Recording consent of %s in room %s not recorded: %v", userID, roomID, err)
+	}
+}
+
+// CallEnded stops recording a room when one of its participants ends the call
+func (s *RecordingService) CallEnded(roomID, userID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordingCallTimeout)
+	defer cancel()
+
+	if err := s.StopRecording(ctx, roomID, userID); err != nil {
+		log.Printf("Recording of room %s not stopped: %v", roomID, err)
+	}
+}
+
+// ParticipantLeft ends the interview of a room a participant dropped out of
+// and didn't reconnect to in time
+func (s *RecordingService) ParticipantLeft(roomID, userID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordingCallTimeout)
+	defer cancel()
+
+	if err := s.AbandonInterview(ctx, roomID, userID); err != nil {
+		log.Printf("Interview of room %s not abandoned: %v", roomID, err)
+	}
+}
+
+// StartInterview marks the room active, creates its interview and asks both
+// users for consent to record it. The call goes ahead whatever they answer.
+func (s *RecordingService) StartInterview(ctx context.Context, roomID string, userIDs []string) (err error) {
+	// Both users' accept events can get here; only the first one starts the interview
+	startedKey := "recording:" + roomID + ":started"
+	first, err := s.redis.Client.SetNX(ctx, startedKey, 1, 24*time.Hour).Result()
+	if err != nil {
+		return err
+	}
+	if !first {
+		return nil
+	}
+
+	// Let a later accept try again if this one fails
+	defer func() {
+		if err != nil {
+			if delErr := s.redis.Del(context.Background(), startedKey); delErr != nil {
+				log.Printf("Failed to release start of room %s: %v", roomID, delErr)
+			}
+		}
+	}()
+
+	room, err := s.roomService.GetRoom(ctx, roomID)
+	if err != nil {
+		return ErrRoomNotFound
+	}
+	if room.Status == "ended" {
+		return ErrRoomNotActive
+	}
+
+	if err := s.roomService.StartRoom(ctx, roomID); err != nil {
+		return err
+	}
+
+	interview, err := s.interviewService.GetInterviewByRoomID(ctx, roomID)
+	if err == mongo.ErrNoDocuments {
+		interview, err = s.interviewService.CreateInterview(ctx, roomID, room.Metadata.Mode, interviewParticipants(userIDs))
+	}
+	if err != nil {
+		return err
+	}
+
+	s.notifyParticipants(interview, s.consentRequest(interview))
+	return nil
+}
+
+// consentRequest asks for consent to record the interview under the current policy
+func (s *RecordingService) consentRequest(interview *models.Interview) websocket.RecordingConsentRequestEvent {
+	return websocket.RecordingConsentRequestEvent{
+		Type:          websocket.EventRecordingConsentRequest,
+		RoomID:        interview.RoomID,
+		InterviewID:   interview.ID.Hex(),
+		PolicyVersion: s.config.RecordingPolicyVersion,
+		PolicyURL:     s.config.RecordingPolicyURL,
+	}
+}
+
+// RecordConsent saves a participant's answer to the consent request. Once
+// everyone has consented to the current policy a Recall bot joins the call;
+// if anyone declines, the room runs unrecorded and unranked.
+func (s *RecordingService) RecordConsent(ctx context.Context, roomID, userID string, granted bool, policyVersion string) error {
+	interview, err := s.interviewService.GetInterviewByRoomID(ctx, roomID)
+	if err != nil {
+		return ErrInterviewNotFound
+	}
+
+	if !isInterviewParticipant(interview, userID) {
+		return ErrNotParticipant
+	}
+
+	// Declining needs no policy; consent only counts for the policy in force
+	if granted && policyVersion != s.config.RecordingPolicyVersion {
+		return ErrConsentPolicyStale
+	}
+
+	interviewID := interview.ID.Hex()
+	saved, err := s.interviewService.RecordConsent(ctx, interviewID, userID, models.RecordingConsent{
+		Granted:       granted,
+		PolicyVersion: policyVersion,
+		AnsweredAt:    time.Now(),
+	})
+	if err != nil || !saved {
+		return err
+	}
+
+	if !granted {
+		declined, err := s.interviewService.DecideConsent(ctx, interviewID, models.ConsentStatusDeclined)
+		if err != nil || !declined {
+			return err
+		}
+
+		log.Printf("Recording of room %s declined; the interview won't be recorded or ranked", roomID)
+		s.notifyParticipants(interview, websocket.RecordingStatusEvent{
+			Type:   websocket.EventRecordingDeclined,
+			RoomID: roomID,
+		})
+		return nil
+	}
+
+	// Wait for everyone else's answer
+	interview, err = s.interviewService.GetInterview(ctx, interviewID)
+	if err != nil {
+		return err
+	}
+	for _, participant := range interview.Participants {
+		if participant.Consent == nil || !participant.Consent.Granted {
+			return nil
+		}
+	}
+
+	consented, err := s.interviewService.DecideConsent(ctx, interviewID, models.ConsentStatusGranted)
+	if err != nil || !consented {
+		return err
+	}
+
+	s.notifyParticipants(interview, websocket.RecordingStatusEvent{
+		Type:   websocket.EventRecordingConsented,
+		RoomID: roomID,
+	})
+	return s.startBot(ctx, roomID, interviewID)
+}
+
+// startBot sends a Recall bot into the call. If the bot can't be created the
+// call goes ahead and the recording is marked failed.
+func (s *RecordingService) startBot(ctx context.Context, roomID, interviewID string) error {
+	if !s.recall.Configured() {
+		log.Printf("Recall is not configured; room %s won't be recorded", roomID)
+		return nil
+	}
+
+	bot, err := s.recall.CreateBot(ctx, recall.CreateBotRequest{
+		MeetingURL: strings.ReplaceAll(s.config.RecallMeetingURL, "{roomId}", roomID),
+		BotName:    s.config.RecallBotName,
+		Metadata: map[string]string{
+			"interview_id": interviewID,
+			"room_id":      roomID,
+		},
+	})
+	if err != nil {
+		s.interviewService.ApplyRecallEvent(ctx, interviewID, RecallEvent{
+			Type:  RecallEventFailed,
+			Error: "bot could not be created: " + err.Error(),
+		})
+		return err
+	}
+
+	log.Printf("Recall bot %s recording room %s (interview %s)", bot.ID, roomID, interviewID)
+	return s.interviewService.AttachRecallBot(ctx, interviewID, bot.ID)
+}
+
+// StopRecording tells the room's bot to leave the call and completes the
+// interview. Bots that are already leaving, or failed, are left alone.
+func (s *RecordingService) StopRecording(ctx context.Context, roomID, userID string) error {
+	interview, err := s.interviewService.GetInterviewByRoomID(ctx, roomID)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !isInterviewParticipant(interview, userID) {
+		return ErrNotParticipant
+	}
+
+	return s.stopInterview(ctx, interview)
+}
+
+// TerminateRoom force-ends a room: its interview is completed, its bot
+// leaves the call and its participants' connections leave the room, so
+// dropping out afterwards doesn't forfeit the interview
+func (s *RecordingService) TerminateRoom(ctx context.Context, roomID string) (*models.Room, error) {
+	room, err := s.roomService.TerminateRoom(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.hub.EndRoom(roomID)
+
+	interview, err := s.interviewService.GetInterviewByRoomID(ctx, roomID)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Interview of terminated room %s not completed: %v", roomID, err)
+		}
+		return room, nil
+	}
+	if err := s.stopInterview(ctx, interview); err != nil {
+		log.Printf("Recording of terminated room %s not stopped: %v", roomID, err)
+	}
+
+	return room, nil
+}
+
+// stopInterview completes an interview and tells its bot to leave the call
+func (s *RecordingService) stopInterview(ctx context.Context, interview *models.Interview) error {
+	// Interviews a participant left keep their status
+	if interview.Status != "completed" && interview.AbandonedBy == "" {
+		if err := s.interviewService.CompleteInterview(ctx, interview.ID.Hex()); err != nil {
+			return err
+		}
+	}
+
+	botID := interview.Recording.RecallBotID
+	switch interview.Recording.Status {
+	case models.RecordingStatusProcessing, models.RecordingStatusCompleted, models.RecordingStatusFailed:
+		return nil
+	}
+	if botID == "" || !s.recall.Configured() {
+		return nil
+	}
+
+	// The bot.call_ended webhook moves the recording on to processing
+	return s.recall.LeaveCall(ctx, botID)
+}
+
+// AbandonInterview ends the room's call after a participant left it. Leaving
+// an interview that would have been ranked (a ranked room both users
+// consented to record) forfeits it: the leaver is ranked as if they scored
+// zero. Any other interview, such as a practice one, is just abandoned. The
+// interview is never evaluated either way. Rooms that already ended are left
+// alone.
+func (s *RecordingService) AbandonInterview(ctx context.Context, roomID, userID string) error {
+	// A room that was already ended or terminated can't be left
+	if room, err := s.roomService.GetRoom(ctx, roomID); err == nil && room.Status == "ended" {
+		return nil
+	}
+
+	interview, err := s.interviewService.GetInterviewByRoomID(ctx, roomID)
+	if err == mongo.ErrNoDocuments {
+		// Left before the interview started
+		return s.roomService.EndRoom(ctx, roomID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !isInterviewParticipant(interview, userID) {
+		return ErrNotParticipant
+	}
+
+	status := models.InterviewStatusAbandoned
+	if interview.IsRanked() {
+		status = models.InterviewStatusForfeited
+	}
+
+	interviewID := interview.ID.Hex()
+	abandoned, err := s.interviewService.AbandonInterview(ctx, interviewID, userID, status)
+	if err != nil || !abandoned {
+		return err
+	}
+	log.Printf("User %s left room %s; interview %s %s", userID, roomID, interviewID, status)
+
+	if err := s.roomService.EndRoom(ctx, roomID); err != nil {
+		log.Printf("Room %s not ended: %v", roomID, err)
+	}
+
+	if status == models.InterviewStatusForfeited {
+		if _, err := s.rankingService.UpdateUserRanking(ctx, userID, models.Scores{}); err != nil {
+			log.Printf("Forfeit of %s in interview %s not ranked: %v", userID, interviewID, err)
+		}
+	}
+
+	botID := interview.Recording.RecallBotID
+	switch interview.Recording.Status {
+	case models.RecordingStatusProcessing, models.RecordingStatusCompleted, models.RecordingStatusFailed:
+		return nil
+	}
+	if botID == "" || !s.recall.Configured() {
+		return nil
+	}
+	return s.recall.LeaveCall(ctx, botID)
+}
+
+// StoreRecording copies the interview's recording files from Recall into the
+// bucket. Files already copied are skipped, so it is safe to call for every
+// bot.done and transcript.done delivery.
+func (s *RecordingService) StoreRecording(ctx context.Context, interviewID string) error {
+	if !s.storage.Configured() {
+		log.Printf("Storage is not configured; recording of interview %s won't be stored", interviewID)
+		return nil
+	}
+
+	interview, err := s.interviewService.GetInterview(ctx, interviewID)
+	if err != nil {
+		return ErrInterviewNotFound
+	}
+
+	recording := interview.Recording
+	var keys models.Recording
+	files := []struct {
+		name      string
+		sourceURL string
+		stored    string
+		key       *string
+	}{
+		{models.RecordingFileVideo, recording.VideoURL, recording.VideoKey, &keys.VideoKey},
+		{models.RecordingFileAudio, recording.AudioURL, recording.AudioKey, &keys.AudioKey},
+		{models.RecordingFileTranscript, recording.TranscriptURL, recording.TranscriptKey, &keys.TranscriptKey},
+	}
+
+	var firstErr error
+	for _, file := range files {
+		if file.sourceURL == "" || file.stored != "" {
+			continue
+		}
+
+		key := recordingObjectKey(interviewID, file.name, file.sourceURL)
+		if err := s.storage.CopyFromURL(ctx, key, file.sourceURL); err != nil {
+			log.Printf("Failed to store %s of interview %s: %v", file.name, interviewID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		*file.key = key
+	}
+
+	if keys.VideoKey != "" || keys.AudioKey != "" || keys.TranscriptKey != "" {
+		if err := s.interviewService.SetRecordingKeys(ctx, interviewID, keys); err != nil {
+			return err
+		}
+	}
+	return firstErr
+}
+
+// RecordingURLs returns short-lived download URLs for the interview's stored
+// recording files. Only the interview's participants may have them.
+func (s *RecordingService) RecordingURLs(ctx context.Context, interviewID, userID string) (*RecordingURLs, error) {
+	interview, err := s.interviewService.GetInterview(ctx, interviewID)
+	if err != nil {
+		return nil, ErrInterviewNotFound
+	}
+
+	if !isInterviewParticipant(interview, userID) {
+		return nil, ErrNotParticipant
+	}
+
+	recording := interview.Recording
+	if recording.VideoKey == "" && recording.AudioKey == "" && recording.TranscriptKey == "" {
+		return nil, ErrRecordingNotStored
+	}
+
+	ttl, err := utils.ParseDuration(s.config.RecordingURLTTL)
+	if err != nil || ttl <= 0 {
+		ttl = defaultRecordingURLTTL
+	}
+
+	urls := &RecordingURLs{
+		Status:    recording.Status,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	files := []struct {
+		key string
+		url *string
+	}{
+		{recording.VideoKey, &urls.VideoURL},
+		{recording.AudioKey, &urls.AudioURL},
+		{recording.TranscriptKey, &urls.TranscriptURL},
+	}
+	for _, file := range files {
+		if file.key == "" {
+			continue
+		}
+		if *file.url, err = s.storage.PresignGetObject(file.key, ttl); err != nil {
+			return nil, err
+		}
+	}
+
+	return urls, nil
+}
+
+// recordingObjectKey is where a recording file is stored, keeping the source's
+// file extension, e.g. "interviews/<id>/video.mp4"
+func recordingObjectKey(interviewID, name, sourceURL string) string {
+	key := "interviews/" + interviewID + "/" + name
+	if u, err := url.Parse(sourceURL); err == nil {
+		if ext := path.Ext(u.Path); len(ext) > 1 && len(ext) <= 5 {
+			key += strings.ToLower(ext)
+		}
+	}
+	return key
+}
+
+// notifyParticipants pushes an event to each of the interview's participants
+func (s *RecordingService) notifyParticipants(interview *models.Interview, event interface{}) {
+	for _, participant := range interview.Participants {
+		s.hub.BroadcastToUser(participant.UserID.Hex(), event)
+	}
+}
+
+// interviewParticipants builds the participant list of a new interview
+func interviewParticipants(userIDs []string) []models.Participant {
+	participants := make([]models.Participant, 0, len(userIDs))
+	for _, userID := range userIDs {
+		objectID, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			continue
+		}
+		participants = append(participants, models.Participant{
+			UserID:   objectID,
+			JoinedAt: time.Now(),
+		})
+	}
+	return participants
+}
+
+func isInterviewParticipant(interview *models.Interview, userID string) bool {
+	for _, participant := range interview.Participants {
+		if participant.UserID.Hex() == userID {
+			return true
+		}
+	}
+	return false
+}
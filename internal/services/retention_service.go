@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/PRM710/Rankedterview-backend/internal/config"
+	"github.com/PRM710/Rankedterview-backend/internal/database"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/repositories"
+	"github.com/PRM710/Rankedterview-backend/internal/storage"
+	"github.com/PRM710/Rankedterview-backend/internal/utils"
+)
+
+const (
+	// Interviews purged per query; the job keeps querying until none are left
+	purgeBatchSize = 100
+
+	defaultPurgeInterval = time.Hour
+
+	// Held by the instance running this interval's purge
+	purgeLockKey = "retention:purge:lock"
+)
+
+var ErrInvalidRecordingFile = errors.New("invalid recording file")
+
+// RetentionService deletes recording files once their retention period is
+// over, or earlier when a participant asks, and keeps an audit trail of
+// every purge
+type RetentionService struct {
+	interviewService *InterviewService
+	storage          *storage.Client
+	auditRepo        *repositories.AuditRepository
+	redis            *database.RedisClient
+	config           *config.Config
+}
+
+func NewRetentionService(
+	interviewService *InterviewService,
+	storageClient *storage.Client,
+	auditRepo *repositories.AuditRepository,
+	redis *database.RedisClient,
+	cfg *config.Config,
+) *RetentionService {
+	return &RetentionService{
+		interviewService: interviewService,
+		storage:          storageClient,
+		auditRepo:        auditRepo,
+		redis:            redis,
+		config:           cfg,
+	}
+}
+
+// Run purges expired recording files every RETENTION_PURGE_INTERVAL. Every
+// instance runs it; a Redis lock lets only one of them purge per interval.
+func (s *RetentionService) Run() {
+	interval, err := utils.ParseDuration(s.config.RetentionPurgeInterval)
+	if err != nil || interval <= 0 {
+		interval = defaultPurgeInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.runPurge(interval)
+		<-ticker.C
+	}
+}
+
+func (s *RetentionService) runPurge(interval time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), interval)
+	defer cancel()
+
+	acquired, err := s.redis.Client.SetNX(ctx, purgeLockKey, 1, interval).Result()
+	if err != nil || !acquired {
+		return
+	}
+
+	purged, err := s.PurgeExpired(ctx)
+	if purged > 0 {
+		log.Printf("Purged expired recording files of %d interviews", purged)
+	}
+	if err != nil {
+		log.Printf("Recording purge incomplete: %v", err)
+	}
+}
+
+// PurgeExpired deletes video and audio files whose retention period is over
+// and reports how many interviews it purged files from. An interview whose
+// purge fails is skipped until the next run; the failures are returned
+// together once everything else has been purged.
+func (s *RetentionService) PurgeExpired(ctx context.Context) (int, error) {
+	policies := []struct {
+		file      string
+		retention string
+	}{
+		{models.RecordingFileVideo, s.config.RecordingVideoRetention},
+		{models.RecordingFileAudio, s.config.RecordingAudioRetention},
+	}
+
+	purged := 0
+	var failures []error
+	for _, policy := range policies {
+		retention, err := utils.ParseDuration(policy.retention)
+		if err != nil {
+			log.Printf("Invalid %s retention %q; not purging %s files", policy.file, policy.retention, policy.file)
+			continue
+		}
+		if retention <= 0 {
+			continue
+		}
+
+		cutoff := time.Now().Add(-retention)
+		var failed []primitive.ObjectID
+		for {
+			interviews, err := s.interviewService.ListWithFileStoredBefore(ctx, policy.file, cutoff, failed, purgeBatchSize)
+			if err != nil {
+				failures = append(failures, err)
+				break
+			}
+
+			for _, interview := range interviews {
+				if err := s.purgeFiles(ctx, interview, []string{policy.file}, models.PurgeReasonRetention, ""); err != nil {
+					log.Printf("Failed to purge %s of interview %s: %v", policy.file, interview.ID.Hex(), err)
+					failures = append(failures, fmt.Errorf("interview %s: %w", interview.ID.Hex(), err))
+					// Leave it out of later batches; it is retried next run
+					failed = append(failed, interview.ID)
+					continue
+				}
+				purged++
+			}
+
+			if len(interviews) < purgeBatchSize {
+				break
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return purged, fmt.Errorf("%d purges failed: %w", len(failures), errors.Join(failures...))
+	}
+	return purged, nil
+}
+
+// DeleteRecording deletes recording files of an interview at the request of
+// one of its participants; with no files given, all of them are deleted.
+// Deleting the transcript also deletes its text.
+func (s *RetentionService) DeleteRecording(ctx context.Context, interviewID, userID string, files []string) error {
+	if len(files) == 0 {
+		files = []string{models.RecordingFileVideo, models.RecordingFileAudio, models.RecordingFileTranscript}
+	}
+	for _, file := range files {
+		if !models.IsValidRecordingFile(file) {
+			return ErrInvalidRecordingFile
+		}
+	}
+
+	interview, err := s.interviewService.GetInterview(ctx, interviewID)
+	if err != nil {
+		return ErrInterviewNotFound
+	}
+
+	if !isInterviewParticipant(interview, userID) {
+		return ErrNotParticipant
+	}
+
+	return s.purgeFiles(ctx, interview, files, models.PurgeReasonUserRequest, userID)
+}
+
+// purgeFiles records the purge, deletes recording files from the bucket and
+// clears them from the interview. Nothing is deleted unless the purge could
+// be audited, and objects are deleted before they are cleared, so a purge
+// that fails part way is simply repeated.
+func (s *RetentionService) purgeFiles(ctx context.Context, interview *models.Interview, files []string, reason, actorID string) error {
+	interviewID := interview.ID.Hex()
+
+	var keys []string
+	for _, file := range files {
+		if key := interview.Recording.FileKey(file); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	entry := &models.AuditEntry{
+		Action:      models.AuditRecordingPurged,
+		InterviewID: interview.ID,
+		Reason:      reason,
+		Details: map[string]string{
+			"files": strings.Join(files, ","),
+			"keys":  strings.Join(keys, ","),
+		},
+	}
+	if actorID != "" {
+		entry.ActorID, _ = primitive.ObjectIDFromHex(actorID)
+	}
+
+	if err := s.auditRepo.Create(ctx, entry); err != nil {
+		return fmt.Errorf("auditing purge: %w", err)
+	}
+
+	for _, key := range keys {
+		if err := s.storage.DeleteObject(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return s.interviewService.ClearRecordingFiles(ctx, interviewID, files)
+}
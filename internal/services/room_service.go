@@ -154,6 +154,41 @@ func (s *RoomService) GetActiveRooms(ctx context.Context) ([]*models.Room, error
 	return s.roomRepo.FindActiveRooms(ctx)
 }
 
+// ListRooms lists rooms with pagination, optionally filtered by status
+func (s *RoomService) ListRooms(ctx context.Context, status string, page, limit int64) ([]*models.Room, int64, error) {
+	skip := (page - 1) * limit
+
+	rooms, err := s.roomRepo.List(ctx, status, skip, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.roomRepo.Count(ctx, status)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return rooms, total, nil
+}
+
+// TerminateRoom force-ends a room that hasn't ended yet
+func (s *RoomService) TerminateRoom(ctx context.Context, roomID string) (*models.Room, error) {
+	room, err := s.roomRepo.FindByRoomID(ctx, roomID)
+	if err != nil {
+		return nil, ErrRoomNotFound
+	}
+
+	if room.Status == "ended" {
+		return nil, ErrRoomNotActive
+	}
+
+	if err := s.EndRoom(ctx, roomID); err != nil {
+		return nil, err
+	}
+
+	return s.roomRepo.FindByRoomID(ctx, roomID)
+}
+
 // CleanupOldRooms removes ended rooms older than the specified duration
 func (s *RoomService) CleanupOldRooms(ctx context.Context, olderThan time.Duration) error {
 	return s.roomRepo.CleanupOldRooms(ctx, olderThan)
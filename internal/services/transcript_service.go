@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/transcript"
+)
+
+var ErrNoTranscriptURL = errors.New("recording has no transcript URL")
+
+// Largest transcript that will be downloaded
+const maxTranscriptSize = 20 << 20 // 20MB
+
+// TranscriptService downloads provider transcripts and stores them as
+// segments attributed to the interview's participants
+type TranscriptService struct {
+	interviewService *InterviewService
+	userService      *UserService
+	httpClient       *http.Client
+}
+
+func NewTranscriptService(interviewService *InterviewService, userService *UserService) *TranscriptService {
+	return &TranscriptService{
+		interviewService: interviewService,
+		userService:      userService,
+		httpClient:       &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Ingest downloads the interview's transcript from its TranscriptURL, parses
+// it (Recall JSON, WebVTT or SRT), maps speakers to participants and saves it
+func (s *TranscriptService) Ingest(ctx context.Context, interview *models.Interview) (*models.Transcript, error) {
+	if interview.Recording.TranscriptURL == "" {
+		return nil, ErrNoTranscriptURL
+	}
+
+	contentType, body, err := s.download(ctx, interview.Recording.TranscriptURL)
+	if err != nil {
+		return nil, err
+	}
+
+	segments, err := transcript.Parse(contentType, body)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mapSpeakers(ctx, interview, segments)
+
+	result := models.Transcript{
+		Raw:      transcript.RenderRaw(segments),
+		Segments: segments,
+	}
+
+	if err := s.interviewService.UpdateTranscript(ctx, interview.ID.Hex(), result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// download fetches a transcript and returns its content type and body
+func (s *TranscriptService) download(ctx context.Context, url string) (string, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", nil, fmt.Errorf("transcript download returned status %d", resp.StatusCode)
+	}
+
+	// Read one byte past the limit so a truncated transcript isn't taken as complete
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTranscriptSize+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(body) > maxTranscriptSize {
+		return "", nil, fmt.Errorf("transcript is larger than %d bytes", maxTranscriptSize)
+	}
+
+	return resp.Header.Get("Content-Type"), body, nil
+}
+
+// mapSpeakers attributes segments to the interview's participants
+func (s *TranscriptService) mapSpeakers(ctx context.Context, interview *models.Interview, segments []models.TranscriptSegment) {
+	var users []*models.User
+	for _, participant := range interview.Participants {
+		user, err := s.userService.GetUser(ctx, participant.UserID.Hex())
+		if err == nil {
+			users = append(users, user)
+		}
+	}
+
+	assignSpeakers(segments, users)
+}
+
+// assignSpeakers sets UserID on each segment whose provider speaker label is
+// a user's ID, name or email, and names the speaker after them. Each user
+// gets at most one label. If exactly one label and one user are left
+// unmatched, they are paired.
+func assignSpeakers(segments []models.TranscriptSegment, users []*models.User) {
+	speakers := map[string]*models.User{}
+	matched := map[*models.User]bool{}
+	var unmatched []string
+
+	for _, segment := range segments {
+		label := segment.Speaker
+		if _, seen := speakers[label]; seen || containsString(unmatched, label) {
+			continue
+		}
+
+		if user := matchSpeaker(label, users, matched); user != nil {
+			speakers[label] = user
+			matched[user] = true
+		} else {
+			unmatched = append(unmatched, label)
+		}
+	}
+
+	if len(unmatched) == 1 && len(users)-len(matched) == 1 {
+		for _, user := range users {
+			if !matched[user] {
+				speakers[unmatched[0]] = user
+			}
+		}
+	}
+
+	for i := range segments {
+		if user, ok := speakers[segments[i].Speaker]; ok {
+			segments[i].UserID = user.ID.Hex()
+			segments[i].Speaker = user.Name
+		}
+	}
+}
+
+// matchSpeaker finds the user a label refers to, skipping users who already
+// have a label (participants may share a display name)
+func matchSpeaker(label string, users []*models.User, matched map[*models.User]bool) *models.User {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return nil
+	}
+
+	for _, user := range users {
+		if matched[user] {
+			continue
+		}
+		if label == user.ID.Hex() || strings.EqualFold(label, user.Name) || strings.EqualFold(label, user.Email) {
+			return user
+		}
+	}
+	return nil
+}
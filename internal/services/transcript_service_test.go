@@ -0,0 +1,115 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
+func TestAssignSpeakers(t *testing.T) {
+	alice := &models.User{ID: primitive.NewObjectID(), Name: "Alice Smith", Email: "alice@example.com"}
+	bob := &models.User{ID: primitive.NewObjectID(), Name: "Bob", Email: "bob@example.com"}
+	namesake := &models.User{ID: primitive.NewObjectID(), Name: "Alice Smith", Email: "alice.s@example.com"}
+
+	type attribution struct{ speaker, userID string }
+
+	tests := []struct {
+		name   string
+		labels []string
+		users  []*models.User
+		want   []attribution
+	}{
+		{
+			name:   "by name, ignoring case",
+			labels: []string{"alice smith", "BOB"},
+			users:  []*models.User{alice, bob},
+			want:   []attribution{{"Alice Smith", alice.ID.Hex()}, {"Bob", bob.ID.Hex()}},
+		},
+		{
+			name:   "by email and user ID",
+			labels: []string{"alice@example.com", bob.ID.Hex()},
+			users:  []*models.User{alice, bob},
+			want:   []attribution{{"Alice Smith", alice.ID.Hex()}, {"Bob", bob.ID.Hex()}},
+		},
+		{
+			name:   "last unknown speaker is paired with the last participant",
+			labels: []string{"Speaker 2", " Alice Smith ", "Speaker 2"},
+			users:  []*models.User{alice, bob},
+			want:   []attribution{{"Bob", bob.ID.Hex()}, {"Alice Smith", alice.ID.Hex()}, {"Bob", bob.ID.Hex()}},
+		},
+		{
+			name:   "several unknown speakers are left alone",
+			labels: []string{"Speaker 1", "Speaker 2"},
+			users:  []*models.User{alice, bob},
+			want:   []attribution{{"Speaker 1", ""}, {"Speaker 2", ""}},
+		},
+		{
+			name:   "more speakers than participants",
+			labels: []string{"Alice Smith", "Speaker 2", "Speaker 3"},
+			users:  []*models.User{alice, bob},
+			want:   []attribution{{"Alice Smith", alice.ID.Hex()}, {"Speaker 2", ""}, {"Speaker 3", ""}},
+		},
+		{
+			name:   "participants sharing a display name",
+			labels: []string{"Alice Smith", "ALICE SMITH"},
+			users:  []*models.User{alice, namesake},
+			want:   []attribution{{"Alice Smith", alice.ID.Hex()}, {"Alice Smith", namesake.ID.Hex()}},
+		},
+		{
+			name:   "participant that couldn't be loaded",
+			labels: []string{"Speaker 1"},
+			users:  nil,
+			want:   []attribution{{"Speaker 1", ""}},
+		},
+	}
+
+	for _, tt := range tests {
+		segments := make([]models.TranscriptSegment, len(tt.labels))
+		for i, label := range tt.labels {
+			segments[i] = models.TranscriptSegment{Speaker: label, Text: "..."}
+		}
+
+		assignSpeakers(segments, tt.users)
+
+		for i, want := range tt.want {
+			if got := (attribution{segments[i].Speaker, segments[i].UserID}); got != want {
+				t.Errorf("%s: segment %d attributed to %+v, want %+v", tt.name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestDownloadRejectsOversizedTranscript(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		wantErr bool
+	}{
+		{"at the limit", maxTranscriptSize, false},
+		{"over the limit", maxTranscriptSize + 1, true},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/vtt")
+			w.Write(bytes.Repeat([]byte("a"), tt.size))
+		}))
+		s := &TranscriptService{httpClient: server.Client()}
+
+		_, body, err := s.download(context.Background(), server.URL)
+		server.Close()
+
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: download() error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if !tt.wantErr && len(body) != tt.size {
+			t.Errorf("%s: download() returned %d bytes, want %d", tt.name, len(body), tt.size)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/PRM710/Rankedterview-backend/internal/config"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/repositories"
+)
+
+var (
+	ErrAIBudgetExceeded = errors.New("AI usage budget exceeded")
+)
+
+// modelPrice is the USD price per 1M prompt and completion tokens
+type modelPrice struct {
+	Prompt     float64
+	Completion float64
+}
+
+type UsageService struct {
+	usageRepo *repositories.UsageRepository
+	config    *config.Config
+	pricing   map[string]modelPrice
+}
+
+func NewUsageService(usageRepo *repositories.UsageRepository, cfg *config.Config) *UsageService {
+	return &UsageService{
+		usageRepo: usageRepo,
+		config:    cfg,
+		pricing:   parsePricing(cfg.OpenAIPricing),
+	}
+}
+
+// SelectModel picks the model to use for an AI call made on behalf of the
+// given users. When a daily budget is exceeded it either downgrades to the
+// fallback model or returns ErrAIBudgetExceeded, depending on configuration.
+func (s *UsageService) SelectModel(ctx context.Context, userIDs []string) (string, error) {
+	exceeded, err := s.budgetExceeded(ctx, userIDs)
+	if err != nil {
+		return "", err
+	}
+
+	if !exceeded {
+		return s.config.OpenAIModel, nil
+	}
+
+	fallback := s.config.OpenAIFallbackModel
+	if s.config.AIBudgetAction == "block" || fallback == "" || fallback == s.config.OpenAIModel {
+		return "", ErrAIBudgetExceeded
+	}
+
+	return fallback, nil
+}
+
+// budgetExceeded checks the global and per-user daily budgets for today
+func (s *UsageService) budgetExceeded(ctx context.Context, userIDs []string) (bool, error) {
+	day := usageDay(time.Now())
+
+	if s.config.AIDailyCostBudget > 0 {
+		_, cost, err := s.usageRepo.SumDay(ctx, day)
+		if err != nil {
+			return false, err
+		}
+		if cost >= s.config.AIDailyCostBudget {
+			return true, nil
+		}
+	}
+
+	if s.config.AIUserDailyTokenBudget <= 0 && s.config.AIUserDailyCostBudget <= 0 {
+		return false, nil
+	}
+
+	for _, userID := range userIDs {
+		tokens, cost, err := s.usageRepo.SumUserDay(ctx, userID, day)
+		if err != nil {
+			return false, err
+		}
+
+		if s.config.AIUserDailyTokenBudget > 0 && tokens >= int64(s.config.AIUserDailyTokenBudget) {
+			return true, nil
+		}
+		if s.config.AIUserDailyCostBudget > 0 && cost >= s.config.AIUserDailyCostBudget {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Record attributes token usage evenly to the given users and returns the
+// total cost in USD
+func (s *UsageService) Record(ctx context.Context, userIDs []string, usage models.TokenUsage) (float64, error) {
+	cost := s.Cost(usage)
+	if len(userIDs) == 0 || usage.TotalTokens == 0 {
+		return cost, nil
+	}
+
+	day := usageDay(time.Now())
+	n := len(userIDs)
+
+	for i, userID := range userIDs {
+		objectID, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			return cost, err
+		}
+
+		// Split evenly; the first user absorbs any rounding remainder
+		share := models.TokenUsage{
+			Model:            usage.Model,
+			PromptTokens:     usage.PromptTokens / n,
+			CompletionTokens: usage.CompletionTokens / n,
+			TotalTokens:      usage.TotalTokens / n,
+		}
+		if i == 0 {
+			share.PromptTokens += usage.PromptTokens % n
+			share.CompletionTokens += usage.CompletionTokens % n
+			share.TotalTokens += usage.TotalTokens % n
+		}
+
+		if err := s.usageRepo.Increment(ctx, objectID, day, share, cost/float64(n)); err != nil {
+			return cost, err
+		}
+	}
+
+	return cost, nil
+}
+
+// Cost returns the USD cost of token usage. Dated model names such as
+// "gpt-4o-2024-08-06" are priced by their longest configured prefix.
+func (s *UsageService) Cost(usage models.TokenUsage) float64 {
+	price, ok := s.pricing[usage.Model]
+	if !ok {
+		matched := ""
+		for model, p := range s.pricing {
+			if strings.HasPrefix(usage.Model, model) && len(model) > len(matched) {
+				matched = model
+				price = p
+			}
+		}
+	}
+
+	return (float64(usage.PromptTokens)*price.Prompt + float64(usage.CompletionTokens)*price.Completion) / 1e6
+}
+
+// GetReport aggregates usage between two days (inclusive, "2006-01-02")
+func (s *UsageService) GetReport(ctx context.Context, from, to string, groupBy []string) ([]models.UsageReportEntry, error) {
+	return s.usageRepo.Report(ctx, from, to, groupBy)
+}
+
+// usageDay returns the UTC day key used for usage accounting
+func usageDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// parsePricing parses "model=prompt/completion,..." into a price table,
+// skipping malformed entries
+func parsePricing(spec string) map[string]modelPrice {
+	pricing := make(map[string]modelPrice)
+
+	for _, entry := range strings.Split(spec, ",") {
+		model, prices, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+
+		promptStr, completionStr, ok := strings.Cut(prices, "/")
+		if !ok {
+			continue
+		}
+
+		prompt, err := strconv.ParseFloat(strings.TrimSpace(promptStr), 64)
+		if err != nil {
+			continue
+		}
+		completion, err := strconv.ParseFloat(strings.TrimSpace(completionStr), 64)
+		if err != nil {
+			continue
+		}
+
+		pricing[strings.TrimSpace(model)] = modelPrice{Prompt: prompt, Completion: completion}
+	}
+
+	return pricing
+}
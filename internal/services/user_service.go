@@ -4,12 +4,16 @@ import (
 	"context"
 	"errors"
 
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
 	"github.com/PRM710/Rankedterview-backend/internal/models"
 	"github.com/PRM710/Rankedterview-backend/internal/repositories"
 )
 
 var (
 	ErrUpdateFailed = errors.New("failed to update user")
+	ErrInvalidRole  = errors.New("invalid role")
 )
 
 type UserService struct {
@@ -92,3 +96,24 @@ func (s *UserService) ListUsers(ctx context.Context, page, limit int64) ([]*mode
 func (s *UserService) DeleteUser(ctx context.Context, userID string) error {
 	return s.userRepo.Delete(ctx, userID)
 }
+
+// SetRole changes a user's role
+func (s *UserService) SetRole(ctx context.Context, userID, role string) (*models.User, error) {
+	if !models.IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	err := s.userRepo.UpdateRole(ctx, userID, role)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.userRepo.FindByID(ctx, userID)
+}
@@ -0,0 +1,253 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Longest lifetime S3 accepts for a presigned URL
+const maxPresignExpiry = 7 * 24 * time.Hour
+
+var (
+	// ErrNotConfigured is returned when no endpoint or credentials are set
+	ErrNotConfigured = errors.New("storage: bucket not configured")
+
+	// ErrNotFound is returned when the object or source doesn't exist
+	ErrNotFound = errors.New("storage: object not found")
+)
+
+// APIError is a non-2xx response from the object store
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("storage: bucket returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// Options configures a Client
+type Options struct {
+	Endpoint        string // e.g. https://<account>.r2.cloudflarestorage.com or http://localhost:9000
+	Region          string // "auto" for R2; MinIO and S3 use their configured region
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// Client stores objects in one bucket of an S3-compatible object store
+// (Cloudflare R2, MinIO, S3). Requests use path-style addressing, which every
+// S3-compatible store understands, and are signed with Signature Version 4.
+type Client struct {
+	endpoint   *url.URL
+	bucket     string
+	signer     signer
+	httpClient *http.Client
+}
+
+func NewClient(opts Options) (*Client, error) {
+	c := &Client{
+		bucket: opts.Bucket,
+		signer: signer{
+			accessKey: opts.AccessKeyID,
+			secretKey: opts.SecretAccessKey,
+			region:    opts.Region,
+		},
+		// Objects can be large, so requests are bounded by their context instead
+		httpClient: &http.Client{},
+	}
+	if c.signer.region == "" {
+		c.signer.region = "auto"
+	}
+
+	if opts.Endpoint != "" {
+		endpoint, err := url.Parse(strings.TrimRight(opts.Endpoint, "/"))
+		if err != nil {
+			return nil, err
+		}
+		if endpoint.Scheme == "" || endpoint.Host == "" {
+			return nil, fmt.Errorf("storage: invalid endpoint %q", opts.Endpoint)
+		}
+		c.endpoint = endpoint
+	}
+
+	return c, nil
+}
+
+// Configured reports whether an endpoint, bucket and credentials are set
+func (c *Client) Configured() bool {
+	return c.endpoint != nil && c.bucket != "" && c.signer.accessKey != "" && c.signer.secretKey != ""
+}
+
+// PutObject uploads an object. S3 needs the size up front; pass -1 if it isn't
+// known and the body is spooled to a temporary file first.
+func (c *Client) PutObject(ctx context.Context, key, contentType string, body io.Reader, size int64) error {
+	if !c.Configured() {
+		return ErrNotConfigured
+	}
+
+	if size < 0 {
+		spooled, n, err := spool(body)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			spooled.Close()
+			os.Remove(spooled.Name())
+		}()
+		body, size = spooled, n
+	}
+
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.do(ctx, http.MethodPut, key, header, body, size)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// DeleteObject removes an object; deleting a missing object is not an error
+func (c *Client) DeleteObject(ctx context.Context, key string) error {
+	if !c.Configured() {
+		return ErrNotConfigured
+	}
+
+	resp, err := c.do(ctx, http.MethodDelete, key, nil, nil, 0)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// CopyFromURL downloads sourceURL and stores it under key, keeping the
+// source's content type
+func (c *Client) CopyFromURL(ctx context.Context, key, sourceURL string) error {
+	if !c.Configured() {
+		return ErrNotConfigured
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("storage: downloading %s returned status %d", key, resp.StatusCode)
+	}
+
+	return c.PutObject(ctx, key, resp.Header.Get("Content-Type"), resp.Body, resp.ContentLength)
+}
+
+// PresignGetObject returns a URL anyone can use to download the object until
+// it expires
+func (c *Client) PresignGetObject(key string, expires time.Duration) (string, error) {
+	if !c.Configured() {
+		return "", ErrNotConfigured
+	}
+	if expires <= 0 || expires > maxPresignExpiry {
+		return "", fmt.Errorf("storage: presigned URL expiry must be between 1s and %s", maxPresignExpiry)
+	}
+
+	u := c.objectURL(key)
+	u.RawQuery = c.signer.presign(time.Now().UTC(), http.MethodGet, u.Host, u.Path, expires)
+	return u.String(), nil
+}
+
+// objectURL is the path-style URL of an object
+func (c *Client) objectURL(key string) *url.URL {
+	u := *c.endpoint
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + c.bucket + "/" + strings.TrimLeft(key, "/")
+	u.RawPath = encodePath(u.Path)
+	return &u
+}
+
+// do sends a signed request for an object. The payload isn't hashed (it is
+// streamed), which S3-compatible stores accept as UNSIGNED-PAYLOAD.
+func (c *Client) do(ctx context.Context, method, key string, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	u := c.objectURL(key)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+	}
+
+	now := time.Now().UTC()
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	req.Header.Set("Authorization", c.signer.authorization(now, method, u.Path, nil, map[string]string{
+		"host":                 u.Host,
+		"x-amz-content-sha256": unsignedPayload,
+		"x-amz-date":           now.Format(amzDateFormat),
+	}, unsignedPayload))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	return resp, nil
+}
+
+// spool copies a body of unknown length to a temporary file, returning the
+// file rewound to its start and its size
+func spool(body io.Reader) (*os.File, int64, error) {
+	file, err := os.CreateTemp("", "storage-upload-*")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	n, err := io.Copy(file, body)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, 0, err
+	}
+
+	return file, n, nil
+}
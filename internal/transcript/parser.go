@@ -0,0 +1,68 @@
+// Package transcript parses provider transcripts (Recall JSON, WebVTT and
+// SRT) into transcript segments.
+package transcript
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
+// Transcript formats
+const (
+	FormatRecallJSON = "recall_json"
+	FormatWebVTT     = "webvtt"
+	FormatSRT        = "srt"
+)
+
+var ErrUnknownFormat = errors.New("transcript: unrecognized format")
+
+// Some providers start text transcripts with a UTF-8 byte order mark
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// Parse parses a transcript, detecting its format from the content type and
+// the body. Segment speakers are the provider's labels.
+func Parse(contentType string, body []byte) ([]models.TranscriptSegment, error) {
+	format := DetectFormat(contentType, body)
+	switch format {
+	case FormatRecallJSON:
+		return ParseRecallJSON(body)
+	case FormatWebVTT:
+		return ParseWebVTT(body)
+	case FormatSRT:
+		return ParseSRT(body)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// DetectFormat works out which format a transcript is in
+func DetectFormat(contentType string, body []byte) string {
+	contentType = strings.ToLower(contentType)
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(body, utf8BOM))
+
+	switch {
+	case strings.Contains(contentType, "json"), bytes.HasPrefix(trimmed, []byte("[")), bytes.HasPrefix(trimmed, []byte("{")):
+		return FormatRecallJSON
+	case strings.Contains(contentType, "vtt"), bytes.HasPrefix(trimmed, []byte("WEBVTT")):
+		return FormatWebVTT
+	case strings.Contains(contentType, "srt"), strings.Contains(contentType, "subrip"), len(trimmed) > 0 && trimmed[0] >= '0' && trimmed[0] <= '9':
+		return FormatSRT
+	}
+	return ""
+}
+
+// RenderRaw renders segments as plain text, one "Speaker: text" line each
+func RenderRaw(segments []models.TranscriptSegment) string {
+	var b strings.Builder
+	for _, segment := range segments {
+		speaker := segment.Speaker
+		if speaker == "" {
+			speaker = "Unknown"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", speaker, segment.Text)
+	}
+	return b.String()
+}
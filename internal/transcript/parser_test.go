@@ -0,0 +1,31 @@
+package transcript
+
+import "testing"
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		want        string
+	}{
+		{"application/json", "", FormatRecallJSON},
+		{"", `  [{"words":[]}]`, FormatRecallJSON},
+		{"text/vtt; charset=utf-8", "", FormatWebVTT},
+		{"text/plain", "\xef\xbb\xbfWEBVTT\n\n", FormatWebVTT},
+		{"application/x-subrip", "", FormatSRT},
+		{"application/octet-stream", "1\n00:00:01,000 --> 00:00:02,000\nHi\n", FormatSRT},
+		{"text/plain", "Alice: Hi", ""},
+	}
+
+	for _, tt := range tests {
+		if got := DetectFormat(tt.contentType, []byte(tt.body)); got != tt.want {
+			t.Errorf("DetectFormat(%q, %q) = %q, want %q", tt.contentType, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	if _, err := Parse("text/plain", []byte("Alice: Hi")); err != ErrUnknownFormat {
+		t.Errorf("Parse error = %v, want %v", err, ErrUnknownFormat)
+	}
+}
@@ -0,0 +1,87 @@
+package transcript
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
+// recallTimestamp is either a bare number of seconds or {"relative": seconds}
+type recallTimestamp float64
+
+func (t *recallTimestamp) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*t = recallTimestamp(seconds)
+		return nil
+	}
+
+	var object struct {
+		Relative float64 `json:"relative"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	*t = recallTimestamp(object.Relative)
+	return nil
+}
+
+// recallEntry is one speaker turn. Both the current format (participant and
+// *_timestamp) and the legacy one (speaker and *_time) are accepted.
+type recallEntry struct {
+	Participant struct {
+		Name string `json:"name"`
+	} `json:"participant"`
+	Speaker string `json:"speaker"`
+	Words   []struct {
+		Text           string          `json:"text"`
+		StartTimestamp recallTimestamp `json:"start_timestamp"`
+		EndTimestamp   recallTimestamp `json:"end_timestamp"`
+		StartTime      recallTimestamp `json:"start_time"`
+		EndTime        recallTimestamp `json:"end_time"`
+		Confidence     float64         `json:"confidence"`
+	} `json:"words"`
+}
+
+// ParseRecallJSON parses a Recall.ai transcript: a list of speaker turns,
+// each with its words and their timestamps
+func ParseRecallJSON(body []byte) ([]models.TranscriptSegment, error) {
+	var entries []recallEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, err
+	}
+
+	segments := make([]models.TranscriptSegment, 0, len(entries))
+	for _, entry := range entries {
+		if len(entry.Words) == 0 {
+			continue
+		}
+
+		speaker := entry.Participant.Name
+		if speaker == "" {
+			speaker = entry.Speaker
+		}
+
+		texts := make([]string, 0, len(entry.Words))
+		var confidence float64
+		for _, word := range entry.Words {
+			texts = append(texts, strings.TrimSpace(word.Text))
+			confidence += word.Confidence
+		}
+
+		first, last := entry.Words[0], entry.Words[len(entry.Words)-1]
+		start := float64(first.StartTimestamp) + float64(first.StartTime)
+		end := float64(last.EndTimestamp) + float64(last.EndTime)
+
+		segments = append(segments, models.TranscriptSegment{
+			Speaker:    speaker,
+			Text:       strings.Join(texts, " "),
+			StartTime:  start,
+			EndTime:    end,
+			Confidence: confidence / float64(len(entry.Words)),
+		})
+	}
+
+	return segments, nil
+}
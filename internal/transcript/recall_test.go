@@ -0,0 +1,77 @@
+package transcript
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
+func TestParseRecallJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []models.TranscriptSegment
+		wantErr bool
+	}{
+		{
+			name: "current format",
+			body: `[{"participant":{"id":1,"name":"Alice"},"words":[
+				{"text":"Hello","start_timestamp":{"relative":1.5},"end_timestamp":{"relative":2},"confidence":1},
+				{"text":"there","start_timestamp":{"relative":2.1},"end_timestamp":{"relative":2.5},"confidence":0.5}]}]`,
+			want: []models.TranscriptSegment{{Speaker: "Alice", Text: "Hello there", StartTime: 1.5, EndTime: 2.5, Confidence: 0.75}},
+		},
+		{
+			name: "legacy format",
+			body: `[{"speaker":"Bob","words":[{"text":" Hi ","start_time":3,"end_time":4,"confidence":1}]}]`,
+			want: []models.TranscriptSegment{{Speaker: "Bob", Text: "Hi", StartTime: 3, EndTime: 4, Confidence: 1}},
+		},
+		{
+			name: "participant name wins over the legacy speaker",
+			body: `[{"participant":{"name":"Alice"},"speaker":"spk_0","words":[{"text":"Hi","start_timestamp":1,"end_timestamp":2}]}]`,
+			want: []models.TranscriptSegment{{Speaker: "Alice", Text: "Hi", StartTime: 1, EndTime: 2}},
+		},
+		{
+			name: "unknown speaker",
+			body: `[{"participant":{"id":7},"words":[{"text":"Hi","start_timestamp":1,"end_timestamp":2}]}]`,
+			want: []models.TranscriptSegment{{Text: "Hi", StartTime: 1, EndTime: 2}},
+		},
+		{
+			name: "turns without words are skipped",
+			body: `[{"participant":{"name":"Alice"},"words":[]},{"participant":{"name":"Bob"},"words":[{"text":"Yes","start_timestamp":5,"end_timestamp":6}]}]`,
+			want: []models.TranscriptSegment{{Speaker: "Bob", Text: "Yes", StartTime: 5, EndTime: 6}},
+		},
+		{
+			name:    "malformed timestamp",
+			body:    `[{"speaker":"Bob","words":[{"text":"Hi","start_timestamp":"soon","end_timestamp":2}]}]`,
+			wantErr: true,
+		},
+		{
+			name:    "malformed relative timestamp",
+			body:    `[{"speaker":"Bob","words":[{"text":"Hi","start_timestamp":{"relative":"1s"},"end_timestamp":2}]}]`,
+			wantErr: true,
+		},
+		{
+			name:    "not a list of turns",
+			body:    `{"words":[]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRecallJSON([]byte(tt.body))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: got %+v, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
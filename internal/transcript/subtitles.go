@@ -0,0 +1,118 @@
+package transcript
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
+var (
+	// "00:01:02.345 --> 00:01:04.000" (WebVTT also allows mm:ss.ttt; SRT uses a comma)
+	cueTimingPattern = regexp.MustCompile(`^((?:\d+:)?\d{1,2}:\d{2}[.,]\d{1,3})\s+-->\s+((?:\d+:)?\d{1,2}:\d{2}[.,]\d{1,3})`)
+
+	// WebVTT voice span: "<v Jane Doe>Hello" or "<v.loud Jane>Hello"
+	voicePattern = regexp.MustCompile(`^<v(?:\.[^\s>]+)*\s+([^>]+)>(.*)$`)
+
+	// Any other cue markup, such as <i> or </v>
+	tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+	errNoCues = errors.New("transcript: no cues found")
+)
+
+// ParseWebVTT parses a WebVTT transcript. Speakers come from <v> voice spans
+// or a "Speaker: " prefix.
+func ParseWebVTT(body []byte) ([]models.TranscriptSegment, error) {
+	return parseCues(body)
+}
+
+// ParseSRT parses an SRT transcript. Speakers come from a "Speaker: " prefix.
+func ParseSRT(body []byte) ([]models.TranscriptSegment, error) {
+	return parseCues(body)
+}
+
+// parseCues parses the cues shared by WebVTT and SRT: blocks separated by
+// blank lines, each with a timing line followed by its text. Header, NOTE,
+// STYLE and index lines are skipped since they carry no timing line.
+func parseCues(body []byte) ([]models.TranscriptSegment, error) {
+	body = bytes.TrimPrefix(body, utf8BOM)
+	body = bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n"))
+
+	var segments []models.TranscriptSegment
+	for _, block := range strings.Split(string(body), "\n\n") {
+		segment, ok := parseCue(block)
+		if ok {
+			segments = append(segments, segment)
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, errNoCues
+	}
+	return segments, nil
+}
+
+func parseCue(block string) (models.TranscriptSegment, bool) {
+	var segment models.TranscriptSegment
+	var lines []string
+	timed := false
+
+	scanner := bufio.NewScanner(strings.NewReader(block))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if !timed {
+			match := cueTimingPattern.FindStringSubmatch(line)
+			if match == nil {
+				continue // header, index or cue identifier
+			}
+			segment.StartTime = parseCueTime(match[1])
+			segment.EndTime = parseCueTime(match[2])
+			timed = true
+			continue
+		}
+
+		if segment.Speaker == "" && len(lines) == 0 {
+			if voice := voicePattern.FindStringSubmatch(line); voice != nil {
+				segment.Speaker = strings.TrimSpace(voice[1])
+				line = voice[2]
+			}
+		}
+		lines = append(lines, strings.TrimSpace(tagPattern.ReplaceAllString(line, "")))
+	}
+
+	if !timed || len(lines) == 0 {
+		return segment, false
+	}
+
+	text := strings.Join(lines, " ")
+	if segment.Speaker == "" {
+		if speaker, rest, found := strings.Cut(text, ": "); found && len(speaker) <= 64 && !strings.ContainsAny(speaker, ".?!") {
+			segment.Speaker = speaker
+			text = rest
+		}
+	}
+	segment.Text = text
+
+	return segment, true
+}
+
+// parseCueTime parses "hh:mm:ss.ttt", "mm:ss.ttt" or "hh:mm:ss,ttt" into seconds
+func parseCueTime(value string) float64 {
+	value = strings.Replace(value, ",", ".", 1)
+	parts := strings.Split(value, ":")
+
+	var seconds float64
+	for _, part := range parts {
+		n, _ := strconv.ParseFloat(part, 64)
+		seconds = seconds*60 + n
+	}
+	return seconds
+}
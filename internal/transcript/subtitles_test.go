@@ -0,0 +1,130 @@
+package transcript
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
+func TestParseWebVTT(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []models.TranscriptSegment
+		wantErr bool
+	}{
+		{
+			name: "voice spans and speaker prefixes",
+			body: "WEBVTT\n\nNOTE recorded by the bot\n\n" +
+				"1\n00:00:01.000 --> 00:00:04.500\n<v Alice>Hello there,\nhow are you?</v>\n\n" +
+				"00:05.000 --> 00:07.250 align:start\nBob: I'm fine.\n",
+			want: []models.TranscriptSegment{
+				{Speaker: "Alice", Text: "Hello there, how are you?", StartTime: 1, EndTime: 4.5},
+				{Speaker: "Bob", Text: "I'm fine.", StartTime: 5, EndTime: 7.25},
+			},
+		},
+		{
+			name: "voice span with classes",
+			body: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\n<v.loud.first Jane Doe>Hi!\n",
+			want: []models.TranscriptSegment{{Speaker: "Jane Doe", Text: "Hi!", StartTime: 1, EndTime: 2}},
+		},
+		{
+			name: "unknown speaker",
+			body: "WEBVTT\n\n00:00:09.000 --> 00:00:10.000\n<i>No speaker.</i>\n\n" +
+				"00:00:11.000 --> 00:00:12.000\nNote. Then: not a speaker\n",
+			want: []models.TranscriptSegment{
+				{Text: "No speaker.", StartTime: 9, EndTime: 10},
+				{Text: "Note. Then: not a speaker", StartTime: 11, EndTime: 12},
+			},
+		},
+		{
+			name: "cues with malformed timestamps are skipped",
+			body: "WEBVTT\n\n00:00:08.000 --> soon\nLost cue\n\n" +
+				"1:2:3 --> 00:00:04.000\nAlso lost\n\n" +
+				"00:00:20.000 --> 00:00:21.000\nKept\n",
+			want: []models.TranscriptSegment{{Text: "Kept", StartTime: 20, EndTime: 21}},
+		},
+		{
+			name: "cues without text are skipped",
+			body: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\n\n00:00:03.000 --> 00:00:04.000\nAlice: Hi\n",
+			want: []models.TranscriptSegment{{Speaker: "Alice", Text: "Hi", StartTime: 3, EndTime: 4}},
+		},
+		{
+			name:    "no cues",
+			body:    "WEBVTT\n\nNOTE empty\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseWebVTT([]byte(tt.body))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: got %+v, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseSRT(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []models.TranscriptSegment
+		wantErr bool
+	}{
+		{
+			name: "multi-line cues",
+			body: "1\n00:00:01,000 --> 00:00:02,500\nAlice: First line\nsecond line\n\n" +
+				"2\n01:00:00,500 --> 01:00:02,000\nBob: Reply\n",
+			want: []models.TranscriptSegment{
+				{Speaker: "Alice", Text: "First line second line", StartTime: 1, EndTime: 2.5},
+				{Speaker: "Bob", Text: "Reply", StartTime: 3600.5, EndTime: 3602},
+			},
+		},
+		{
+			name: "byte order mark and CRLF line endings",
+			body: "\xef\xbb\xbf1\r\n00:00:03,000 --> 00:00:04,000\r\nAlice: Hi\r\n\r\n2\r\n00:00:05,000 --> 00:00:06,000\r\nBob: Hey\r\n",
+			want: []models.TranscriptSegment{
+				{Speaker: "Alice", Text: "Hi", StartTime: 3, EndTime: 4},
+				{Speaker: "Bob", Text: "Hey", StartTime: 5, EndTime: 6},
+			},
+		},
+		{
+			name: "unknown speaker",
+			body: "1\n00:00:01,000 --> 00:00:02,000\nJust text\n",
+			want: []models.TranscriptSegment{{Text: "Just text", StartTime: 1, EndTime: 2}},
+		},
+		{
+			name:    "malformed timestamps only",
+			body:    "1\n00:00:01 --> 00:00:02\nNo milliseconds\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSRT([]byte(tt.body))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: got %+v, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
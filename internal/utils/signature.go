@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMissingSignature = errors.New("missing webhook signature headers")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrStaleWebhook     = errors.New("webhook timestamp outside tolerance")
+	ErrNoWebhookSecret  = errors.New("webhook secret is not configured")
+)
+
+// VerifyWebhookSignature checks a Svix-style webhook signature, as sent by
+// Recall.ai: signatureHeader holds space-separated "v1,<base64 HMAC-SHA256>"
+// entries over "<id>.<timestamp>.<body>". The secret may carry a "whsec_"
+// prefix, in which case the rest is the base64-encoded key. Every delivery is
+// rejected while the secret is empty, since anyone could sign with it.
+func VerifyWebhookSignature(secret, id, timestamp, signatureHeader string, body []byte, tolerance time.Duration, now time.Time) error {
+	if secret == "" {
+		return ErrNoWebhookSecret
+	}
+	if id == "" || timestamp == "" || signatureHeader == "" {
+		return ErrMissingSignature
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	sentAt := time.Unix(seconds, 0)
+	if now.Sub(sentAt) > tolerance || sentAt.Sub(now) > tolerance {
+		return ErrStaleWebhook
+	}
+
+	key := []byte(secret)
+	if encoded, ok := strings.CutPrefix(secret, "whsec_"); ok {
+		if key, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return err
+		}
+	}
+	if len(key) == 0 {
+		return ErrNoWebhookSecret
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	// Several signatures are sent while a secret is being rotated
+	for _, entry := range strings.Fields(signatureHeader) {
+		version, signature, found := strings.Cut(entry, ",")
+		if !found || version != "v1" {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(signature)
+		if err != nil {
+			continue
+		}
+
+		if hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
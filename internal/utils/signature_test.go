@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func sign(key []byte, id, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(body)
+	return "v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	const (
+		secret    = "test-secret"
+		id        = "msg_2Lh9KRb0pzN4LePd3XiA4v12Axj"
+		tolerance = 5 * time.Minute
+	)
+	now := time.Unix(1700000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := []byte(`{"event":"bot.done","data":{"bot_id":"b1"}}`)
+	valid := sign([]byte(secret), id, timestamp, body)
+
+	rawKey := []byte("0123456789abcdef0123456789abcdef")
+	whsecSecret := "whsec_" + base64.StdEncoding.EncodeToString(rawKey)
+
+	tests := []struct {
+		name      string
+		secret    string
+		id        string
+		timestamp string
+		signature string
+		body      []byte
+		want      error
+	}{
+		{"valid", secret, id, timestamp, valid, body, nil},
+		{"whsec_ secret", whsecSecret, id, timestamp, sign(rawKey, id, timestamp, body), body, nil},
+		{"rotated secret listed second", secret, id, timestamp, sign([]byte("old-secret"), id, timestamp, body) + " " + valid, body, nil},
+		{"rotated secret listed first", secret, id, timestamp, valid + " " + sign([]byte("old-secret"), id, timestamp, body), body, nil},
+		{"unknown versions are skipped", secret, id, timestamp, "v1a,c2lnbmF0dXJl " + valid, body, nil},
+		{"no matching signature among several", secret, id, timestamp, sign([]byte("a"), id, timestamp, body) + " " + sign([]byte("b"), id, timestamp, body), body, ErrInvalidSignature},
+		{"wrong secret", "other-secret", id, timestamp, valid, body, ErrInvalidSignature},
+		{"tampered body", secret, id, timestamp, valid, []byte(`{"event":"bot.fatal"}`), ErrInvalidSignature},
+		{"different id", secret, "msg_other", timestamp, valid, body, ErrInvalidSignature},
+		{"expired timestamp", secret, id, strconv.FormatInt(now.Add(-tolerance-time.Second).Unix(), 10), valid, body, ErrStaleWebhook},
+		{"timestamp too far ahead", secret, id, strconv.FormatInt(now.Add(tolerance+time.Second).Unix(), 10), valid, body, ErrStaleWebhook},
+		{"malformed timestamp", secret, id, "yesterday", valid, body, ErrInvalidSignature},
+		{"malformed signature", secret, id, timestamp, "v1,not base64!", body, ErrInvalidSignature},
+		{"missing signature", secret, id, timestamp, "", body, ErrMissingSignature},
+		{"missing id", secret, "", timestamp, valid, body, ErrMissingSignature},
+		{"empty secret", "", id, timestamp, sign(nil, id, timestamp, body), body, ErrNoWebhookSecret},
+		{"empty whsec_ secret", "whsec_", id, timestamp, sign(nil, id, timestamp, body), body, ErrNoWebhookSecret},
+	}
+
+	for _, tt := range tests {
+		err := VerifyWebhookSignature(tt.secret, tt.id, tt.timestamp, tt.signature, tt.body, tolerance, now)
+		if err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/PRM710/Rankedterview-backend/internal/utils"
+)
+
+const (
+	// How long a connection may stay open before sending its auth frame
+	authTimeout = 10 * time.Second
+
+	// How long before expiry the client is asked to refresh its token
+	tokenRefreshWarning = time.Minute
+
+	// Subprotocol used to carry the token: "Sec-WebSocket-Protocol: bearer, <token>"
+	BearerSubprotocol = "bearer"
+
+	// Close codes (4000-4999 are reserved for applications)
+	CloseAuthFailed     = 4001
+	CloseTokenExpired   = 4002
+	CloseAccountBanned  = 4003
+	CloseSlowClient     = 4004 // send buffer full; reconnect with lastSeq to catch up
+	CloseSessionRevoked = 4005 // the user's sessions were revoked; log in again
+)
+
+var (
+	ErrAuthRequired   = errors.New("authentication required")
+	ErrUserMismatch   = errors.New("token belongs to a different user")
+	ErrSessionRevoked = errors.New("session has been revoked")
+)
+
+// Authenticate validates an access token for a WebSocket connection
+func (h *Hub) Authenticate(token string) (*utils.JWTClaims, error) {
+	if token == "" {
+		return nil, ErrAuthRequired
+	}
+	return utils.ValidateToken(token, h.jwtSecret)
+}
+
+// TokenFromRequest returns the token sent with the upgrade request, either as
+// the "token" query parameter or as the second value of the bearer subprotocol
+func TokenFromRequest(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+
+	protocols := websocket.Subprotocols(r)
+	if len(protocols) >= 2 && protocols[0] == BearerSubprotocol {
+		return protocols[1]
+	}
+
+	return ""
+}
+
+// ReadAuthFrame waits for the first message on a new connection and
+// authenticates it. The frame looks like {"type": "auth", "data": {"token": "..."}}.
+func (h *Hub) ReadAuthFrame(conn *websocket.Conn) (*utils.JWTClaims, error) {
+	conn.SetReadDeadline(time.Now().Add(authTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	var msg AuthEvent
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Type != EventAuth {
+		return nil, ErrAuthRequired
+	}
+
+	return h.Authenticate(msg.Data.Token)
+}
+
+// RejectConnection closes a connection that failed to authenticate
+func RejectConnection(conn *websocket.Conn, reason string) {
+	CloseConnection(conn, CloseAuthFailed, reason)
+}
+
+// CloseConnection sends a close frame with an application close code and
+// closes the connection
+func CloseConnection(conn *websocket.Conn, code int, reason string) {
+	conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(writeWait),
+	)
+	conn.Close()
+}
+
+// SetTokenExpiry schedules the connection to be closed when its token expires.
+// The client is warned shortly before so it can refresh in-band.
+func (c *Client) SetTokenExpiry(expiresAt time.Time) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	c.stopExpiryTimersLocked()
+
+	untilExpiry := time.Until(expiresAt)
+	if untilExpiry > tokenRefreshWarning {
+		c.warnTimer = time.AfterFunc(untilExpiry-tokenRefreshWarning, func() {
+			c.Send(TokenExpiringEvent{Type: EventTokenExpiring, ExpiresAt: expiresAt})
+		})
+	}
+
+	c.expiryTimer = time.AfterFunc(untilExpiry, func() {
+		log.Printf("Token expired for user %s, closing connection", c.UserID)
+		CloseConnection(c.conn, CloseTokenExpired, "token expired")
+	})
+}
+
+// stopExpiryTimers stops the token expiry timers (on disconnect)
+func (c *Client) stopExpiryTimers() {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+	c.stopExpiryTimersLocked()
+}
+
+func (c *Client) stopExpiryTimersLocked() {
+	if c.warnTimer != nil {
+		c.warnTimer.Stop()
+	}
+	if c.expiryTimer != nil {
+		c.expiryTimer.Stop()
+	}
+}
+
+// checkSession rejects tokens of revoked sessions
+func (h *Hub) checkSession(sessionID string) error {
+	if h.sessionChecker == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), routingTimeout)
+	defer cancel()
+
+	revoked, err := h.sessionChecker.IsSessionRevoked(ctx, sessionID)
+	if err != nil {
+		log.Printf("Failed to check session %s: %v", sessionID, err)
+		return errors.New("failed to check session")
+	}
+	if revoked {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// handleAuth refreshes the connection's token in-band. The new token must
+// belong to the same user; a failed refresh leaves the current expiry in place.
+func (c *Client) handleAuth(msg *AuthEvent) {
+	claims, err := c.hub.Authenticate(msg.Data.Token)
+	if err == nil && claims.UserID != c.UserID {
+		err = ErrUserMismatch
+	}
+	if err == nil {
+		err = c.hub.checkSession(claims.SessionID)
+	}
+	if err != nil {
+		c.sendError(ErrorAuthFailed, msg.Type, err.Error())
+		return
+	}
+
+	c.SetTokenExpiry(claims.ExpiresAt.Time)
+	c.Send(AuthOKEvent{Type: EventAuthOK, ExpiresAt: claims.ExpiresAt.Time})
+}
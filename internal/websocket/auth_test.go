@@ -0,0 +1,47 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+	"github.com/PRM710/Rankedterview-backend/internal/utils"
+)
+
+type stubSessions struct {
+	revoked map[string]bool
+}
+
+func (s stubSessions) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	return s.revoked[sessionID], nil
+}
+
+func TestAuthRefreshRejectsRevokedSession(t *testing.T) {
+	h := newTestHub()
+	h.SetSessionChecker(stubSessions{revoked: map[string]bool{"old": true}})
+	alice := connect(h, "alice")
+	defer alice.stopExpiryTimers()
+
+	tests := []struct {
+		sessionID string
+		want      string
+	}{
+		{"old", EventError},
+		{"current", EventAuthOK},
+	}
+
+	for _, tt := range tests {
+		token, err := utils.GenerateToken("alice", "alice@example.com", models.RoleUser, tt.sessionID, "test-secret", 10*time.Minute)
+		if err != nil {
+			t.Fatalf("GenerateToken: %v", err)
+		}
+
+		alice.handleMessage([]byte(`{"type":"auth","data":{"token":"` + token + `"}}`))
+
+		messages := received(t, alice)
+		if len(messages) != 1 || messages[0]["type"] != tt.want {
+			t.Errorf("refresh with session %s got %v, want %s", tt.sessionID, messages, tt.want)
+		}
+	}
+}
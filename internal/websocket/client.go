@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
 )
 
 const (
@@ -22,37 +25,106 @@ type Client struct {
 	conn   *websocket.Conn
 	send   chan []byte
 	UserID string
-	RoomID string
+	RoomID string // guarded by hub.clientsMu
+
+	// Identifies this connection in the presence registry
+	connID string
+
+	// Protocol version negotiated when connecting
+	protocolVersion int
+
+	// Sequence number of the last reliable event queued for the client, and
+	// whether it resumes an earlier connection from lastSeq
+	seqMu    sync.Mutex
+	lastSeq  int64
+	seqKnown bool
+	resuming bool
+
+	closeOnce sync.Once
+
+	// Guards send: once the hub closes it, queued payloads are dropped
+	sendMu sync.Mutex
+	closed bool
+
+	// Access token expiry; the connection is closed when it passes
+	authMu      sync.Mutex
+	warnTimer   *time.Timer
+	expiryTimer *time.Timer
 }
 
-// NewClient creates a new client
-func NewClient(hub *Hub, conn *websocket.Conn, userID string) *Client {
+// NewClient creates a new client speaking the given protocol version
+func NewClient(hub *Hub, conn *websocket.Conn, userID string, protocolVersion int) *Client {
 	return &Client{
-		hub:    hub,
-		conn:   conn,
-		send:   make(chan []byte, 256),
-		UserID: userID,
+		hub:             hub,
+		conn:            conn,
+		send:            make(chan []byte, 256),
+		UserID:          userID,
+		connID:          newID(),
+		protocolVersion: protocolVersion,
 	}
 }
 
-// Send sends a message to the client
-func (c *Client) Send(data map[string]interface{}) error {
-	payload, err := json.Marshal(data)
+// ResumeFrom makes the connection resume from the last reliable event the
+// client processed; events after it are replayed. Must be called before the
+// client is registered.
+func (c *Client) ResumeFrom(lastSeq int64) {
+	c.lastSeq = lastSeq
+	c.seqKnown = true
+	c.resuming = true
+}
+
+// Send sends an event to the client
+func (c *Client) Send(event interface{}) error {
+	payload, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
 
+	c.queue(payload)
+	return nil
+}
+
+// queue adds a payload to the client's send buffer. A client whose buffer is
+// full has fallen too far behind; it is disconnected so it reconnects and
+// resumes rather than silently missing events.
+func (c *Client) queue(payload []byte) bool {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if c.closed {
+		return false
+	}
+
 	select {
 	case c.send <- payload:
-		return nil
+		return true
 	default:
-		return nil // Drop if buffer full
+		log.Printf("Send buffer full for user %s, disconnecting slow client", c.UserID)
+		c.closeOnce.Do(func() {
+			if c.conn != nil {
+				go CloseConnection(c.conn, CloseSlowClient, "client too slow")
+			}
+		})
+		return false
+	}
+}
+
+// closeSend closes the send channel, which stops WritePump; later queue
+// calls drop their payload instead of panicking
+func (c *Client) closeSend() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.send)
 	}
 }
 
 // ReadPump pumps messages from the WebSocket connection to the hub
 func (c *Client) ReadPump() {
 	defer func() {
+		c.stopExpiryTimers()
 		c.hub.unregister <- c
 		c.conn.Close()
 	}()
@@ -109,71 +181,84 @@ func (c *Client) WritePump() {
 	}
 }
 
-// handleMessage handles incoming WebSocket messages
+// handleMessage handles incoming WebSocket messages. Frames that aren't
+// valid client events are rejected with an error event.
 func (c *Client) handleMessage(message []byte) {
-	var msg Event
-	if err := json.Unmarshal(message, &msg); err != nil {
-		log.Printf("Error unmarshaling message: %v", err)
+	event, err := DecodeEvent(message)
+	if err != nil {
+		log.Printf("Rejected frame from %s: %v", c.UserID, err)
+		if frameErr, ok := err.(*FrameError); ok {
+			c.Send(frameErr.ErrorEvent())
+		}
 		return
 	}
 
-	// Debug: log ALL incoming messages
-	log.Printf("handleMessage: type=%s, from=%s, to=%s, roomId=%s", msg.Type, c.UserID, msg.To, msg.RoomID)
-
 	// Handle different event types
-	switch msg.Type {
-	case "ping":
+	switch msg := event.(type) {
+	case *PingEvent:
 		// Heartbeat from client - respond with pong
-		c.Send(map[string]interface{}{"type": "pong"})
-		return
-
-	case EventJoinQueue:
-		// Client wants to join matchmaking queue
-		// This is handled via HTTP API, so we just acknowledge
-		c.Send(map[string]interface{}{
-			"type":    "queue_ack",
-			"message": "Queue request received",
-		})
+		c.Send(PongEvent{Type: EventPong})
+
+	case *AuthEvent:
+		// In-band token refresh
+		c.handleAuth(msg)
+
+	case *QueueRequestEvent:
+		// Joining and leaving the queue is handled via HTTP API, so we just acknowledge
+		if msg.Type == EventJoinQueue {
+			c.Send(QueueEvent{Type: EventQueueAck, Message: "Queue request received"})
+		} else {
+			c.Send(QueueEvent{Type: EventQueueLeftAck, Message: "Left queue"})
+		}
 
-	case EventLeaveQueue:
-		// Client wants to leave queue
-		c.Send(map[string]interface{}{
-			"type":    "queue_left_ack",
-			"message": "Left queue",
-		})
+	case *AckEvent:
+		// Client processed every reliable event up to msg.Seq
+		c.hub.ack(c.UserID, msg.Seq)
 
-	case EventAcceptMatch:
+	case *AcceptMatchEvent:
 		// User accepted the match
 		c.handleAcceptMatch(msg)
 
-	case EventWebRTCOffer, EventWebRTCAnswer, EventICECandidate:
-		// Relay WebRTC signaling - handle all three the same way
-		c.relayWebRTC(msg)
+	case *RecordingConsentEvent:
+		// User answered the recording consent request
+		c.handleRecordingConsent(msg)
+
+	case *WebRTCOfferEvent:
+		c.relayWebRTC(msg.Type, msg.To, msg.RoomID, func(roomID string) interface{} {
+			return WebRTCOfferEvent{Type: msg.Type, From: c.UserID, RoomID: roomID, SDP: msg.SDP}
+		})
+
+	case *WebRTCAnswerEvent:
+		c.relayWebRTC(msg.Type, msg.To, msg.RoomID, func(roomID string) interface{} {
+			return WebRTCAnswerEvent{Type: msg.Type, From: c.UserID, RoomID: roomID, SDP: msg.SDP}
+		})
 
-	case EventCallEnd:
+	case *ICECandidateEvent:
+		c.relayWebRTC(msg.Type, msg.To, msg.RoomID, func(roomID string) interface{} {
+			return ICECandidateEvent{Type: msg.Type, From: c.UserID, RoomID: roomID, Candidate: msg.Candidate}
+		})
+
+	case *CallEndEvent:
 		// User ended the call - notify room participants
 		c.handleCallEnded(msg)
 
-	case EventMediaStateChange:
+	case *MediaStateChangeEvent:
 		// User toggled mic/camera - notify room participants
 		c.handleMediaStateChanged(msg)
 
-	case EventMessage:
+	case *SendMessageEvent:
 		// Relay chat message in room
 		c.relayToRoom(msg)
 
-	default:
-		log.Printf("Unknown event type: %s", msg.Type)
+	case *TranscriptSegmentEvent:
+		// Partial transcript from the client's speech-to-text (live coaching)
+		c.handleTranscriptSegment(msg)
 	}
 }
 
 // handleAcceptMatch handles when a user accepts a match
-func (c *Client) handleAcceptMatch(msg Event) {
+func (c *Client) handleAcceptMatch(msg *AcceptMatchEvent) {
 	roomID := msg.RoomID
-	if roomID == "" {
-		log.Printf("No roomId in accept_match from %s", c.UserID)
-		return
-	}
 
 	log.Printf("User %s accepted match for room %s", c.UserID, roomID)
 
@@ -197,9 +282,9 @@ func (c *Client) handleAcceptMatch(msg Event) {
 
 	if len(acceptedUsers) == 1 {
 		// First user accepted - notify them to wait
-		c.Send(map[string]interface{}{
-			"type":    EventPartnerAccepted,
-			"message": "Waiting for partner to accept...",
+		c.Send(PartnerAcceptedEvent{
+			Type:    EventPartnerAccepted,
+			Message: "Waiting for partner to accept...",
 		})
 	}
 
@@ -221,82 +306,116 @@ func (c *Client) handleAcceptMatch(msg Event) {
 				role = "callee"
 			}
 			log.Printf("Assigning role %s to user %s", role, userID)
-			c.hub.BroadcastToUser(userID, map[string]interface{}{
-				"type":   EventBothReady,
-				"roomId": roomID,
-				"role":   role,
+			c.hub.BroadcastToUser(userID, BothReadyEvent{
+				Type:   EventBothReady,
+				RoomID: roomID,
+				Role:   role,
 			})
 		}
 
 		// Also notify room participants who might have different IDs
 		for key, userID := range participants {
 			if (key == "user1" || key == "user2") && userID != c.UserID {
-				c.hub.BroadcastToUser(userID, map[string]interface{}{
-					"type":   EventPartnerAccepted,
-					"roomId": roomID,
+				c.hub.BroadcastToUser(userID, PartnerAcceptedEvent{
+					Type:   EventPartnerAccepted,
+					RoomID: roomID,
 				})
 			}
 		}
 
 		// Clean up the acceptance key
 		c.hub.redis.Del(ctx, acceptKey)
+
+		if c.hub.roomObserver != nil {
+			go c.hub.roomObserver.RoomReady(roomID, acceptedUsers)
+		}
 	}
 }
 
-// relayWebRTC relays WebRTC signaling messages to room participants only
-func (c *Client) relayWebRTC(msg Event) {
-	roomID := msg.To // The "to" field contains the roomId
-
-	payload := map[string]interface{}{
-		"type":      msg.Type,
-		"from":      c.UserID,
-		"roomId":    roomID,
-		"sdp":       msg.SDP,
-		"candidate": msg.Candidate,
+// handleRecordingConsent passes a user's answer to the recording consent
+// request on to the room observer
+func (c *Client) handleRecordingConsent(msg *RecordingConsentEvent) {
+	roomID := msg.RoomID
+	if roomID == "" {
+		roomID = c.currentRoom()
 	}
 
 	if roomID == "" {
-		log.Printf("No roomId in WebRTC message from %s, falling back to broadcast", c.UserID)
-		c.hub.BroadcastToAllExcept(c.UserID, payload)
+		c.sendError(ErrorInvalidPayload, msg.Type, "roomId is required")
+		return
+	}
+	granted := *msg.Data.Granted
+
+	log.Printf("User %s answered recording consent for room %s: %v", c.UserID, roomID, granted)
+
+	if c.hub.roomObserver != nil {
+		go c.hub.roomObserver.RecordingConsent(roomID, c.UserID, granted, msg.Data.PolicyVersion)
+	}
+}
+
+// relayWebRTC relays WebRTC signaling to the sender's partner in the room.
+// SDPs and ICE candidates carry network addresses, so nothing is sent unless
+// the sender is one of the room's participants. relayed builds the event the
+// partner gets.
+func (c *Client) relayWebRTC(eventType, to, roomID string, relayed func(roomID string) interface{}) {
+	if to != "" {
+		roomID = to // The "to" field contains the roomId
+	}
+
+	partnerID, ok := c.hub.roomPartner(roomID, c.UserID)
+	if !ok {
+		log.Printf("Dropping %s from %s: not a participant of room %s", eventType, c.UserID, roomID)
+		c.sendError(ErrorNotRoomParticipant, eventType, "You are not a participant of this room")
 		return
 	}
 
 	// Track which room this client is in (for disconnect notification)
+	c.hub.clientsMu.Lock()
 	if c.RoomID == "" {
 		c.RoomID = roomID
 		log.Printf("Client %s joined room %s", c.UserID, roomID)
 	}
+	c.hub.clientsMu.Unlock()
 
-	log.Printf("relayWebRTC: %s from %s to room %s", msg.Type, c.UserID, roomID)
+	payload, err := json.Marshal(relayed(roomID))
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
 
-	// Try room-based delivery first, then fallback to broadcast all
-	// This ensures WebRTC messages always get through
-	c.hub.BroadcastToRoomExcept(roomID, c.UserID, payload)
+	c.hub.sendToUser(partnerID, payload)
+}
 
-	// Also broadcast to all as fallback (WebRTC is time-sensitive)
-	// The receiver will filter by roomId anyway
-	c.hub.BroadcastToAllExcept(c.UserID, payload)
+// currentRoom returns the room the client is in. RoomID is guarded by the
+// hub's clientsMu, as the hub updates it from other goroutines.
+func (c *Client) currentRoom() string {
+	c.hub.clientsMu.RLock()
+	defer c.hub.clientsMu.RUnlock()
+	return c.RoomID
 }
 
-// relayToRoom relays a message to all users in a room
-func (c *Client) relayToRoom(msg Event) {
-	if msg.RoomID == "" {
-		return
-	}
+// sendError tells the client a frame of the given event type was rejected
+func (c *Client) sendError(code, eventType, message string) {
+	event := NewErrorEvent(code, message)
+	event.Event = eventType
+	c.Send(event)
+}
 
-	c.hub.BroadcastToRoomExcept(msg.RoomID, c.UserID, map[string]interface{}{
-		"type":    msg.Type,
-		"from":    c.UserID,
-		"roomId":  msg.RoomID,
-		"message": msg.Data["message"],
+// relayToRoom relays a chat message to all users in a room
+func (c *Client) relayToRoom(msg *SendMessageEvent) {
+	c.hub.BroadcastToRoomExcept(msg.RoomID, c.UserID, MessageEvent{
+		Type:    EventMessage,
+		From:    c.UserID,
+		RoomID:  msg.RoomID,
+		Message: msg.Data.Message,
 	})
 }
 
 // handleCallEnded handles when a user ends a call
-func (c *Client) handleCallEnded(msg Event) {
+func (c *Client) handleCallEnded(msg *CallEndEvent) {
 	roomID := msg.RoomID
 	if roomID == "" {
-		roomID = c.RoomID
+		roomID = c.currentRoom()
 	}
 
 	if roomID == "" {
@@ -305,29 +424,80 @@ func (c *Client) handleCallEnded(msg Event) {
 
 	log.Printf("User %s ended call in room %s", c.UserID, roomID)
 
-	c.hub.BroadcastToRoomExcept(roomID, c.UserID, map[string]interface{}{
-		"type":   EventCallEnd,
-		"from":   c.UserID,
-		"roomId": roomID,
+	// The call is over, so dropping out later doesn't hold the room, for
+	// either participant
+	c.hub.clientsMu.Lock()
+	if c.RoomID == roomID {
+		c.RoomID = ""
+	}
+	c.hub.clientsMu.Unlock()
+	if _, ok := c.hub.roomPartner(roomID, c.UserID); ok {
+		c.hub.EndRoom(roomID)
+	}
+
+	c.hub.BroadcastToRoomExcept(roomID, c.UserID, CallEndEvent{
+		Type:   EventCallEnd,
+		From:   c.UserID,
+		RoomID: roomID,
 	})
+
+	if c.hub.roomObserver != nil {
+		go c.hub.roomObserver.CallEnded(roomID, c.UserID)
+	}
 }
 
 // handleMediaStateChanged handles when a user changes their media state (mute/video toggle)
-func (c *Client) handleMediaStateChanged(msg Event) {
+func (c *Client) handleMediaStateChanged(msg *MediaStateChangeEvent) {
 	roomID := msg.RoomID
 	if roomID == "" {
-		roomID = c.RoomID
+		roomID = c.currentRoom()
 	}
 
 	if roomID == "" {
 		return
 	}
 
-	c.hub.BroadcastToRoomExcept(roomID, c.UserID, map[string]interface{}{
-		"type":       EventMediaStateChange,
-		"from":       c.UserID,
-		"roomId":     roomID,
-		"isMuted":    msg.Data["isMuted"],
-		"isVideoOff": msg.Data["isVideoOff"],
+	c.hub.BroadcastToRoomExcept(roomID, c.UserID, PartnerMediaStateEvent{
+		Type:       EventMediaStateChange,
+		From:       c.UserID,
+		RoomID:     roomID,
+		IsMuted:    msg.Data.IsMuted,
+		IsVideoOff: msg.Data.IsVideoOff,
 	})
 }
+
+// handleTranscriptSegment forwards a client-relayed transcript segment to the
+// transcript relay. The sender is recorded as the speaker.
+func (c *Client) handleTranscriptSegment(msg *TranscriptSegmentEvent) {
+	roomID := msg.RoomID
+	if roomID == "" {
+		roomID = c.currentRoom()
+	}
+
+	if roomID == "" || c.hub.transcriptRelay == nil {
+		return
+	}
+
+	// Only room participants may stream transcript into a room
+	participants, err := c.hub.getRoomParticipants(roomID)
+	if err != nil || (participants["user1"] != c.UserID && participants["user2"] != c.UserID) {
+		return
+	}
+
+	segment := models.TranscriptSegment{
+		Speaker:   c.UserID,
+		Text:      msg.Data.Text,
+		StartTime: msg.Data.StartTime,
+		EndTime:   msg.Data.EndTime,
+	}
+
+	// Hint generation calls OpenAI, so don't block the read pump
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := c.hub.transcriptRelay.IngestSegment(ctx, roomID, segment); err != nil {
+			log.Printf("Transcript segment from %s in room %s not ingested: %v", c.UserID, roomID, err)
+		}
+	}()
+}
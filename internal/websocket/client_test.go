@@ -0,0 +1,193 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/PRM710/Rankedterview-backend/internal/database"
+)
+
+// newTestHub returns a hub whose Redis is unreachable, so only the room
+// participants cached by joinRoom and locally connected clients are used
+func newTestHub() *Hub {
+	return NewHub(database.NewRedis("127.0.0.1:1", "", 0), "test-secret")
+}
+
+// connect registers a client for userID without a network connection
+func connect(h *Hub, userID string) *Client {
+	client := NewClient(h, nil, userID, ProtocolVersion)
+	h.clients[userID] = client
+	return client
+}
+
+// joinRoom caches a room's participants as they would be read from Redis
+func joinRoom(h *Hub, roomID, user1, user2 string) {
+	h.rooms[roomID] = &roomCache{
+		participants: map[string]string{"user1": user1, "user2": user2, "status": "active"},
+		cachedAt:     time.Now(),
+	}
+}
+
+// received drains the messages queued for a client
+func received(t *testing.T, c *Client) []map[string]interface{} {
+	t.Helper()
+
+	var messages []map[string]interface{}
+	for {
+		select {
+		case payload := <-c.send:
+			var message map[string]interface{}
+			if err := json.Unmarshal(payload, &message); err != nil {
+				t.Fatalf("invalid message for %s: %v", c.UserID, err)
+			}
+			messages = append(messages, message)
+		default:
+			return messages
+		}
+	}
+}
+
+func signalingTypes() []string {
+	return []string{EventWebRTCOffer, EventWebRTCAnswer, EventICECandidate}
+}
+
+// signal builds a signaling frame carrying both an SDP and a candidate, with
+// the given addressing fields
+func signal(eventType, addressing string) []byte {
+	frame := `{"type":"` + eventType + `","sdp":{"type":"offer","sdp":"v=0 o=- 1 1 IN IP4 192.0.2.10"},` +
+		`"candidate":{"candidate":"candidate:1 1 udp 1 192.0.2.10 5000 typ host"}`
+	if addressing != "" {
+		frame += "," + addressing
+	}
+	return []byte(frame + "}")
+}
+
+func TestRelayWebRTCReachesOnlyRoomPartner(t *testing.T) {
+	for _, eventType := range signalingTypes() {
+		t.Run(eventType, func(t *testing.T) {
+			h := newTestHub()
+			alice := connect(h, "alice")
+			bob := connect(h, "bob")
+			eve := connect(h, "eve")
+			carol := connect(h, "carol")
+			joinRoom(h, "room-1", "alice", "bob")
+			joinRoom(h, "room-2", "eve", "carol")
+
+			alice.handleMessage(signal(eventType, `"to":"room-1"`))
+
+			messages := received(t, bob)
+			if len(messages) != 1 {
+				t.Fatalf("partner got %d messages, want 1", len(messages))
+			}
+			if messages[0]["type"] != eventType || messages[0]["from"] != "alice" || messages[0]["roomId"] != "room-1" {
+				t.Errorf("partner got %v", messages[0])
+			}
+
+			for _, other := range []*Client{alice, eve, carol} {
+				if messages := received(t, other); len(messages) != 0 {
+					t.Errorf("%s received signaling meant for bob: %v", other.UserID, messages)
+				}
+			}
+		})
+	}
+}
+
+func TestRelayWebRTCUsesRoomIDField(t *testing.T) {
+	h := newTestHub()
+	alice := connect(h, "alice")
+	bob := connect(h, "bob")
+	eve := connect(h, "eve")
+	joinRoom(h, "room-1", "alice", "bob")
+
+	bob.handleMessage(signal(EventWebRTCAnswer, `"roomId":"room-1"`))
+
+	if messages := received(t, alice); len(messages) != 1 {
+		t.Fatalf("partner got %d messages, want 1", len(messages))
+	}
+	if messages := received(t, eve); len(messages) != 0 {
+		t.Errorf("eve received signaling: %v", messages)
+	}
+}
+
+func TestRelayWebRTCWithoutRoomIsNotBroadcast(t *testing.T) {
+	for _, eventType := range signalingTypes() {
+		t.Run(eventType, func(t *testing.T) {
+			h := newTestHub()
+			alice := connect(h, "alice")
+			bob := connect(h, "bob")
+			eve := connect(h, "eve")
+			joinRoom(h, "room-1", "alice", "bob")
+
+			alice.handleMessage(signal(eventType, ""))
+
+			for _, other := range []*Client{bob, eve} {
+				if messages := received(t, other); len(messages) != 0 {
+					t.Errorf("%s received signaling without a room: %v", other.UserID, messages)
+				}
+			}
+
+			messages := received(t, alice)
+			if len(messages) != 1 || messages[0]["code"] != ErrorInvalidPayload {
+				t.Errorf("sender got %v, want INVALID_PAYLOAD", messages)
+			}
+		})
+	}
+}
+
+func TestRelayWebRTCFromOutsiderIsDropped(t *testing.T) {
+	for _, eventType := range signalingTypes() {
+		t.Run(eventType, func(t *testing.T) {
+			h := newTestHub()
+			alice := connect(h, "alice")
+			bob := connect(h, "bob")
+			eve := connect(h, "eve")
+			joinRoom(h, "room-1", "alice", "bob")
+
+			eve.handleMessage(signal(eventType, `"to":"room-1"`))
+
+			for _, participant := range []*Client{alice, bob} {
+				if messages := received(t, participant); len(messages) != 0 {
+					t.Errorf("%s received signaling from an outsider: %v", participant.UserID, messages)
+				}
+			}
+
+			messages := received(t, eve)
+			if len(messages) != 1 || messages[0]["code"] != ErrorNotRoomParticipant {
+				t.Errorf("outsider got %v, want NOT_ROOM_PARTICIPANT", messages)
+			}
+			if eve.RoomID != "" {
+				t.Errorf("outsider was put in room %q", eve.RoomID)
+			}
+		})
+	}
+}
+
+func TestRelayWebRTCToRoomWithoutPartnerIsDropped(t *testing.T) {
+	h := newTestHub()
+	alice := connect(h, "alice")
+	eve := connect(h, "eve")
+	joinRoom(h, "room-1", "alice", "")
+
+	alice.handleMessage(signal(EventWebRTCOffer, `"to":"room-1"`))
+
+	if messages := received(t, eve); len(messages) != 0 {
+		t.Errorf("eve received signaling: %v", messages)
+	}
+}
+
+func TestRelayWebRTCDoesNotUseHubBroadcast(t *testing.T) {
+	h := newTestHub()
+	alice := connect(h, "alice")
+	connect(h, "bob")
+	joinRoom(h, "room-1", "alice", "bob")
+
+	alice.handleMessage(signal(EventICECandidate, `"to":"room-1"`))
+	alice.handleMessage(signal(EventICECandidate, ""))
+
+	select {
+	case message := <-h.broadcast:
+		t.Errorf("signaling was queued for broadcast: %+v", message)
+	default:
+	}
+}
@@ -0,0 +1,214 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Reliable delivery. Events that must not be lost get a per-user sequence
+// number and are kept in a Redis stream for a while. Clients ack the events
+// they have processed and resume with "?lastSeq=" after reconnecting;
+// anything they missed is replayed in order before newer events.
+const (
+	// How long unacked events are kept after the last one was sent
+	streamTTL = 10 * time.Minute
+
+	// Events kept per user; older ones are trimmed even if unacked
+	streamMaxLen = 500
+)
+
+// reliableEvents are sequenced, kept for replay and never dropped silently
+var reliableEvents = map[string]bool{
+	EventQueueJoined:             true,
+	EventQueueLeft:               true,
+	EventMatchFound:              true,
+	EventPartnerAccepted:         true,
+	EventBothReady:               true,
+	EventCallEnd:                 true,
+	EventPartnerDisconnected:     true,
+	EventPartnerReconnecting:     true,
+	EventPartnerReconnected:      true,
+	EventRecordingConsentRequest: true,
+	EventRecordingConsented:      true,
+	EventRecordingDeclined:       true,
+	EventEvaluationComplete:      true,
+	EventMessage:                 true,
+}
+
+// appendEventScript numbers an event and adds it to the user's stream in one
+// step, so stream IDs (<seq>-0) are added in order whichever node sends them.
+// The sequence key never expires: a client's lastSeq must stay meaningful.
+var appendEventScript = redis.NewScript(`
+local seq = redis.call("INCR", KEYS[1])
+redis.call("XADD", KEYS[2], "MAXLEN", "~", ARGV[2], seq .. "-0", "payload", ARGV[1])
+redis.call("PEXPIRE", KEYS[2], ARGV[3])
+return seq`)
+
+func seqKey(userID string) string {
+	return "ws:seq:" + userID
+}
+
+func streamKey(userID string) string {
+	return "ws:stream:" + userID
+}
+
+// eventType returns the type of an encoded event
+func eventType(payload []byte) string {
+	var head struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(payload, &head)
+	return head.Type
+}
+
+// withSeq adds the sequence number to an encoded event
+func withSeq(payload []byte, seq int64) []byte {
+	if len(payload) < 2 || payload[0] != '{' {
+		return payload
+	}
+
+	sequenced := []byte(`{"seq":` + strconv.FormatInt(seq, 10))
+	if strings.TrimSpace(string(payload[1:])) != "}" {
+		sequenced = append(sequenced, ',')
+	}
+	return append(sequenced, payload[1:]...)
+}
+
+// sequence numbers a reliable event for a user and keeps it for replay
+func (h *Hub) sequence(userID string, payload []byte) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), routingTimeout)
+	defer cancel()
+
+	return appendEventScript.Run(ctx, h.redis.Client, []string{seqKey(userID), streamKey(userID)},
+		payload, streamMaxLen, streamTTL.Milliseconds()).Int64()
+}
+
+// latestSeq returns the sequence number of the last reliable event sent to a user
+func (h *Hub) latestSeq(ctx context.Context, userID string) (int64, error) {
+	value, err := h.redis.Get(ctx, seqKey(userID))
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// ack drops the events a user has processed from their stream
+func (h *Hub) ack(userID string, seq int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), routingTimeout)
+	defer cancel()
+
+	err := h.redis.Client.XTrimMinID(ctx, streamKey(userID), strconv.FormatInt(seq+1, 10)).Err()
+	if err != nil {
+		log.Printf("Failed to trim event stream of %s: %v", userID, err)
+	}
+}
+
+// startSession welcomes a new connection and, when it resumes an earlier one,
+// replays the reliable events it missed. Reliable events sent meanwhile wait
+// for the replay so they arrive in order.
+func (h *Hub) startSession(client *Client) {
+	client.seqMu.Lock()
+	defer client.seqMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), routingTimeout)
+	defer cancel()
+
+	latest, err := h.latestSeq(ctx, client.UserID)
+	if err != nil {
+		log.Printf("Failed to read event sequence of %s: %v", client.UserID, err)
+	} else {
+		// Without lastSeq (or with one from before a Redis reset) the
+		// connection starts at the latest event
+		if !client.resuming || client.lastSeq > latest {
+			client.lastSeq = latest
+		}
+		client.seqKnown = true
+	}
+
+	client.Send(ConnectedEvent{
+		Type:              EventConnected,
+		Message:           "Connected to RANKEDterview",
+		ProtocolVersion:   client.protocolVersion,
+		SupportedVersions: SupportedProtocolVersions,
+		Seq:               client.lastSeq,
+	})
+
+	if client.seqKnown && client.lastSeq < latest {
+		client.replayLocked(latest)
+	}
+}
+
+// deliverSequenced queues a reliable event for the client. Events already
+// replayed are skipped, and any the client hasn't had yet are replayed first.
+func (c *Client) deliverSequenced(seq int64, payload []byte) {
+	c.seqMu.Lock()
+	defer c.seqMu.Unlock()
+
+	if !c.seqKnown {
+		c.lastSeq = seq - 1
+		c.seqKnown = true
+	}
+	if seq <= c.lastSeq {
+		return
+	}
+	if seq > c.lastSeq+1 {
+		c.replayLocked(seq - 1)
+	}
+
+	if c.queue(withSeq(payload, seq)) {
+		c.lastSeq = seq
+	}
+}
+
+// entrySeq returns the sequence number in a stream entry's ID (<seq>-0), or
+// 0 if it has none
+func entrySeq(entry redis.XMessage) int64 {
+	id, _, _ := strings.Cut(entry.ID, "-")
+	seq, _ := strconv.ParseInt(id, 10, 64)
+	return seq
+}
+
+// replayLocked queues the events in the user's stream after the last one the
+// client got, up to and including upTo. If some of them were trimmed from the
+// stream the client is sent a REPLAY_GAP error first. The caller holds seqMu.
+func (c *Client) replayLocked(upTo int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), routingTimeout)
+	defer cancel()
+
+	entries, err := c.hub.redis.Client.XRange(ctx, streamKey(c.UserID),
+		strconv.FormatInt(c.lastSeq+1, 10), strconv.FormatInt(upTo, 10)).Result()
+	if err != nil {
+		log.Printf("Failed to replay events for %s: %v", c.UserID, err)
+		return
+	}
+
+	// Events before the first one kept were trimmed or expired: the client
+	// can't catch up from the stream and has to reload its state
+	if len(entries) == 0 || entrySeq(entries[0]) > c.lastSeq+1 {
+		log.Printf("Events %d to %d of %s are no longer kept", c.lastSeq+1, upTo, c.UserID)
+		c.Send(NewErrorEvent(ErrorReplayGap, "some events you missed are no longer available; reload the current state"))
+	}
+
+	for _, entry := range entries {
+		seq := entrySeq(entry)
+		payload, ok := entry.Values["payload"].(string)
+		if seq == 0 || !ok {
+			continue
+		}
+		if !c.queue(withSeq([]byte(payload), seq)) {
+			return
+		}
+	}
+
+	log.Printf("Replayed %d events to %s (up to seq %d)", len(entries), c.UserID, upTo)
+	c.lastSeq = upTo
+}
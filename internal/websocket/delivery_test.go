@@ -0,0 +1,182 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWithSeq(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    string
+	}{
+		{`{"type":"match_found","roomId":"room-1"}`, `{"seq":7,"type":"match_found","roomId":"room-1"}`},
+		{`{}`, `{"seq":7}`},
+		{`not json`, `not json`},
+	}
+
+	for _, tt := range tests {
+		if got := string(withSeq([]byte(tt.payload), 7)); got != tt.want {
+			t.Errorf("withSeq(%s) = %s, want %s", tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestDeliverSequencedSkipsReplayedEvents(t *testing.T) {
+	h := newTestHub()
+	alice := connect(h, "alice")
+	alice.ResumeFrom(4)
+
+	alice.deliverSequenced(3, []byte(`{"type":"match_found","roomId":"room-1"}`))
+	alice.deliverSequenced(5, []byte(`{"type":"both_ready","roomId":"room-1","role":"caller"}`))
+	alice.deliverSequenced(5, []byte(`{"type":"both_ready","roomId":"room-1","role":"caller"}`))
+
+	messages := received(t, alice)
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1: %v", len(messages), messages)
+	}
+	if messages[0]["type"] != EventBothReady || messages[0]["seq"] != float64(5) {
+		t.Errorf("got %v, want both_ready with seq 5", messages[0])
+	}
+	if alice.lastSeq != 5 {
+		t.Errorf("lastSeq = %d, want 5", alice.lastSeq)
+	}
+}
+
+func TestDeliverSequencedStartsAtFirstEventWithoutResume(t *testing.T) {
+	h := newTestHub()
+	alice := connect(h, "alice")
+
+	alice.deliverSequenced(42, []byte(`{"type":"match_found","roomId":"room-1"}`))
+
+	messages := received(t, alice)
+	if len(messages) != 1 || messages[0]["seq"] != float64(42) {
+		t.Errorf("got %v, want match_found with seq 42", messages)
+	}
+}
+
+func TestFullSendBufferIsNotSilentlyDropped(t *testing.T) {
+	h := newTestHub()
+	alice := connect(h, "alice")
+	alice.ResumeFrom(0)
+
+	for i := 0; i < cap(alice.send); i++ {
+		alice.Send(PongEvent{Type: EventPong})
+	}
+
+	alice.deliverSequenced(1, []byte(`{"type":"match_found","roomId":"room-1"}`))
+	if alice.lastSeq != 0 {
+		t.Errorf("lastSeq = %d after a failed delivery, want 0 so the event is replayed", alice.lastSeq)
+	}
+}
+
+// sendEvents sequences reliable events for a user as if they were sent while
+// the user was away
+func sendEvents(t *testing.T, h *Hub, userID string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := h.sequence(userID, []byte(`{"type":"match_found","roomId":"room-1"}`)); err != nil {
+			t.Fatalf("sequence: %v", err)
+		}
+	}
+}
+
+func TestStartSessionReplaysEventsAfterLastSeq(t *testing.T) {
+	h, _ := newRedisTestHub(t)
+	sendEvents(t, h, "alice", 3)
+	alice := connect(h, "alice")
+	alice.ResumeFrom(1)
+
+	h.startSession(alice)
+
+	messages := received(t, alice)
+	if len(messages) != 3 {
+		t.Fatalf("got %d messages, want connected then 2 replayed events: %v", len(messages), messages)
+	}
+	if messages[0]["type"] != EventConnected || messages[0]["seq"] != float64(1) {
+		t.Errorf("got %v, want connected with seq 1", messages[0])
+	}
+	for i, message := range messages[1:] {
+		if message["type"] != EventMatchFound || message["seq"] != float64(i+2) {
+			t.Errorf("replayed %v, want match_found with seq %d", message, i+2)
+		}
+	}
+	if alice.lastSeq != 3 {
+		t.Errorf("lastSeq = %d, want 3", alice.lastSeq)
+	}
+}
+
+func TestAckTrimsStream(t *testing.T) {
+	h, fake := newRedisTestHub(t)
+	sendEvents(t, h, "alice", 3)
+	alice := connect(h, "alice")
+
+	alice.handleMessage([]byte(`{"type":"ack","seq":2}`))
+
+	if seqs := fake.Stream(streamKey("alice")); len(seqs) != 1 || seqs[0] != 3 {
+		t.Errorf("alice's stream holds %v after acking 2, want [3]", seqs)
+	}
+}
+
+func TestUnregisterDuringStartSession(t *testing.T) {
+	h, _ := newRedisTestHub(t)
+	sendEvents(t, h, "alice", 3)
+	alice := connect(h, "alice")
+	alice.ResumeFrom(1)
+
+	// Hold startSession back until the client is gone, so the welcome and
+	// the replay are queued on a closed send channel
+	alice.seqMu.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.startSession(alice)
+	}()
+	h.unregisterClient(alice)
+	alice.seqMu.Unlock()
+	<-done
+
+	alice.deliverSequenced(4, []byte(`{"type":"match_found","roomId":"room-1"}`))
+	if alice.queue([]byte(`{"type":"pong"}`)) {
+		t.Error("queue accepted a payload for an unregistered client")
+	}
+}
+
+func TestReplayReportsTrimmedEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		ackedTo  int64
+		replayed []float64
+	}{
+		{"older events trimmed", 2, []float64{3}},
+		{"every event trimmed", 3, nil},
+	}
+
+	for _, tt := range tests {
+		h, _ := newRedisTestHub(t)
+		sendEvents(t, h, "alice", 3)
+		h.ack("alice", tt.ackedTo)
+		alice := connect(h, "alice")
+		alice.ResumeFrom(0)
+
+		h.startSession(alice)
+
+		messages := received(t, alice)
+		if len(messages) < 2 || messages[0]["type"] != EventConnected {
+			t.Fatalf("%s: got %v, want connected then a replay gap error", tt.name, messages)
+		}
+		if messages[1]["type"] != EventError || messages[1]["code"] != ErrorReplayGap {
+			t.Errorf("%s: got %v, want a REPLAY_GAP error", tt.name, messages[1])
+		}
+		var replayed []float64
+		for _, message := range messages[2:] {
+			replayed = append(replayed, message["seq"].(float64))
+		}
+		if !reflect.DeepEqual(replayed, tt.replayed) {
+			t.Errorf("%s: replayed seqs %v, want %v", tt.name, replayed, tt.replayed)
+		}
+		if alice.lastSeq != 3 {
+			t.Errorf("%s: lastSeq = %d, want 3", tt.name, alice.lastSeq)
+		}
+	}
+}
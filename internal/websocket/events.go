@@ -1,85 +1,320 @@
 package websocket
 
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/PRM710/Rankedterview-backend/internal/models"
+)
+
 // Event types
 const (
 	// Matchmaking events
 	EventJoinQueue       = "join_queue"
 	EventLeaveQueue      = "leave_queue"
+	EventQueueAck        = "queue_ack"
+	EventQueueLeftAck    = "queue_left_ack"
+	EventQueueJoined     = "queue_joined"
+	EventQueueLeft       = "queue_left"
 	EventMatchFound      = "match_found"
 	EventAcceptMatch     = "accept_match"
 	EventPartnerAccepted = "partner_accepted"
 	EventBothReady       = "both_ready"
 
-	// Room events
-	EventJoinRoom  = "join_room"
-	EventLeaveRoom = "leave_room"
-	EventRoomReady = "room_ready"
-
 	// WebRTC signaling events
 	EventWebRTCOffer  = "webrtc_offer"
 	EventWebRTCAnswer = "webrtc_answer"
 	EventICECandidate = "ice_candidate"
 
 	// Call events
-	EventCallStart           = "call_start"
 	EventCallEnd             = "call_ended"
 	EventMediaStateChange    = "media_state_changed"
 	EventPartnerDisconnected = "partner_disconnected"
+	EventPartnerReconnecting = "partner_reconnecting"
+	EventPartnerReconnected  = "partner_reconnected"
+
+	// Recording consent events; the bot only joins once both users consent
+	EventRecordingConsentRequest = "recording_consent_request"
+	EventRecordingConsent        = "recording_consent"
+	EventRecordingConsented      = "recording_consented"
+	EventRecordingDeclined       = "recording_declined"
 
 	// Interview events
-	EventInterviewStart     = "interview_start"
-	EventInterviewEnd       = "interview_end"
 	EventEvaluationComplete = "evaluation_complete"
 
+	// Live coaching events (practice rooms only)
+	EventTranscriptSegment = "transcript_segment"
+	EventCoachingHint      = "coaching_hint"
+
 	// Chat/messaging
 	EventMessage = "message"
 
+	// Authentication events
+	EventAuth          = "auth"
+	EventAuthOK        = "auth_ok"
+	EventTokenExpiring = "token_expiring"
+
+	// Reliable delivery
+	EventAck = "ack"
+
 	// System events
-	EventConnected    = "connected"
-	EventDisconnected = "disconnected"
-	EventError        = "error"
+	EventPing      = "ping"
+	EventPong      = "pong"
+	EventConnected = "connected"
+	EventError     = "error"
+)
+
+// Error codes sent in ErrorEvent
+const (
+	ErrorMalformedFrame      = "MALFORMED_FRAME"      // not a JSON object with a type
+	ErrorUnknownEvent        = "UNKNOWN_EVENT"        // type isn't a client event
+	ErrorInvalidPayload      = "INVALID_PAYLOAD"      // fields missing or of the wrong type
+	ErrorAuthFailed          = "AUTH_FAILED"          // in-band token refresh rejected
+	ErrorNotRoomParticipant  = "NOT_ROOM_PARTICIPANT" // the room isn't the sender's
+	ErrorConsentPolicyStale  = "CONSENT_POLICY_STALE" // consent was given to an old policy
+	ErrorUnsupportedProtocol = "UNSUPPORTED_PROTOCOL" // no protocol version in common
+	ErrorReplayGap           = "REPLAY_GAP"           // missed events expired; reload state over HTTP
 )
 
-// Event represents a WebSocket event
-type Event struct {
-	Type      string                 `json:"type"`
-	From      string                 `json:"from,omitempty"`
-	To        string                 `json:"to,omitempty"`
-	RoomID    string                 `json:"roomId,omitempty"`
-	Data      map[string]interface{} `json:"data,omitempty"`
-	SDP       interface{}            `json:"sdp,omitempty"`
-	Candidate interface{}            `json:"candidate,omitempty"`
+// Events sent by clients. Every event is a JSON object with a "type"; fields
+// without omitempty are required.
+
+// PingEvent is a client heartbeat, answered with a PongEvent
+type PingEvent struct {
+	Type string `json:"type"`
+}
+
+// AuthEvent authenticates a connection, or refreshes its token in-band
+type AuthEvent struct {
+	Type string   `json:"type"`
+	Data AuthData `json:"data"`
+}
+
+type AuthData struct {
+	Token string `json:"token"`
+}
+
+// QueueRequestEvent asks to join or leave the queue (join_queue, leave_queue).
+// Joining happens over HTTP, so these are only acknowledged.
+type QueueRequestEvent struct {
+	Type string `json:"type"`
+}
+
+// AckEvent acknowledges every reliable event up to and including seq, so
+// they are no longer kept for replay
+type AckEvent struct {
+	Type string `json:"type"`
+	Seq  int64  `json:"seq"`
+}
+
+// AcceptMatchEvent accepts the match found for a room
+type AcceptMatchEvent struct {
+	Type   string `json:"type"`
+	RoomID string `json:"roomId"`
+}
+
+// WebRTCOfferEvent carries an SDP offer. Clients address it to a room with
+// "to" (or "roomId"); it is delivered to their partner with "from" and "roomId" set.
+type WebRTCOfferEvent struct {
+	Type   string          `json:"type"`
+	From   string          `json:"from,omitempty"`
+	To     string          `json:"to,omitempty"`
+	RoomID string          `json:"roomId,omitempty"`
+	SDP    json.RawMessage `json:"sdp"`
+}
+
+// WebRTCAnswerEvent carries an SDP answer, addressed like WebRTCOfferEvent
+type WebRTCAnswerEvent struct {
+	Type   string          `json:"type"`
+	From   string          `json:"from,omitempty"`
+	To     string          `json:"to,omitempty"`
+	RoomID string          `json:"roomId,omitempty"`
+	SDP    json.RawMessage `json:"sdp"`
+}
+
+// ICECandidateEvent carries an ICE candidate, addressed like WebRTCOfferEvent
+type ICECandidateEvent struct {
+	Type      string          `json:"type"`
+	From      string          `json:"from,omitempty"`
+	To        string          `json:"to,omitempty"`
+	RoomID    string          `json:"roomId,omitempty"`
+	Candidate json.RawMessage `json:"candidate"`
+}
+
+// CallEndEvent ends a call. Clients may leave out the room they are in; the
+// partner gets it with "from" set. "reason" is set when the server ends it:
+// "terminated" by an admin, "abandoned" when the user didn't reconnect in time.
+type CallEndEvent struct {
+	Type   string `json:"type"`
+	From   string `json:"from,omitempty"`
+	RoomID string `json:"roomId,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// MediaStateChangeEvent reports that the sender toggled their mic or camera
+type MediaStateChangeEvent struct {
+	Type   string     `json:"type"`
+	RoomID string     `json:"roomId,omitempty"`
+	Data   MediaState `json:"data"`
+}
+
+// MediaState carries the mic and camera flags that changed; a client may
+// send only one of them
+type MediaState struct {
+	IsMuted    *bool `json:"isMuted,omitempty"`
+	IsVideoOff *bool `json:"isVideoOff,omitempty"`
+}
+
+// RecordingConsentEvent answers a RecordingConsentRequestEvent. Granting
+// consent needs the policy version the user was shown.
+type RecordingConsentEvent struct {
+	Type   string               `json:"type"`
+	RoomID string               `json:"roomId,omitempty"`
+	Data   RecordingConsentData `json:"data"`
+}
+
+type RecordingConsentData struct {
+	Granted       *bool  `json:"granted"`
+	PolicyVersion string `json:"policyVersion,omitempty"`
+}
+
+// SendMessageEvent sends a chat message to the sender's room
+type SendMessageEvent struct {
+	Type   string          `json:"type"`
+	RoomID string          `json:"roomId"`
+	Data   ChatMessageData `json:"data"`
+}
+
+type ChatMessageData struct {
+	Message string `json:"message"`
+}
+
+// TranscriptSegmentEvent streams a segment of the sender's speech-to-text
+// for live coaching
+type TranscriptSegmentEvent struct {
+	Type   string                `json:"type"`
+	RoomID string                `json:"roomId,omitempty"`
+	Data   TranscriptSegmentData `json:"data"`
+}
+
+type TranscriptSegmentData struct {
+	Text      string  `json:"text"`
+	StartTime float64 `json:"startTime,omitempty"`
+	EndTime   float64 `json:"endTime,omitempty"`
+}
+
+// Events sent by the server
+
+// ConnectedEvent welcomes a new connection and tells it the protocol version
+// in use. Seq is the last reliable event the connection starts after; missed
+// events, if it resumed, follow.
+type ConnectedEvent struct {
+	Type              string `json:"type"`
+	Message           string `json:"message"`
+	ProtocolVersion   int    `json:"protocolVersion"`
+	SupportedVersions []int  `json:"supportedVersions"`
+	Seq               int64  `json:"seq"`
+}
+
+// PongEvent answers a PingEvent
+type PongEvent struct {
+	Type string `json:"type"`
+}
+
+// AuthOKEvent confirms an in-band token refresh
+type AuthOKEvent struct {
+	Type      string    `json:"type"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// TokenExpiringEvent asks the client to refresh its token before the
+// connection is closed
+type TokenExpiringEvent struct {
+	Type      string    `json:"type"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// QueueEvent reports a queue change (queue_ack, queue_left_ack,
+// queue_joined, queue_left)
+type QueueEvent struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
 }
 
 // MatchFoundEvent is sent when a match is found
 type MatchFoundEvent struct {
 	Type    string `json:"type"`
 	RoomID  string `json:"roomId"`
-	Message string `json:"message"`
+	Message string `json:"message,omitempty"`
 }
 
-// WebRTCOfferEvent represents a WebRTC offer
-type WebRTCOfferEvent struct {
-	Type string                 `json:"type"`
-	From string                 `json:"from"`
-	To   string                 `json:"to"`
-	SDP  map[string]interface{} `json:"sdp"`
+// PartnerAcceptedEvent tells a user their side of the match is accepted and
+// they are waiting on their partner, or that their partner accepted
+type PartnerAcceptedEvent struct {
+	Type    string `json:"type"`
+	RoomID  string `json:"roomId,omitempty"`
+	Message string `json:"message,omitempty"`
 }
 
-// WebRTCAnswerEvent represents a WebRTC answer
-type WebRTCAnswerEvent struct {
-	Type string                 `json:"type"`
-	From string                 `json:"from"`
-	To   string                 `json:"to"`
-	SDP  map[string]interface{} `json:"sdp"`
+// BothReadyEvent starts the call once both users accepted; the caller makes
+// the WebRTC offer
+type BothReadyEvent struct {
+	Type   string `json:"type"`
+	RoomID string `json:"roomId"`
+	Role   string `json:"role"` // "caller" or "callee"
 }
 
-// ICECandidateEvent represents an ICE candidate
-type ICECandidateEvent struct {
-	Type      string                 `json:"type"`
-	From      string                 `json:"from"`
-	To        string                 `json:"to"`
-	Candidate map[string]interface{} `json:"candidate"`
+// PartnerMediaStateEvent tells a user their partner toggled their mic or
+// camera. Flags the partner didn't send are left out.
+type PartnerMediaStateEvent struct {
+	Type       string `json:"type"`
+	From       string `json:"from"`
+	RoomID     string `json:"roomId"`
+	IsMuted    *bool  `json:"isMuted,omitempty"`
+	IsVideoOff *bool  `json:"isVideoOff,omitempty"`
+}
+
+// PartnerDisconnectedEvent tells a user their partner left the call: they
+// dropped out and didn't reconnect in time
+type PartnerDisconnectedEvent struct {
+	Type   string `json:"type"`
+	From   string `json:"from"`
+	RoomID string `json:"roomId"`
+}
+
+// PartnerReconnectingEvent tells a user their partner's connection dropped;
+// the room is held for them until ExpiresAt
+type PartnerReconnectingEvent struct {
+	Type      string    `json:"type"`
+	From      string    `json:"from"`
+	RoomID    string    `json:"roomId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// PartnerReconnectedEvent tells a user their partner is back; the partner's
+// peer connection is gone, so the user sends a new WebRTC offer
+type PartnerReconnectedEvent struct {
+	Type   string `json:"type"`
+	From   string `json:"from"`
+	RoomID string `json:"roomId"`
+}
+
+// RecordingConsentRequestEvent asks a user to consent to the call being
+// recorded under the given policy
+type RecordingConsentRequestEvent struct {
+	Type          string `json:"type"`
+	RoomID        string `json:"roomId"`
+	InterviewID   string `json:"interviewId"`
+	PolicyVersion string `json:"policyVersion"`
+	PolicyURL     string `json:"policyUrl,omitempty"`
+}
+
+// RecordingStatusEvent reports the outcome of the consent request
+// (recording_consented, recording_declined)
+type RecordingStatusEvent struct {
+	Type   string `json:"type"`
+	RoomID string `json:"roomId"`
 }
 
 // MessageEvent represents a chat message
@@ -90,9 +325,89 @@ type MessageEvent struct {
 	Message string `json:"message"`
 }
 
-// ErrorEvent represents an error message
+// CoachingHintEvent is pushed to the interviewee with a live coaching hint
+type CoachingHintEvent struct {
+	Type   string `json:"type"`
+	RoomID string `json:"roomId"`
+	Hint   string `json:"hint"`
+}
+
+// EvaluationCompleteEvent pushes a participant's scores and, if their
+// ranking was updated, their rating change
+type EvaluationCompleteEvent struct {
+	Type         string        `json:"type"`
+	InterviewID  string        `json:"interviewId"`
+	Scores       models.Scores `json:"scores"`
+	Summary      string        `json:"summary"`
+	EloChange    *int          `json:"eloChange,omitempty"`
+	NewElo       *int          `json:"newElo,omitempty"`
+	PreviousRank *int          `json:"previousRank,omitempty"`
+	NewRank      *int          `json:"newRank,omitempty"`
+}
+
+// ErrorEvent represents an error message. Event is the type of the rejected
+// frame, when known.
 type ErrorEvent struct {
 	Type    string `json:"type"`
 	Message string `json:"message"`
 	Code    string `json:"code,omitempty"`
+	Event   string `json:"event,omitempty"`
+	RoomID  string `json:"roomId,omitempty"`
+}
+
+// NewErrorEvent builds an ErrorEvent
+func NewErrorEvent(code, message string) ErrorEvent {
+	return ErrorEvent{Type: EventError, Code: code, Message: message}
+}
+
+// Rules beyond required fields
+
+func (e *WebRTCOfferEvent) validate() error {
+	return validateSignal(e.To, e.RoomID)
+}
+
+func (e *WebRTCAnswerEvent) validate() error {
+	return validateSignal(e.To, e.RoomID)
+}
+
+func (e *ICECandidateEvent) validate() error {
+	return validateSignal(e.To, e.RoomID)
+}
+
+func validateSignal(to, roomID string) error {
+	if to == "" && roomID == "" {
+		return errors.New("to or roomId is required")
+	}
+	return nil
+}
+
+func (e *AckEvent) validate() error {
+	if e.Seq < 1 {
+		return errors.New("seq must be a positive sequence number")
+	}
+	return nil
+}
+
+func (e *MediaStateChangeEvent) validate() error {
+	if e.Data.IsMuted == nil && e.Data.IsVideoOff == nil {
+		return errors.New("data.isMuted or data.isVideoOff is required")
+	}
+	return nil
+}
+
+// Longest chat message relayed
+const maxChatMessageLength = 4000
+
+func (e *SendMessageEvent) validate() error {
+	if len(e.Data.Message) > maxChatMessageLength {
+		return errors.New("data.message is too long")
+	}
+	return nil
+}
+
+func (e *TranscriptSegmentEvent) validate() error {
+	if e.Data.EndTime < e.Data.StartTime {
+		return errors.New("data.endTime is before data.startTime")
+	}
+	return nil
 }
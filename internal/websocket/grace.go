@@ -0,0 +1,241 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Reconnect grace. When a participant's connection drops mid-call their room
+// is held for the grace period and their partner is told they are
+// reconnecting. If they connect again (to any node) in time the call resumes;
+// otherwise the first node to see the window expire ends it for them.
+const (
+	defaultReconnectGrace = 30 * time.Second
+
+	// Open windows, scored by when they expire; members are "<user>|<room>"
+	graceWindowsKey = "ws:grace:windows"
+
+	// How often expired windows are looked for
+	graceSweepInterval = time.Second
+
+	// Windows handled per sweep
+	graceSweepBatch = 100
+
+	// The user's window is kept a little past its expiry so a reconnect
+	// racing the sweep still finds it
+	graceKeyMargin = time.Minute
+)
+
+// holdRoomScript releases a dropped connection's presence and opens its grace
+// window, unless the user has already connected again elsewhere
+var holdRoomScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current ~= false and current ~= ARGV[1] then
+	return 0
+end
+redis.call("DEL", KEYS[1])
+redis.call("SET", KEYS[2], ARGV[2], "PX", ARGV[3])
+redis.call("ZADD", KEYS[3], ARGV[4], ARGV[5])
+return 1`)
+
+// graceKey holds the room a user may reconnect to
+func graceKey(userID string) string {
+	return "ws:grace:" + userID
+}
+
+func graceMember(userID, roomID string) string {
+	return userID + "|" + roomID
+}
+
+// holdRoom is called when a participant's connection drops. Their room is
+// held for the grace period; with no grace period it is abandoned at once.
+func (h *Hub) holdRoom(client *Client, roomID string) {
+	if h.reconnectGrace <= 0 {
+		h.releasePresence(client)
+		h.abandonRoom(client.UserID, roomID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), routingTimeout)
+	defer cancel()
+
+	expiresAt := time.Now().Add(h.reconnectGrace)
+	held, err := holdRoomScript.Run(ctx, h.redis.Client,
+		[]string{presenceKey(client.UserID), graceKey(client.UserID), graceWindowsKey},
+		presenceValue(h.nodeID, client.connID), roomID, (h.reconnectGrace + graceKeyMargin).Milliseconds(),
+		expiresAt.UnixMilli(), graceMember(client.UserID, roomID)).Int()
+	if err != nil {
+		// Without a window the partner can't be told when it ends
+		log.Printf("Failed to hold room %s for %s: %v", roomID, client.UserID, err)
+		h.BroadcastToRoomExcept(roomID, client.UserID, PartnerDisconnectedEvent{
+			Type:   EventPartnerDisconnected,
+			From:   client.UserID,
+			RoomID: roomID,
+		})
+		return
+	}
+	if held == 0 {
+		// Already connected again elsewhere
+		return
+	}
+
+	log.Printf("Holding room %s for %s until %s", roomID, client.UserID, expiresAt.Format(time.RFC3339))
+	h.BroadcastToRoomExcept(roomID, client.UserID, PartnerReconnectingEvent{
+		Type:      EventPartnerReconnecting,
+		From:      client.UserID,
+		RoomID:    roomID,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// resumeRoom puts a new connection back in the room held for it, if its
+// grace window is still open, and tells the partner it is back
+func (h *Hub) resumeRoom(client *Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), routingTimeout)
+	defer cancel()
+
+	roomID, err := h.redis.Client.GetDel(ctx, graceKey(client.UserID)).Result()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("Failed to look up held room of %s: %v", client.UserID, err)
+		}
+		return
+	}
+
+	// Whoever removes the window owns it: the sweep may have just expired it
+	removed, err := h.redis.Client.ZRem(ctx, graceWindowsKey, graceMember(client.UserID, roomID)).Result()
+	if err != nil || removed == 0 {
+		return
+	}
+
+	h.clientsMu.Lock()
+	client.RoomID = roomID
+	h.clientsMu.Unlock()
+
+	log.Printf("User %s reconnected to room %s", client.UserID, roomID)
+	h.BroadcastToRoomExcept(roomID, client.UserID, PartnerReconnectedEvent{
+		Type:   EventPartnerReconnected,
+		From:   client.UserID,
+		RoomID: roomID,
+	})
+}
+
+// EndRoom is called once a room's call is over, whether a participant ended
+// it or it was terminated. Its participants' connections, on any node, leave
+// the room and their grace windows are closed, so dropping out afterwards
+// neither holds nor abandons it.
+func (h *Hub) EndRoom(roomID string) {
+	participants, err := h.getRoomParticipants(roomID)
+	if err != nil {
+		log.Printf("Error getting room participants: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), routingTimeout)
+	defer cancel()
+
+	for _, userID := range []string{participants["user1"], participants["user2"]} {
+		if userID == "" {
+			continue
+		}
+
+		removed, err := h.redis.Client.ZRem(ctx, graceWindowsKey, graceMember(userID, roomID)).Result()
+		if err == nil && removed > 0 {
+			h.redis.Client.Del(ctx, graceKey(userID))
+		}
+
+		if h.leaveLocalRoom(userID, roomID) {
+			continue
+		}
+		if nodeID := h.lookupNode(ctx, userID); nodeID != "" && nodeID != h.nodeID {
+			h.publish(ctx, nodeChannel(nodeID), envelope{
+				Kind:   envelopeLeaveRoom,
+				UserID: userID,
+				RoomID: roomID,
+			})
+		}
+	}
+}
+
+// leaveLocalRoom takes a user connected to this node out of a room, reporting
+// whether they are connected here
+func (h *Hub) leaveLocalRoom(userID, roomID string) bool {
+	h.clientsMu.Lock()
+	defer h.clientsMu.Unlock()
+
+	client, ok := h.clients[userID]
+	if ok && client.RoomID == roomID {
+		client.RoomID = ""
+	}
+	return ok
+}
+
+// expireGraceWindows abandons the rooms of users who didn't reconnect in
+// time. Every node sweeps; removing a window from the set claims it, so each
+// is handled once.
+func (h *Hub) expireGraceWindows() {
+	ticker := time.NewTicker(graceSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-h.shutdown:
+			return
+		}
+
+		h.sweepGraceWindows()
+	}
+}
+
+// sweepGraceWindows abandons the rooms whose grace window has expired
+func (h *Hub) sweepGraceWindows() {
+	ctx, cancel := context.WithTimeout(context.Background(), routingTimeout)
+	defer cancel()
+
+	expired, err := h.redis.Client.ZRangeByScore(ctx, graceWindowsKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Count: graceSweepBatch,
+	}).Result()
+	if err != nil {
+		log.Printf("Failed to look for expired grace windows: %v", err)
+	}
+
+	for _, member := range expired {
+		removed, err := h.redis.Client.ZRem(ctx, graceWindowsKey, member).Result()
+		if err != nil || removed == 0 {
+			continue
+		}
+
+		userID, roomID, _ := strings.Cut(member, "|")
+		h.redis.Client.Del(ctx, graceKey(userID))
+		log.Printf("User %s did not reconnect to room %s in time", userID, roomID)
+		h.abandonRoom(userID, roomID)
+	}
+}
+
+// abandonRoom ends the call for a participant who left it: the partner is
+// told they disconnected, the participant is told the call ended when they
+// come back, and the room observer decides what leaving costs them
+func (h *Hub) abandonRoom(userID, roomID string) {
+	h.BroadcastToRoomExcept(roomID, userID, PartnerDisconnectedEvent{
+		Type:   EventPartnerDisconnected,
+		From:   userID,
+		RoomID: roomID,
+	})
+	h.BroadcastToUser(userID, CallEndEvent{
+		Type:   EventCallEnd,
+		RoomID: roomID,
+		Reason: "abandoned",
+	})
+
+	if h.roomObserver != nil {
+		go h.roomObserver.ParticipantLeft(roomID, userID)
+	}
+}
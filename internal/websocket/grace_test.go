@@ -0,0 +1,207 @@
+package websocket
+
+import (
+	"testing"
+)
+
+// holdForReconnect drops alice's connection from a room shared with bob and
+// holds the room for them
+func holdForReconnect(t *testing.T) (*Hub, *fakeRedis, *Client) {
+	t.Helper()
+
+	h, fake := newRedisTestHub(t)
+	alice := connect(h, "alice")
+	bob := connect(h, "bob")
+	joinRoom(h, "room-1", "alice", "bob")
+	fake.Set(presenceKey("alice"), presenceValue(h.nodeID, alice.connID))
+
+	h.holdRoom(alice, "room-1")
+
+	if roomID, ok := fake.Get(graceKey("alice")); !ok || roomID != "room-1" {
+		t.Fatalf("grace key = %q, want room-1", roomID)
+	}
+	if _, ok := fake.ZScore(graceWindowsKey, graceMember("alice", "room-1")); !ok {
+		t.Fatal("no grace window was opened")
+	}
+	messages := received(t, bob)
+	if len(messages) != 1 || messages[0]["type"] != EventPartnerReconnecting || messages[0]["from"] != "alice" {
+		t.Fatalf("partner got %v, want partner_reconnecting from alice", messages)
+	}
+	return h, fake, bob
+}
+
+// stubObserver records the participants reported as having left their room
+type stubObserver struct {
+	left chan string
+}
+
+func (o *stubObserver) RoomReady(roomID string, userIDs []string)                                  {}
+func (o *stubObserver) RecordingConsent(roomID, userID string, granted bool, policyVersion string) {}
+func (o *stubObserver) CallEnded(roomID, userID string)                                            {}
+func (o *stubObserver) ParticipantLeft(roomID, userID string) {
+	o.left <- roomID + "|" + userID
+}
+
+func TestHoldRoomWithoutGraceAbandonsAtOnce(t *testing.T) {
+	h := newTestHub()
+	h.SetReconnectGrace(0)
+	observer := &stubObserver{left: make(chan string, 1)}
+	h.SetRoomObserver(observer)
+	alice := connect(h, "alice")
+	bob := connect(h, "bob")
+	joinRoom(h, "room-1", "alice", "bob")
+
+	h.holdRoom(alice, "room-1")
+
+	messages := received(t, bob)
+	if len(messages) != 1 || messages[0]["type"] != EventPartnerDisconnected || messages[0]["from"] != "alice" {
+		t.Errorf("partner got %v, want partner_disconnected from alice", messages)
+	}
+	if left := <-observer.left; left != "room-1|alice" {
+		t.Errorf("observer was told %s left, want room-1|alice", left)
+	}
+}
+
+func TestHoldRoomFailureStillTellsPartner(t *testing.T) {
+	h := newTestHub()
+	alice := connect(h, "alice")
+	bob := connect(h, "bob")
+	joinRoom(h, "room-1", "alice", "bob")
+
+	// Redis is unreachable, so no grace window can be opened
+	h.holdRoom(alice, "room-1")
+
+	messages := received(t, bob)
+	if len(messages) != 1 || messages[0]["type"] != EventPartnerDisconnected {
+		t.Errorf("partner got %v, want partner_disconnected", messages)
+	}
+}
+
+func TestEndedCallIsNotHeld(t *testing.T) {
+	h := newTestHub()
+	alice := connect(h, "alice")
+	connect(h, "bob")
+	joinRoom(h, "room-1", "alice", "bob")
+
+	alice.handleMessage(signal(EventWebRTCOffer, `"to":"room-1"`))
+	if alice.RoomID != "room-1" {
+		t.Fatalf("RoomID = %q after signaling, want room-1", alice.RoomID)
+	}
+
+	alice.handleMessage([]byte(`{"type":"call_ended","roomId":"room-1"}`))
+	if alice.RoomID != "" {
+		t.Errorf("RoomID = %q after ending the call, want none", alice.RoomID)
+	}
+}
+
+func TestHoldThenResume(t *testing.T) {
+	h, fake, bob := holdForReconnect(t)
+	observer := &stubObserver{left: make(chan string, 1)}
+	h.SetRoomObserver(observer)
+
+	alice := connect(h, "alice")
+	h.resumeRoom(alice)
+
+	if alice.RoomID != "room-1" {
+		t.Errorf("RoomID = %q after resuming, want room-1", alice.RoomID)
+	}
+	messages := received(t, bob)
+	if len(messages) != 1 || messages[0]["type"] != EventPartnerReconnected || messages[0]["from"] != "alice" {
+		t.Errorf("partner got %v, want partner_reconnected from alice", messages)
+	}
+	if _, ok := fake.Get(graceKey("alice")); ok {
+		t.Error("grace key was kept after resuming")
+	}
+
+	// The window is closed, so a later sweep leaves the room alone
+	h.sweepGraceWindows()
+	select {
+	case left := <-observer.left:
+		t.Errorf("observer was told %s left after resuming", left)
+	default:
+	}
+}
+
+func TestHoldThenExpireAbandonsRoom(t *testing.T) {
+	h, fake, bob := holdForReconnect(t)
+	observer := &stubObserver{left: make(chan string, 1)}
+	h.SetRoomObserver(observer)
+
+	// Expire the window
+	fake.ZAdd(graceWindowsKey, graceMember("alice", "room-1"), 0)
+	h.sweepGraceWindows()
+
+	messages := received(t, bob)
+	if len(messages) != 1 || messages[0]["type"] != EventPartnerDisconnected || messages[0]["from"] != "alice" {
+		t.Errorf("partner got %v, want partner_disconnected from alice", messages)
+	}
+	if left := <-observer.left; left != "room-1|alice" {
+		t.Errorf("observer was told %s left, want room-1|alice", left)
+	}
+	if message := <-h.broadcast; message.UserID != "alice" {
+		t.Errorf("queued %+v, want call_ended for alice", message)
+	} else if event, ok := message.Data.(CallEndEvent); !ok || event.RoomID != "room-1" || event.Reason != "abandoned" {
+		t.Errorf("alice was sent %+v, want call_ended for room-1", message.Data)
+	}
+	if _, ok := fake.Get(graceKey("alice")); ok {
+		t.Error("grace key was kept after the window expired")
+	}
+
+	// A reconnect after the sweep doesn't resume the abandoned room
+	alice := connect(h, "alice")
+	h.resumeRoom(alice)
+	if alice.RoomID != "" {
+		t.Errorf("RoomID = %q after the window expired, want none", alice.RoomID)
+	}
+}
+
+func TestCallEndedTakesBothParticipantsOutOfRoom(t *testing.T) {
+	h, fake := newRedisTestHub(t)
+	alice := connect(h, "alice")
+	bob := connect(h, "bob")
+	joinRoom(h, "room-1", "alice", "bob")
+	alice.RoomID, bob.RoomID = "room-1", "room-1"
+
+	// Bob's earlier drop still has an open window
+	fake.Set(graceKey("bob"), "room-1")
+	fake.ZAdd(graceWindowsKey, graceMember("bob", "room-1"), 0)
+
+	alice.handleMessage([]byte(`{"type":"call_ended","roomId":"room-1"}`))
+
+	if alice.currentRoom() != "" || bob.currentRoom() != "" {
+		t.Errorf("rooms after call_ended = %q, %q; want none", alice.currentRoom(), bob.currentRoom())
+	}
+	if _, ok := fake.ZScore(graceWindowsKey, graceMember("bob", "room-1")); ok {
+		t.Error("bob's grace window is still open")
+	}
+	if _, ok := fake.Get(graceKey("bob")); ok {
+		t.Error("bob's grace key was kept")
+	}
+}
+
+func TestEndRoomReachesParticipantOnOtherNode(t *testing.T) {
+	h, fake := newRedisTestHub(t)
+	alice := connect(h, "alice")
+	joinRoom(h, "room-1", "alice", "bob")
+	alice.RoomID = "room-1"
+	fake.Set(presenceKey("bob"), presenceValue("node-b", "conn-1"))
+
+	h.EndRoom("room-1")
+
+	if alice.currentRoom() != "" {
+		t.Errorf("alice is still in %q", alice.currentRoom())
+	}
+	envelopes := publishedEnvelopes(t, fake, nodeChannel("node-b"))
+	if len(envelopes) != 1 || envelopes[0].Kind != envelopeLeaveRoom || envelopes[0].UserID != "bob" || envelopes[0].RoomID != "room-1" {
+		t.Errorf("node-b got %+v, want bob to leave room-1", envelopes)
+	}
+
+	// On node-b
+	other := newTestHub()
+	bob := connect(other, "bob")
+	bob.RoomID = "room-1"
+	other.handleEnvelope(`{"kind":"leave_room","origin":"node-a","userId":"bob","roomId":"room-1"}`)
+	if bob.currentRoom() != "" {
+		t.Errorf("bob is still in %q", bob.currentRoom())
+	}
+}
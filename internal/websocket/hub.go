@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/PRM710/Rankedterview-backend/internal/database"
+	"github.com/PRM710/Rankedterview-backend/internal/models"
 )
 
 // Configuration for scalability
@@ -20,6 +21,29 @@ const (
 	roomCacheTTL = 30 * time.Second
 )
 
+// TranscriptRelay receives partial transcript segments streamed by clients
+// during a call (implemented by the coaching service)
+type TranscriptRelay interface {
+	IngestSegment(ctx context.Context, roomID string, segment models.TranscriptSegment) error
+}
+
+// SessionChecker reports whether the session an access token was issued for
+// has been revoked (implemented by the auth service)
+type SessionChecker interface {
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+// RoomObserver is told when a call starts and ends, how its users answer
+// the recording consent request, and when a user who dropped out of a call
+// didn't reconnect in time (implemented by the recording service).
+// Methods are called on their own goroutine.
+type RoomObserver interface {
+	RoomReady(roomID string, userIDs []string)
+	RecordingConsent(roomID, userID string, granted bool, policyVersion string)
+	CallEnded(roomID, userID string)
+	ParticipantLeft(roomID, userID string)
+}
+
 // roomCache caches room participants to reduce Redis calls
 type roomCache struct {
 	participants map[string]string
@@ -43,7 +67,7 @@ type Hub struct {
 	// Unregister requests from clients
 	unregister chan *Client
 
-	// Broadcast messages to all clients
+	// Messages for a user or a room
 	broadcast chan *Message
 
 	// Mutex for thread-safe access to clients
@@ -55,22 +79,39 @@ type Hub struct {
 	// Redis for persistence and pub/sub across instances
 	redis *database.RedisClient
 
+	// Identifies this instance in the presence registry and its node channel
+	nodeID string
+
+	// Secret used to validate connection tokens
+	jwtSecret string
+
+	// Rejects in-band token refreshes for revoked sessions (optional)
+	sessionChecker SessionChecker
+
+	// Receives transcript segments relayed by clients (optional)
+	transcriptRelay TranscriptRelay
+
+	// Told when calls start and end (optional)
+	roomObserver RoomObserver
+
+	// How long a dropped participant's room is held for them to reconnect
+	reconnectGrace time.Duration
+
 	// Shutdown channel
 	shutdown chan struct{}
 }
 
 // Message represents a WebSocket message
 type Message struct {
-	Type      string                 `json:"type"`
-	UserID    string                 `json:"userId,omitempty"`
-	RoomID    string                 `json:"roomId,omitempty"`
-	Data      map[string]interface{} `json:"data,omitempty"`
-	Broadcast bool                   `json:"-"`
-	Exclude   string                 `json:"-"` // UserID to exclude from broadcast
+	Type    string      `json:"type"`
+	UserID  string      `json:"userId,omitempty"`
+	RoomID  string      `json:"roomId,omitempty"`
+	Data    interface{} `json:"data,omitempty"` // one of the event types
+	Exclude string      `json:"-"`              // UserID to exclude from a room broadcast
 }
 
 // NewHub creates a new Hub
-func NewHub(redis *database.RedisClient) *Hub {
+func NewHub(redis *database.RedisClient, jwtSecret string) *Hub {
 	return &Hub{
 		clients:    make(map[string]*Client),
 		rooms:      make(map[string]*roomCache),
@@ -79,7 +120,11 @@ func NewHub(redis *database.RedisClient) *Hub {
 		unregister: make(chan *Client, 100),
 		broadcast:  make(chan *Message, broadcastBufferSize),
 		redis:      redis,
+		nodeID:     newNodeID(),
+		jwtSecret:  jwtSecret,
 		shutdown:   make(chan struct{}),
+
+		reconnectGrace: defaultReconnectGrace,
 	}
 }
 
@@ -91,6 +136,11 @@ func (h *Hub) Run() {
 		go h.broadcastWorker(i)
 	}
 
+	// Deliveries from other instances
+	go h.subscribe()
+	go h.refreshPresence()
+	go h.expireGraceWindows()
+
 	// Main loop for register/unregister
 	for {
 		select {
@@ -146,18 +196,15 @@ func (h *Hub) registerClient(client *Client) {
 
 	log.Printf("Client registered: %s (Total: %d)", client.UserID, clientCount)
 
-	// Set user online status in Redis (non-blocking)
+	// Record that this node owns the connection, then put it back in any room
+	// held for it (non-blocking)
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		h.redis.Set(ctx, "user:"+client.UserID+":online", "true", 30*time.Minute)
+		h.claimPresence(client)
+		h.resumeRoom(client)
 	}()
 
-	// Send welcome message
-	client.Send(map[string]interface{}{
-		"type":    "connected",
-		"message": "Connected to RANKEDterview",
-	})
+	// Send welcome message and replay missed events (non-blocking)
+	go h.startSession(client)
 }
 
 // unregisterClient unregisters a client
@@ -168,13 +215,7 @@ func (h *Hub) unregisterClient(client *Client) {
 	if currentClient, ok := h.clients[client.UserID]; ok && currentClient == client {
 		delete(h.clients, client.UserID)
 
-		// Safely close the send channel
-		select {
-		case <-client.send:
-			// Channel already closed
-		default:
-			close(client.send)
-		}
+		client.closeSend()
 
 		clientCount := len(h.clients)
 
@@ -185,22 +226,14 @@ func (h *Hub) unregisterClient(client *Client) {
 
 		log.Printf("Client unregistered: %s (Total: %d)", client.UserID, clientCount)
 
-		// If the client was in a room, notify the partner
+		// If the client was in a room, hold it for them to reconnect;
+		// otherwise just release the connection's presence (non-blocking)
 		if roomID != "" {
-			log.Printf("Client %s was in room %s, notifying partner", client.UserID, roomID)
-			h.BroadcastToRoomExcept(roomID, client.UserID, map[string]interface{}{
-				"type":   "partner_disconnected",
-				"from":   client.UserID,
-				"roomId": roomID,
-			})
+			log.Printf("Client %s was in room %s, holding it", client.UserID, roomID)
+			go h.holdRoom(client, roomID)
+		} else {
+			go h.releasePresence(client)
 		}
-
-		// Remove online status in Redis (non-blocking)
-		go func() {
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-			h.redis.Del(ctx, "user:"+client.UserID+":online")
-		}()
 	} else {
 		h.clientsMu.Unlock()
 	}
@@ -214,48 +247,53 @@ func (h *Hub) handleBroadcast(message *Message) {
 		return
 	}
 
-	if message.Broadcast {
-		h.broadcastToAllClients(payload, message.Exclude)
-	} else if message.UserID != "" {
+	if message.UserID != "" {
 		h.sendToUser(message.UserID, payload)
 	} else if message.RoomID != "" {
 		h.broadcastToRoomInternal(message.RoomID, payload, message.Exclude)
 	}
 }
 
-// broadcastToAllClients sends to all connected clients
-func (h *Hub) broadcastToAllClients(payload []byte, exclude string) {
-	h.clientsMu.RLock()
-	defer h.clientsMu.RUnlock()
-
-	for userID, client := range h.clients {
-		if userID == exclude {
-			continue
+// sendToUser sends to a specific user, wherever they are connected. Reliable
+// events are sequenced first so the user gets them even if they are offline
+// or disconnected before they are delivered.
+func (h *Hub) sendToUser(userID string, payload []byte) {
+	var seq int64
+	if reliableEvents[eventType(payload)] {
+		var err error
+		if seq, err = h.sequence(userID, payload); err != nil {
+			log.Printf("Failed to sequence event for %s, sending it unsequenced: %v", userID, err)
 		}
-		h.sendToClient(client, payload)
+	}
+
+	if !h.sendToLocalUser(userID, payload, seq) {
+		h.forwardToUser(userID, payload, seq)
 	}
 }
 
-// sendToUser sends to a specific user
-func (h *Hub) sendToUser(userID string, payload []byte) {
+// sendToLocalUser sends to a user connected to this node, reporting whether
+// they are. seq is the event's sequence number, or 0 if it isn't sequenced.
+func (h *Hub) sendToLocalUser(userID string, payload []byte, seq int64) bool {
 	h.clientsMu.RLock()
 	client, ok := h.clients[userID]
 	h.clientsMu.RUnlock()
 
-	if ok {
+	if !ok {
+		return false
+	}
+
+	if seq > 0 {
+		client.deliverSequenced(seq, payload)
+	} else {
 		h.sendToClient(client, payload)
 	}
+	return true
 }
 
-// sendToClient sends payload to a client with non-blocking write
+// sendToClient sends payload to a client with non-blocking write; clients
+// that fall behind are disconnected
 func (h *Hub) sendToClient(client *Client, payload []byte) {
-	select {
-	case client.send <- payload:
-		// Message sent
-	default:
-		// Buffer full - log but don't block
-		log.Printf("Send buffer full for user %s, dropping message", client.UserID)
-	}
+	client.queue(payload)
 }
 
 // getRoomParticipants gets room participants with caching
@@ -289,6 +327,26 @@ func (h *Hub) getRoomParticipants(roomID string) (map[string]string, error) {
 	return participants, nil
 }
 
+// roomPartner returns the other participant of a room, if userID is one of
+// its participants and the room has a partner
+func (h *Hub) roomPartner(roomID, userID string) (string, bool) {
+	participants, err := h.getRoomParticipants(roomID)
+	if err != nil {
+		log.Printf("Error getting room participants: %v", err)
+		return "", false
+	}
+
+	var partnerID string
+	switch userID {
+	case participants["user1"]:
+		partnerID = participants["user2"]
+	case participants["user2"]:
+		partnerID = participants["user1"]
+	}
+
+	return partnerID, partnerID != "" && partnerID != userID
+}
+
 // invalidateRoomCache invalidates the room cache
 func (h *Hub) invalidateRoomCache(roomID string) {
 	h.roomsMu.Lock()
@@ -308,29 +366,23 @@ func (h *Hub) broadcastToRoomInternal(roomID string, payload []byte, exclude str
 
 	log.Printf("Room %s participants from Redis: %+v", roomID, participants)
 
-	h.clientsMu.RLock()
-	defer h.clientsMu.RUnlock()
-
+	// Each participant gets their own sequence number, wherever they are connected
 	sentCount := 0
 	for key, userID := range participants {
 		log.Printf("Checking participant: key=%s, userID=%s, exclude=%s", key, userID, exclude)
-		if (key == "user1" || key == "user2") && userID != exclude {
-			if client, ok := h.clients[userID]; ok {
-				log.Printf("Sending to user %s", userID)
-				h.sendToClient(client, payload)
-				sentCount++
-			} else {
-				log.Printf("User %s not connected (not in h.clients)", userID)
-			}
+		if (key == "user1" || key == "user2") && userID != "" && userID != exclude {
+			log.Printf("Sending to user %s", userID)
+			h.sendToUser(userID, payload)
+			sentCount++
 		}
 	}
-	log.Printf("broadcastToRoomInternal: sent to %d clients", sentCount)
+	log.Printf("broadcastToRoomInternal: sent to %d participants", sentCount)
 }
 
 // Public methods
 
 // BroadcastToRoomExcept broadcasts a message to all users in a room except the specified user
-func (h *Hub) BroadcastToRoomExcept(roomID string, excludeUserID string, data map[string]interface{}) {
+func (h *Hub) BroadcastToRoomExcept(roomID string, excludeUserID string, data interface{}) {
 	payload, err := json.Marshal(data)
 	if err != nil {
 		log.Printf("Error marshaling message: %v", err)
@@ -340,55 +392,87 @@ func (h *Hub) BroadcastToRoomExcept(roomID string, excludeUserID string, data ma
 	h.broadcastToRoomInternal(roomID, payload, excludeUserID)
 }
 
-// BroadcastToAll broadcasts a message to all connected clients
-func (h *Hub) BroadcastToAll(data map[string]interface{}) {
-	select {
-	case h.broadcast <- &Message{Data: data, Broadcast: true}:
-	default:
-		log.Println("Broadcast buffer full, dropping message")
-	}
-}
-
-// BroadcastToAllExcept broadcasts to all connected clients except the specified user
-func (h *Hub) BroadcastToAllExcept(excludeUserID string, data map[string]interface{}) {
-	select {
-	case h.broadcast <- &Message{Data: data, Broadcast: true, Exclude: excludeUserID}:
-	default:
-		log.Println("Broadcast buffer full, dropping message")
-	}
-}
-
 // BroadcastToUser sends a message to a specific user
-func (h *Hub) BroadcastToUser(userID string, data map[string]interface{}) {
-	select {
-	case h.broadcast <- &Message{UserID: userID, Data: data}:
-	default:
-		log.Printf("Broadcast buffer full for user %s, dropping message", userID)
-	}
+func (h *Hub) BroadcastToUser(userID string, data interface{}) {
+	h.queueTargeted(&Message{UserID: userID, Data: data})
 }
 
 // BroadcastToRoom broadcasts to all users in a room
-func (h *Hub) BroadcastToRoom(roomID string, data map[string]interface{}) {
+func (h *Hub) BroadcastToRoom(roomID string, data interface{}) {
+	h.queueTargeted(&Message{RoomID: roomID, Data: data})
+}
+
+// queueTargeted queues a message for a user or room. Such messages may carry
+// reliable events, so when the buffer is full they are handled on their own
+// goroutine rather than dropped.
+func (h *Hub) queueTargeted(message *Message) {
 	select {
-	case h.broadcast <- &Message{RoomID: roomID, Data: data}:
+	case h.broadcast <- message:
 	default:
-		log.Printf("Broadcast buffer full for room %s, dropping message", roomID)
+		log.Printf("Broadcast buffer full, handling message for user %q room %q directly", message.UserID, message.RoomID)
+		go h.handleBroadcast(message)
 	}
 }
 
-// GetOnlineUsers returns the number of online users
+// GetOnlineUsers returns the number of users connected to this node
 func (h *Hub) GetOnlineUsers() int {
 	h.clientsMu.RLock()
 	defer h.clientsMu.RUnlock()
 	return len(h.clients)
 }
 
-// IsUserOnline checks if a user is connected
+// IsUserOnline checks if a user is connected to any node
 func (h *Hub) IsUserOnline(userID string) bool {
 	h.clientsMu.RLock()
-	defer h.clientsMu.RUnlock()
 	_, exists := h.clients[userID]
-	return exists
+	h.clientsMu.RUnlock()
+	if exists {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), routingTimeout)
+	defer cancel()
+	return h.lookupNode(ctx, userID) != ""
+}
+
+// DisconnectUser closes a user's connection, on whichever node it is, with an
+// application close code. ReadPump then unregisters the client as for any
+// other disconnect. It reports whether the user was connected.
+func (h *Hub) DisconnectUser(userID string, code int, reason string) bool {
+	if h.disconnectLocalUser(userID, code, reason) {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), routingTimeout)
+	defer cancel()
+
+	nodeID := h.lookupNode(ctx, userID)
+	if nodeID == "" || nodeID == h.nodeID {
+		return false
+	}
+
+	h.publish(ctx, nodeChannel(nodeID), envelope{
+		Kind:   envelopeDisconnect,
+		UserID: userID,
+		Code:   code,
+		Reason: reason,
+	})
+	return true
+}
+
+// disconnectLocalUser closes the connection of a user connected to this node
+func (h *Hub) disconnectLocalUser(userID string, code int, reason string) bool {
+	h.clientsMu.RLock()
+	client, ok := h.clients[userID]
+	h.clientsMu.RUnlock()
+
+	if !ok {
+		return false
+	}
+
+	log.Printf("Disconnecting user %s: %s", userID, reason)
+	CloseConnection(client.conn, code, reason)
+	return true
 }
 
 // Register adds a client to the hub
@@ -409,6 +493,30 @@ func (h *Hub) Unregister(client *Client) {
 	}
 }
 
+// SetSessionChecker sets what in-band token refreshes are checked against
+// for revoked sessions. Must be called before Run.
+func (h *Hub) SetSessionChecker(checker SessionChecker) {
+	h.sessionChecker = checker
+}
+
+// SetTranscriptRelay sets where client-relayed transcript segments are sent.
+// Must be called before Run.
+func (h *Hub) SetTranscriptRelay(relay TranscriptRelay) {
+	h.transcriptRelay = relay
+}
+
+// SetRoomObserver sets who is told when calls start and end. Must be called before Run.
+func (h *Hub) SetRoomObserver(observer RoomObserver) {
+	h.roomObserver = observer
+}
+
+// SetReconnectGrace sets how long a participant who drops out of a call has
+// to reconnect before the call ends for them; 0 ends it at once. Must be
+// called before Run.
+func (h *Hub) SetReconnectGrace(grace time.Duration) {
+	h.reconnectGrace = grace
+}
+
 // Shutdown gracefully shuts down the hub
 func (h *Hub) Shutdown() {
 	close(h.shutdown)
@@ -0,0 +1,274 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cross-instance delivery. Every instance (node) records the connections it
+// owns in a Redis presence registry and subscribes to its own node channel
+// and to the broadcast channel. Messages for a user connected elsewhere are
+// published to the owning node's channel; broadcasts go to every node.
+const (
+	// Presence entries expire unless their node keeps refreshing them, so a
+	// crashed node's users don't look online for long
+	presenceTTL             = 2 * time.Minute
+	presenceRefreshInterval = 30 * time.Second
+
+	// Timeout of the Redis calls made while routing a message
+	routingTimeout = 5 * time.Second
+)
+
+// Kinds of envelope sent between nodes
+const (
+	envelopeDeliver    = "deliver"    // send payload to a user
+	envelopeDisconnect = "disconnect" // close a user's connection with a close code
+	envelopeReplaced   = "replaced"   // the user connected elsewhere; close the old connection
+	envelopeLeaveRoom  = "leave_room" // the user's call in a room is over
+)
+
+// envelope is a message routed to another node over Redis
+type envelope struct {
+	Kind    string          `json:"kind"`
+	Origin  string          `json:"origin"`
+	UserID  string          `json:"userId,omitempty"`
+	ConnID  string          `json:"connId,omitempty"`
+	RoomID  string          `json:"roomId,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Seq     int64           `json:"seq,omitempty"` // sequence number of a reliable event
+	Code    int             `json:"code,omitempty"`
+	Reason  string          `json:"reason,omitempty"`
+}
+
+// Presence is only released or refreshed by the connection that owns it, so
+// a node never removes an entry written for a newer connection
+var (
+	releasePresenceScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	refreshPresenceScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current == false or current == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+return 0`)
+)
+
+func presenceKey(userID string) string {
+	return "ws:presence:" + userID
+}
+
+func nodeChannel(nodeID string) string {
+	return "ws:node:" + nodeID
+}
+
+// presenceValue identifies a connection as "<node>|<connection>"
+func presenceValue(nodeID, connID string) string {
+	return nodeID + "|" + connID
+}
+
+func parsePresence(value string) (nodeID, connID string) {
+	nodeID, connID, _ = strings.Cut(value, "|")
+	return nodeID, connID
+}
+
+// newID returns a random hex identifier
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newNodeID names this instance; the hostname makes logs easier to follow
+func newNodeID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return newID()
+	}
+	return host + "-" + newID()[:8]
+}
+
+// claimPresence records that this node owns the client's connection. If the
+// user was connected to another node, that node is told to close the old
+// connection, as registerClient does for connections on this node.
+func (h *Hub) claimPresence(client *Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), routingTimeout)
+	defer cancel()
+
+	previous, err := h.redis.Client.SetArgs(ctx, presenceKey(client.UserID), presenceValue(h.nodeID, client.connID), redis.SetArgs{
+		TTL: presenceTTL,
+		Get: true,
+	}).Result()
+	if err == redis.Nil {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to record presence of %s: %v", client.UserID, err)
+		return
+	}
+
+	nodeID, connID := parsePresence(previous)
+	if nodeID != "" && nodeID != h.nodeID {
+		h.publish(ctx, nodeChannel(nodeID), envelope{
+			Kind:   envelopeReplaced,
+			UserID: client.UserID,
+			ConnID: connID,
+		})
+	}
+}
+
+// releasePresence removes the client's presence entry, unless the user has
+// since connected again (here or on another node)
+func (h *Hub) releasePresence(client *Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), routingTimeout)
+	defer cancel()
+
+	err := releasePresenceScript.Run(ctx, h.redis.Client, []string{presenceKey(client.UserID)},
+		presenceValue(h.nodeID, client.connID)).Err()
+	if err != nil && err != redis.Nil {
+		log.Printf("Failed to release presence of %s: %v", client.UserID, err)
+	}
+}
+
+// refreshPresence keeps the presence entries of this node's connections alive
+func (h *Hub) refreshPresence() {
+	ticker := time.NewTicker(presenceRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-h.shutdown:
+			return
+		}
+
+		h.clientsMu.RLock()
+		clients := make([]*Client, 0, len(h.clients))
+		for _, client := range h.clients {
+			clients = append(clients, client)
+		}
+		h.clientsMu.RUnlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), routingTimeout)
+		pipe := h.redis.Client.Pipeline()
+		for _, client := range clients {
+			refreshPresenceScript.Eval(ctx, pipe, []string{presenceKey(client.UserID)},
+				presenceValue(h.nodeID, client.connID), presenceTTL.Milliseconds())
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			log.Printf("Failed to refresh presence: %v", err)
+		}
+		cancel()
+	}
+}
+
+// lookupNode returns the node that owns the user's connection, or "" if the
+// user isn't connected anywhere
+func (h *Hub) lookupNode(ctx context.Context, userID string) string {
+	value, err := h.redis.Get(ctx, presenceKey(userID))
+	if err != nil {
+		return ""
+	}
+	nodeID, _ := parsePresence(value)
+	return nodeID
+}
+
+// forwardToUser publishes a payload to the node the user is connected to.
+// Users with no presence, or whose entry points back here, are offline;
+// sequenced events wait in their stream until they resume.
+func (h *Hub) forwardToUser(userID string, payload []byte, seq int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), routingTimeout)
+	defer cancel()
+
+	nodeID := h.lookupNode(ctx, userID)
+	if nodeID == "" || nodeID == h.nodeID {
+		return
+	}
+
+	h.publish(ctx, nodeChannel(nodeID), envelope{
+		Kind:    envelopeDeliver,
+		UserID:  userID,
+		Payload: payload,
+		Seq:     seq,
+	})
+}
+
+func (h *Hub) publish(ctx context.Context, channel string, env envelope) {
+	env.Origin = h.nodeID
+
+	message, err := json.Marshal(env)
+	if err != nil {
+		log.Printf("Error marshaling envelope: %v", err)
+		return
+	}
+
+	if err := h.redis.Publish(ctx, channel, message); err != nil {
+		log.Printf("Failed to publish to %s: %v", channel, err)
+	}
+}
+
+// subscribe receives envelopes sent to this node until the hub shuts down. The subscription reconnects by itself if
+// the Redis connection drops.
+func (h *Hub) subscribe() {
+	pubsub := h.redis.Subscribe(context.Background(), nodeChannel(h.nodeID))
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			h.handleEnvelope(msg.Payload)
+		case <-h.shutdown:
+			return
+		}
+	}
+}
+
+// handleEnvelope acts on an envelope from another node. Deliveries only reach
+// local clients; they are never forwarded again.
+func (h *Hub) handleEnvelope(message string) {
+	var env envelope
+	if err := json.Unmarshal([]byte(message), &env); err != nil {
+		log.Printf("Error unmarshaling envelope: %v", err)
+		return
+	}
+	if env.Origin == h.nodeID {
+		return
+	}
+
+	switch env.Kind {
+	case envelopeDeliver:
+		h.sendToLocalUser(env.UserID, env.Payload, env.Seq)
+
+	case envelopeDisconnect:
+		h.disconnectLocalUser(env.UserID, env.Code, env.Reason)
+
+	case envelopeLeaveRoom:
+		h.leaveLocalRoom(env.UserID, env.RoomID)
+
+	case envelopeReplaced:
+		h.clientsMu.RLock()
+		client, ok := h.clients[env.UserID]
+		h.clientsMu.RUnlock()
+
+		if ok && client.connID == env.ConnID && client.conn != nil {
+			log.Printf("User %s connected to node %s, closing connection here", env.UserID, env.Origin)
+			client.conn.Close()
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// publishedEnvelopes decodes the envelopes published to a channel
+func publishedEnvelopes(t *testing.T, fake *fakeRedis, channel string) []envelope {
+	t.Helper()
+
+	var envelopes []envelope
+	for _, message := range fake.Published() {
+		if message.channel != channel {
+			continue
+		}
+		var env envelope
+		if err := json.Unmarshal([]byte(message.message), &env); err != nil {
+			t.Fatalf("invalid envelope on %s: %v", channel, err)
+		}
+		envelopes = append(envelopes, env)
+	}
+	return envelopes
+}
+
+func TestClaimPresenceReplacesConnectionOnOtherNode(t *testing.T) {
+	h, fake := newRedisTestHub(t)
+	fake.Set(presenceKey("alice"), presenceValue("node-b", "conn-1"))
+	alice := NewClient(h, nil, "alice", ProtocolVersion)
+
+	h.claimPresence(alice)
+
+	if value, _ := fake.Get(presenceKey("alice")); value != presenceValue(h.nodeID, alice.connID) {
+		t.Errorf("presence = %q, want this node's connection", value)
+	}
+	envelopes := publishedEnvelopes(t, fake, nodeChannel("node-b"))
+	if len(envelopes) != 1 {
+		t.Fatalf("node-b got %d envelopes, want 1", len(envelopes))
+	}
+	want := envelope{Kind: envelopeReplaced, Origin: h.nodeID, UserID: "alice", ConnID: "conn-1"}
+	if env := envelopes[0]; env.Kind != want.Kind || env.Origin != want.Origin || env.UserID != want.UserID || env.ConnID != want.ConnID {
+		t.Errorf("node-b got %+v, want %+v", env, want)
+	}
+}
+
+func TestClaimPresenceOnSameNodePublishesNothing(t *testing.T) {
+	h, fake := newRedisTestHub(t)
+	fake.Set(presenceKey("alice"), presenceValue(h.nodeID, "old-conn"))
+
+	h.claimPresence(NewClient(h, nil, "alice", ProtocolVersion))
+
+	if messages := fake.Published(); len(messages) != 0 {
+		t.Errorf("published %v for a reconnect on this node", messages)
+	}
+}
+
+func TestReleasePresenceKeepsNewerConnection(t *testing.T) {
+	h, fake := newRedisTestHub(t)
+	old := NewClient(h, nil, "alice", ProtocolVersion)
+	fake.Set(presenceKey("alice"), presenceValue("node-b", "conn-2"))
+
+	h.releasePresence(old)
+
+	if value, ok := fake.Get(presenceKey("alice")); !ok || value != presenceValue("node-b", "conn-2") {
+		t.Errorf("presence = %q, want the newer connection on node-b", value)
+	}
+
+	fake.Set(presenceKey("alice"), presenceValue(h.nodeID, old.connID))
+	h.releasePresence(old)
+	if _, ok := fake.Get(presenceKey("alice")); ok {
+		t.Error("presence of the released connection was kept")
+	}
+}
+
+func TestSendToUserForwardsToOwningNode(t *testing.T) {
+	h, fake := newRedisTestHub(t)
+	fake.Set(presenceKey("bob"), presenceValue("node-b", "conn-1"))
+
+	h.sendToUser("bob", []byte(`{"type":"partner_media_state","from":"alice"}`))
+	h.sendToUser("bob", []byte(`{"type":"match_found","roomId":"room-1"}`))
+
+	envelopes := publishedEnvelopes(t, fake, nodeChannel("node-b"))
+	if len(envelopes) != 2 {
+		t.Fatalf("node-b got %d envelopes, want 2", len(envelopes))
+	}
+	for _, env := range envelopes {
+		if env.Kind != envelopeDeliver || env.UserID != "bob" || env.Origin != h.nodeID {
+			t.Errorf("node-b got %+v, want a delivery to bob from this node", env)
+		}
+	}
+	if envelopes[0].Seq != 0 {
+		t.Errorf("unreliable event forwarded with seq %d", envelopes[0].Seq)
+	}
+	if envelopes[1].Seq != 1 || string(envelopes[1].Payload) != `{"type":"match_found","roomId":"room-1"}` {
+		t.Errorf("reliable event forwarded as %+v, want seq 1", envelopes[1])
+	}
+	if seqs := fake.Stream(streamKey("bob")); len(seqs) != 1 || seqs[0] != 1 {
+		t.Errorf("bob's stream holds %v, want [1]", seqs)
+	}
+}
+
+func TestSendToOfflineUserPublishesNothing(t *testing.T) {
+	h, fake := newRedisTestHub(t)
+	fake.Set(presenceKey("carol"), presenceValue(h.nodeID, "gone"))
+
+	h.sendToUser("bob", []byte(`{"type":"match_found","roomId":"room-1"}`))
+	h.sendToUser("carol", []byte(`{"type":"match_found","roomId":"room-1"}`))
+
+	if messages := fake.Published(); len(messages) != 0 {
+		t.Errorf("published %v for offline users", messages)
+	}
+	if seqs := fake.Stream(streamKey("bob")); len(seqs) != 1 {
+		t.Errorf("bob's stream holds %v, want the event kept for replay", seqs)
+	}
+}
+
+func TestDisconnectUserOnOtherNode(t *testing.T) {
+	h, fake := newRedisTestHub(t)
+	fake.Set(presenceKey("bob"), presenceValue("node-b", "conn-1"))
+
+	if !h.DisconnectUser("bob", CloseAccountBanned, "account banned") {
+		t.Fatal("DisconnectUser reported bob as offline")
+	}
+
+	envelopes := publishedEnvelopes(t, fake, nodeChannel("node-b"))
+	if len(envelopes) != 1 || envelopes[0].Kind != envelopeDisconnect || envelopes[0].Code != CloseAccountBanned || envelopes[0].Reason != "account banned" {
+		t.Errorf("node-b got %+v, want a disconnect with the ban close code", envelopes)
+	}
+	if h.DisconnectUser("carol", CloseAccountBanned, "account banned") {
+		t.Error("DisconnectUser reported carol, who has no presence, as connected")
+	}
+}
+
+func TestHandleEnvelopeDeliversToLocalUser(t *testing.T) {
+	h := newTestHub()
+	alice := connect(h, "alice")
+	bob := connect(h, "bob")
+	alice.ResumeFrom(2)
+
+	h.handleEnvelope(`{"kind":"deliver","origin":"node-b","userId":"alice","payload":{"type":"match_found","roomId":"room-1"},"seq":3}`)
+	h.handleEnvelope(`{"kind":"deliver","origin":"node-b","userId":"alice","payload":{"type":"pong"}}`)
+
+	messages := received(t, alice)
+	if len(messages) != 2 || messages[0]["type"] != EventMatchFound || messages[0]["seq"] != float64(3) || messages[1]["type"] != EventPong {
+		t.Errorf("alice got %v, want match_found with seq 3 then pong", messages)
+	}
+	if messages := received(t, bob); len(messages) != 0 {
+		t.Errorf("bob got %v", messages)
+	}
+}
+
+func TestHandleEnvelopeIgnoresOwnAndMalformedEnvelopes(t *testing.T) {
+	h := newTestHub()
+	alice := connect(h, "alice")
+
+	h.handleEnvelope(`{"kind":"deliver","origin":"` + h.nodeID + `","payload":{"type":"pong"}}`)
+	h.handleEnvelope(`{"kind":"deliver","origin":"node-b","userId":"carol","payload":{"type":"pong"}}`)
+	h.handleEnvelope(`{"kind":"deliver","origin":"node-b","payload":{"type":"pong"}}`)
+	h.handleEnvelope(`not json`)
+
+	if messages := received(t, alice); len(messages) != 0 {
+		t.Errorf("alice got %v", messages)
+	}
+}
+
+func TestReconnectReplacesLocalClient(t *testing.T) {
+	h, _ := newRedisTestHub(t)
+	old := connect(h, "alice")
+
+	current := NewClient(h, nil, "alice", ProtocolVersion)
+	h.registerClient(current)
+
+	h.clientsMu.RLock()
+	registered := h.clients["alice"]
+	h.clientsMu.RUnlock()
+	if registered != current {
+		t.Fatal("the new connection did not replace the old one")
+	}
+
+	// The old connection unregistering late must not remove the new one
+	h.unregisterClient(old)
+
+	h.clientsMu.RLock()
+	registered = h.clients["alice"]
+	h.clientsMu.RUnlock()
+	if registered != current {
+		t.Error("unregistering the old connection removed the new one")
+	}
+	if !h.sendToLocalUser("alice", []byte(`{"type":"pong"}`), 0) {
+		t.Error("alice is not reachable on the new connection")
+	}
+}
@@ -0,0 +1,211 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ProtocolVersion is the newest version of the event protocol. Clients offer
+// the versions they speak with "?protocol=1,2" when connecting; the highest
+// version both sides support is used, and clients that offer none get the
+// current one.
+const ProtocolVersion = 1
+
+// SupportedProtocolVersions lists every version the server still speaks
+var SupportedProtocolVersions = []int{1}
+
+var ErrUnsupportedProtocol = errors.New("no supported protocol version offered")
+
+// NegotiateProtocol picks the protocol version for a connection from the
+// comma-separated versions the client offered
+func NegotiateProtocol(offered string) (int, error) {
+	if strings.TrimSpace(offered) == "" {
+		return ProtocolVersion, nil
+	}
+
+	version := 0
+	for _, v := range strings.Split(offered, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n <= version {
+			continue
+		}
+		for _, supported := range SupportedProtocolVersions {
+			if n == supported {
+				version = n
+			}
+		}
+	}
+
+	if version == 0 {
+		return 0, ErrUnsupportedProtocol
+	}
+	return version, nil
+}
+
+// clientEvents maps each event clients may send to its payload type
+var clientEvents = map[string]interface{}{
+	EventPing:              PingEvent{},
+	EventAuth:              AuthEvent{},
+	EventAck:               AckEvent{},
+	EventJoinQueue:         QueueRequestEvent{},
+	EventLeaveQueue:        QueueRequestEvent{},
+	EventAcceptMatch:       AcceptMatchEvent{},
+	EventWebRTCOffer:       WebRTCOfferEvent{},
+	EventWebRTCAnswer:      WebRTCAnswerEvent{},
+	EventICECandidate:      ICECandidateEvent{},
+	EventCallEnd:           CallEndEvent{},
+	EventMediaStateChange:  MediaStateChangeEvent{},
+	EventRecordingConsent:  RecordingConsentEvent{},
+	EventMessage:           SendMessageEvent{},
+	EventTranscriptSegment: TranscriptSegmentEvent{},
+}
+
+// serverEvents maps each event the server sends to its payload type
+var serverEvents = map[string]interface{}{
+	EventConnected:               ConnectedEvent{},
+	EventPong:                    PongEvent{},
+	EventAuthOK:                  AuthOKEvent{},
+	EventTokenExpiring:           TokenExpiringEvent{},
+	EventQueueAck:                QueueEvent{},
+	EventQueueLeftAck:            QueueEvent{},
+	EventQueueJoined:             QueueEvent{},
+	EventQueueLeft:               QueueEvent{},
+	EventMatchFound:              MatchFoundEvent{},
+	EventPartnerAccepted:         PartnerAcceptedEvent{},
+	EventBothReady:               BothReadyEvent{},
+	EventWebRTCOffer:             WebRTCOfferEvent{},
+	EventWebRTCAnswer:            WebRTCAnswerEvent{},
+	EventICECandidate:            ICECandidateEvent{},
+	EventCallEnd:                 CallEndEvent{},
+	EventMediaStateChange:        PartnerMediaStateEvent{},
+	EventPartnerDisconnected:     PartnerDisconnectedEvent{},
+	EventPartnerReconnecting:     PartnerReconnectingEvent{},
+	EventPartnerReconnected:      PartnerReconnectedEvent{},
+	EventRecordingConsentRequest: RecordingConsentRequestEvent{},
+	EventRecordingConsented:      RecordingStatusEvent{},
+	EventRecordingDeclined:       RecordingStatusEvent{},
+	EventEvaluationComplete:      EvaluationCompleteEvent{},
+	EventCoachingHint:            CoachingHintEvent{},
+	EventMessage:                 MessageEvent{},
+	EventError:                   ErrorEvent{},
+}
+
+// validator is implemented by events with rules beyond required fields
+type validator interface {
+	validate() error
+}
+
+// FrameError rejects a frame a client sent. It is reported back to the
+// client as an ErrorEvent.
+type FrameError struct {
+	Code    string
+	Event   string
+	Message string
+}
+
+func (e *FrameError) Error() string {
+	return e.Message
+}
+
+// ErrorEvent returns the event that reports the rejection to the client
+func (e *FrameError) ErrorEvent() ErrorEvent {
+	event := NewErrorEvent(e.Code, e.Message)
+	event.Event = e.Event
+	return event
+}
+
+// DecodeEvent parses and validates a frame sent by a client, returning a
+// pointer to its typed payload
+func DecodeEvent(frame []byte) (interface{}, error) {
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(frame, &head); err != nil || head.Type == "" {
+		return nil, &FrameError{Code: ErrorMalformedFrame, Message: "frames must be JSON objects with a string type"}
+	}
+
+	proto, ok := clientEvents[head.Type]
+	if !ok {
+		return nil, &FrameError{Code: ErrorUnknownEvent, Event: head.Type, Message: "unknown event type " + head.Type}
+	}
+
+	event := reflect.New(reflect.TypeOf(proto))
+	if err := json.Unmarshal(frame, event.Interface()); err != nil {
+		return nil, &FrameError{Code: ErrorInvalidPayload, Event: head.Type, Message: payloadErrorMessage(err)}
+	}
+
+	if field := missingField(event.Elem(), ""); field != "" {
+		return nil, &FrameError{Code: ErrorInvalidPayload, Event: head.Type, Message: field + " is required"}
+	}
+
+	if v, ok := event.Interface().(validator); ok {
+		if err := v.validate(); err != nil {
+			return nil, &FrameError{Code: ErrorInvalidPayload, Event: head.Type, Message: err.Error()}
+		}
+	}
+
+	return event.Interface(), nil
+}
+
+func payloadErrorMessage(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return fmt.Sprintf("%s must be %s, not %s", typeErr.Field, jsonTypeName(typeErr.Type), typeErr.Value)
+	}
+	return "invalid payload: " + err.Error()
+}
+
+// missingField returns the path of the first required field left out of an
+// event. Fields without omitempty are required; booleans and numbers that
+// clients must send are pointers so a zero value can be told from none.
+func missingField(v reflect.Value, prefix string) string {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, omitempty := jsonField(t.Field(i))
+		if name == "" || omitempty {
+			continue
+		}
+		path := prefix + name
+
+		field := v.Field(i)
+		switch {
+		case field.Type() == rawMessageType:
+			raw := field.Bytes()
+			if len(raw) == 0 || string(raw) == "null" {
+				return path
+			}
+		case field.Kind() == reflect.Struct && field.Type() != timeType:
+			if missing := missingField(field, path+"."); missing != "" {
+				return missing
+			}
+		case field.Kind() == reflect.String, field.Kind() == reflect.Ptr,
+			field.Kind() == reflect.Slice, field.Kind() == reflect.Map:
+			if field.IsZero() {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// jsonField returns a struct field's JSON name, or "" if it isn't encoded
+func jsonField(f reflect.StructField) (name string, omitempty bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	return name, strings.Contains(","+opts+",", ",omitempty,")
+}
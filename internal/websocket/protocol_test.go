@@ -0,0 +1,138 @@
+package websocket
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestNegotiateProtocol(t *testing.T) {
+	tests := []struct {
+		offered string
+		want    int
+		wantErr bool
+	}{
+		{offered: "", want: ProtocolVersion},
+		{offered: "1", want: 1},
+		{offered: "1, 99", want: 1},
+		{offered: "abc,1", want: 1},
+		{offered: "99", wantErr: true},
+		{offered: "0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := NegotiateProtocol(tt.offered)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NegotiateProtocol(%q) = %d, %v; want %d, error %v", tt.offered, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestDecodeEventRejectsInvalidFrames(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame string
+		code  string
+	}{
+		{"not JSON", `hello`, ErrorMalformedFrame},
+		{"not an object", `["ping"]`, ErrorMalformedFrame},
+		{"no type", `{"roomId":"room-1"}`, ErrorMalformedFrame},
+		{"type not a string", `{"type":1}`, ErrorMalformedFrame},
+		{"unknown type", `{"type":"join_room"}`, ErrorUnknownEvent},
+		{"server-only type", `{"type":"match_found","roomId":"room-1"}`, ErrorUnknownEvent},
+		{"missing field", `{"type":"accept_match"}`, ErrorInvalidPayload},
+		{"missing nested field", `{"type":"auth","data":{}}`, ErrorInvalidPayload},
+		{"wrong field type", `{"type":"recording_consent","data":{"granted":"yes"}}`, ErrorInvalidPayload},
+		{"missing boolean", `{"type":"recording_consent","data":{"policyVersion":"2024-01"}}`, ErrorInvalidPayload},
+		{"null payload", `{"type":"webrtc_offer","to":"room-1","sdp":null}`, ErrorInvalidPayload},
+		{"failed rule", `{"type":"transcript_segment","data":{"text":"hi","startTime":5,"endTime":2}}`, ErrorInvalidPayload},
+		{"empty media state", `{"type":"media_state_changed","data":{}}`, ErrorInvalidPayload},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeEvent([]byte(tt.frame))
+			frameErr, ok := err.(*FrameError)
+			if !ok {
+				t.Fatalf("DecodeEvent(%s) error = %v, want a FrameError", tt.frame, err)
+			}
+			if frameErr.Code != tt.code {
+				t.Errorf("DecodeEvent(%s) code = %s, want %s", tt.frame, frameErr.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestDecodeEventReturnsTypedEvent(t *testing.T) {
+	event, err := DecodeEvent([]byte(`{"type":"media_state_changed","roomId":"room-1","data":{"isMuted":false,"isVideoOff":true},"extra":1}`))
+	if err != nil {
+		t.Fatalf("DecodeEvent() error = %v", err)
+	}
+
+	msg, ok := event.(*MediaStateChangeEvent)
+	if !ok {
+		t.Fatalf("DecodeEvent() = %T, want *MediaStateChangeEvent", event)
+	}
+	if msg.RoomID != "room-1" || *msg.Data.IsMuted || !*msg.Data.IsVideoOff {
+		t.Errorf("DecodeEvent() = %+v", msg)
+	}
+}
+
+func TestPartialMediaStateIsRelayed(t *testing.T) {
+	h := newTestHub()
+	alice := connect(h, "alice")
+	bob := connect(h, "bob")
+	joinRoom(h, "room-1", "alice", "bob")
+
+	alice.handleMessage([]byte(`{"type":"media_state_changed","roomId":"room-1","data":{"isMuted":true}}`))
+
+	messages := received(t, bob)
+	if len(messages) != 1 {
+		t.Fatalf("bob got %d messages, want 1", len(messages))
+	}
+	if _, ok := messages[0]["isVideoOff"]; ok || messages[0]["isMuted"] != true {
+		t.Errorf("bob got %v, want only isMuted", messages[0])
+	}
+}
+
+func TestInvalidFrameGetsErrorEvent(t *testing.T) {
+	h := newTestHub()
+	alice := connect(h, "alice")
+
+	alice.handleMessage([]byte(`{"type":"accept_match","roomId":""}`))
+
+	messages := received(t, alice)
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	if messages[0]["type"] != EventError || messages[0]["code"] != ErrorInvalidPayload || messages[0]["event"] != EventAcceptMatch {
+		t.Errorf("got %v, want an INVALID_PAYLOAD error for accept_match", messages[0])
+	}
+}
+
+func TestSchemaIsUpToDate(t *testing.T) {
+	schema, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema() error = %v", err)
+	}
+
+	var parsed struct {
+		Defs map[string]json.RawMessage `json:"$defs"`
+	}
+	if err := json.Unmarshal(schema, &parsed); err != nil {
+		t.Fatalf("Schema() is not valid JSON: %v", err)
+	}
+	for _, name := range []string{"ClientEvent", "ServerEvent", "ErrorEvent", "WebRTCOfferEvent"} {
+		if _, ok := parsed.Defs[name]; !ok {
+			t.Errorf("schema has no definition of %s", name)
+		}
+	}
+
+	committed, err := os.ReadFile("../../api/websocket.schema.json")
+	if err != nil {
+		t.Fatalf("reading committed schema: %v", err)
+	}
+	if string(committed) != string(schema)+"\n" {
+		t.Error("api/websocket.schema.json is out of date; run go generate ./internal/websocket")
+	}
+}
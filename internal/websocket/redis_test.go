@@ -0,0 +1,486 @@
+package websocket
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PRM710/Rankedterview-backend/internal/database"
+)
+
+// fakeRedis is an in-memory Redis speaking RESP2, with just the commands the
+// hub uses. The hub's Lua scripts are answered by Go versions of them.
+type fakeRedis struct {
+	mu        sync.Mutex
+	strings   map[string]string
+	expiries  map[string]time.Time
+	streams   map[string][]streamEntry
+	zsets     map[string]map[string]float64
+	hashes    map[string]map[string]string
+	published []published
+}
+
+type streamEntry struct {
+	seq     int64
+	payload string
+}
+
+type published struct {
+	channel string
+	message string
+}
+
+// newRedisTestHub returns a hub backed by a fake Redis
+func newRedisTestHub(t *testing.T) (*Hub, *fakeRedis) {
+	t.Helper()
+
+	fake := &fakeRedis{
+		strings:  map[string]string{},
+		expiries: map[string]time.Time{},
+		streams:  map[string][]streamEntry{},
+		zsets:    map[string]map[string]float64{},
+		hashes:   map[string]map[string]string{},
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go fake.serve(conn)
+		}
+	}()
+
+	redis := database.NewRedis(listener.Addr().String(), "", 0)
+	t.Cleanup(func() {
+		redis.Close()
+		listener.Close()
+	})
+
+	return NewHub(redis, "test-secret"), fake
+}
+
+// get returns a string key, honouring its expiry. The caller holds mu.
+func (f *fakeRedis) get(key string) (string, bool) {
+	if expiry, ok := f.expiries[key]; ok && !time.Now().Before(expiry) {
+		f.del(key)
+	}
+	value, ok := f.strings[key]
+	return value, ok
+}
+
+// del removes a key of any type. The caller holds mu.
+func (f *fakeRedis) del(key string) int {
+	_, isString := f.strings[key]
+	_, isStream := f.streams[key]
+	_, isZSet := f.zsets[key]
+	_, isHash := f.hashes[key]
+	delete(f.strings, key)
+	delete(f.expiries, key)
+	delete(f.streams, key)
+	delete(f.zsets, key)
+	delete(f.hashes, key)
+	if isString || isStream || isZSet || isHash {
+		return 1
+	}
+	return 0
+}
+
+func (f *fakeRedis) set(key, value string, ttl time.Duration) {
+	f.strings[key] = value
+	delete(f.expiries, key)
+	if ttl > 0 {
+		f.expiries[key] = time.Now().Add(ttl)
+	}
+}
+
+// Set stores a string key
+func (f *fakeRedis) Set(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.set(key, value, 0)
+}
+
+// Get returns a string key
+func (f *fakeRedis) Get(key string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.get(key)
+}
+
+// ZScore returns the score of a sorted set member
+func (f *fakeRedis) ZScore(key, member string) (float64, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	score, ok := f.zsets[key][member]
+	return score, ok
+}
+
+// ZAdd adds a sorted set member
+func (f *fakeRedis) ZAdd(key, member string, score float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.zadd(key, member, score)
+}
+
+func (f *fakeRedis) zadd(key, member string, score float64) {
+	if f.zsets[key] == nil {
+		f.zsets[key] = map[string]float64{}
+	}
+	f.zsets[key][member] = score
+}
+
+// Stream returns the sequence numbers kept in a stream
+func (f *fakeRedis) Stream(key string) []int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var seqs []int64
+	for _, entry := range f.streams[key] {
+		seqs = append(seqs, entry.seq)
+	}
+	return seqs
+}
+
+// Published returns the messages published so far
+func (f *fakeRedis) Published() []published {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]published(nil), f.published...)
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		reply := f.exec(args)
+		f.mu.Unlock()
+		writeReply(w, reply)
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// Replies are written by type: string is a bulk string, status a simple
+// string, error an error, int64 an integer, nil a null and []interface{} an array
+type status string
+type redisError string
+
+func (f *fakeRedis) exec(args []string) interface{} {
+	command := strings.ToUpper(args[0])
+	args = args[1:]
+
+	switch command {
+	case "PING":
+		return status("PONG")
+
+	case "GET":
+		if value, ok := f.get(args[0]); ok {
+			return value
+		}
+		return nil
+
+	case "GETDEL":
+		value, ok := f.get(args[0])
+		if !ok {
+			return nil
+		}
+		f.del(args[0])
+		return value
+
+	case "SET":
+		return f.execSet(args)
+
+	case "DEL":
+		var removed int64
+		for _, key := range args {
+			f.get(key) // drop it if it expired
+			removed += int64(f.del(key))
+		}
+		return removed
+
+	case "EXISTS":
+		var found int64
+		for _, key := range args {
+			if _, ok := f.get(key); ok {
+				found++
+			} else if _, ok := f.streams[key]; ok {
+				found++
+			}
+		}
+		return found
+
+	case "INCR":
+		value, _ := f.get(args[0])
+		n, _ := strconv.ParseInt(value, 10, 64)
+		n++
+		f.strings[args[0]] = strconv.FormatInt(n, 10)
+		return n
+
+	case "PEXPIRE":
+		ms, _ := strconv.ParseInt(args[1], 10, 64)
+		if _, ok := f.get(args[0]); ok {
+			f.expiries[args[0]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			return int64(1)
+		}
+		return int64(0)
+
+	case "PUBLISH":
+		f.published = append(f.published, published{channel: args[0], message: args[1]})
+		return int64(0)
+
+	case "HGETALL":
+		var reply []interface{}
+		for field, value := range f.hashes[args[0]] {
+			reply = append(reply, field, value)
+		}
+		return reply
+
+	case "ZREM":
+		var removed int64
+		for _, member := range args[1:] {
+			if _, ok := f.zsets[args[0]][member]; ok {
+				delete(f.zsets[args[0]], member)
+				removed++
+			}
+		}
+		return removed
+
+	case "ZRANGEBYSCORE":
+		return f.execZRangeByScore(args)
+
+	case "XRANGE":
+		start, _ := strconv.ParseInt(strings.TrimSuffix(args[1], "-0"), 10, 64)
+		end, _ := strconv.ParseInt(strings.TrimSuffix(args[2], "-0"), 10, 64)
+		reply := []interface{}{}
+		for _, entry := range f.streams[args[0]] {
+			if entry.seq >= start && entry.seq <= end {
+				reply = append(reply, []interface{}{
+					strconv.FormatInt(entry.seq, 10) + "-0",
+					[]interface{}{"payload", entry.payload},
+				})
+			}
+		}
+		return reply
+
+	case "XTRIM":
+		if strings.ToUpper(args[1]) != "MINID" {
+			return redisError("ERR only MINID is supported")
+		}
+		minID, _ := strconv.ParseInt(args[2], 10, 64)
+		var kept []streamEntry
+		var trimmed int64
+		for _, entry := range f.streams[args[0]] {
+			if entry.seq < minID {
+				trimmed++
+				continue
+			}
+			kept = append(kept, entry)
+		}
+		f.streams[args[0]] = kept
+		return trimmed
+
+	case "EVALSHA":
+		script, ok := fakeScripts[args[0]]
+		if !ok {
+			return redisError("NOSCRIPT No matching script")
+		}
+		numKeys, _ := strconv.Atoi(args[1])
+		return script(f, args[2:2+numKeys], args[2+numKeys:])
+	}
+
+	return redisError("ERR unknown command '" + command + "'")
+}
+
+func (f *fakeRedis) execSet(args []string) interface{} {
+	key, value := args[0], args[1]
+	var ttl time.Duration
+	var nx, get bool
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "PX":
+			ms, _ := strconv.ParseInt(args[i+1], 10, 64)
+			ttl = time.Duration(ms) * time.Millisecond
+			i++
+		case "EX":
+			s, _ := strconv.ParseInt(args[i+1], 10, 64)
+			ttl = time.Duration(s) * time.Second
+			i++
+		case "NX":
+			nx = true
+		case "GET":
+			get = true
+		}
+	}
+
+	previous, existed := f.get(key)
+	if nx && existed {
+		return nil
+	}
+	f.set(key, value, ttl)
+
+	if get {
+		if existed {
+			return previous
+		}
+		return nil
+	}
+	return status("OK")
+}
+
+func (f *fakeRedis) execZRangeByScore(args []string) interface{} {
+	min := parseScore(args[1])
+	max := parseScore(args[2])
+	limit := -1
+	for i := 3; i+2 < len(args); i++ {
+		if strings.ToUpper(args[i]) == "LIMIT" {
+			limit, _ = strconv.Atoi(args[i+2])
+		}
+	}
+
+	var members []string
+	for member, score := range f.zsets[args[0]] {
+		if score >= min && score <= max {
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return f.zsets[args[0]][members[i]] < f.zsets[args[0]][members[j]]
+	})
+	if limit >= 0 && len(members) > limit {
+		members = members[:limit]
+	}
+
+	reply := []interface{}{}
+	for _, member := range members {
+		reply = append(reply, member)
+	}
+	return reply
+}
+
+func parseScore(value string) float64 {
+	switch value {
+	case "-inf":
+		return -1 << 62
+	case "+inf", "inf":
+		return 1 << 62
+	}
+	score, _ := strconv.ParseFloat(value, 64)
+	return score
+}
+
+// fakeScripts are Go versions of the hub's Lua scripts, by SHA1
+var fakeScripts map[string]func(f *fakeRedis, keys, args []string) interface{}
+
+func init() {
+	fakeScripts = map[string]func(f *fakeRedis, keys, args []string) interface{}{
+		appendEventScript.Hash(): func(f *fakeRedis, keys, args []string) interface{} {
+			seq := f.exec([]string{"INCR", keys[0]}).(int64)
+			entries := append(f.streams[keys[1]], streamEntry{seq: seq, payload: args[0]})
+			if max, _ := strconv.Atoi(args[1]); len(entries) > max {
+				entries = entries[len(entries)-max:]
+			}
+			f.streams[keys[1]] = entries
+			return seq
+		},
+
+		releasePresenceScript.Hash(): func(f *fakeRedis, keys, args []string) interface{} {
+			if value, ok := f.get(keys[0]); ok && value == args[0] {
+				return int64(f.del(keys[0]))
+			}
+			return int64(0)
+		},
+
+		refreshPresenceScript.Hash(): func(f *fakeRedis, keys, args []string) interface{} {
+			if value, ok := f.get(keys[0]); !ok || value == args[0] {
+				ms, _ := strconv.ParseInt(args[1], 10, 64)
+				f.set(keys[0], args[0], time.Duration(ms)*time.Millisecond)
+				return int64(1)
+			}
+			return int64(0)
+		},
+
+		holdRoomScript.Hash(): func(f *fakeRedis, keys, args []string) interface{} {
+			if value, ok := f.get(keys[0]); ok && value != args[0] {
+				return int64(0)
+			}
+			f.del(keys[0])
+			ms, _ := strconv.ParseInt(args[2], 10, 64)
+			f.set(keys[1], args[1], time.Duration(ms)*time.Millisecond)
+			score, _ := strconv.ParseFloat(args[3], 64)
+			f.zadd(keys[2], args[4], score)
+			return int64(1)
+		},
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func writeReply(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case status:
+		w.WriteString("+" + string(v) + "\r\n")
+	case redisError:
+		w.WriteString("-" + string(v) + "\r\n")
+	case int64:
+		w.WriteString(":" + strconv.FormatInt(v, 10) + "\r\n")
+	case string:
+		w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+	case []interface{}:
+		w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	}
+}
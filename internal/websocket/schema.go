@@ -0,0 +1,181 @@
+package websocket
+
+//go:generate go run ../../cmd/wsschema -o ../../api/websocket.schema.json
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+// Schema returns a JSON Schema (draft 2020-12) of the event protocol, built
+// from the event types. Each event type is a definition whose "type" lists
+// the events it is used for; ClientEvent and ServerEvent match any frame a
+// client or the server may send. Fields without omitempty are required.
+// Reliable events also carry the "seq" they are delivered with.
+func Schema() ([]byte, error) {
+	g := &schemaGenerator{defs: map[string]interface{}{}}
+
+	names := map[reflect.Type][]string{}
+	for _, events := range []map[string]interface{}{clientEvents, serverEvents} {
+		for name, proto := range events {
+			t := reflect.TypeOf(proto)
+			if !containsString(names[t], name) {
+				names[t] = append(names[t], name)
+			}
+		}
+	}
+	for t := range names {
+		sort.Strings(names[t])
+	}
+	g.eventNames = names
+
+	schema := map[string]interface{}{
+		"$schema":           "https://json-schema.org/draft/2020-12/schema",
+		"title":             "RANKEDterview WebSocket protocol",
+		"x-protocolVersion": ProtocolVersion,
+		// Signaling and call_ended frames are sent both ways
+		"anyOf": []interface{}{
+			ref("ClientEvent"),
+			ref("ServerEvent"),
+		},
+		"$defs": g.defs,
+	}
+	g.defs["ClientEvent"] = map[string]interface{}{"oneOf": g.eventRefs(clientEvents)}
+	g.defs["ServerEvent"] = map[string]interface{}{"oneOf": g.eventRefs(serverEvents)}
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+type schemaGenerator struct {
+	defs       map[string]interface{}
+	eventNames map[reflect.Type][]string
+}
+
+// eventRefs references the types of a set of events, once each
+func (g *schemaGenerator) eventRefs(events map[string]interface{}) []interface{} {
+	var typeNames []string
+	seen := map[string]bool{}
+	for _, proto := range events {
+		t := reflect.TypeOf(proto)
+		if !seen[t.Name()] {
+			seen[t.Name()] = true
+			typeNames = append(typeNames, g.define(t))
+		}
+	}
+	sort.Strings(typeNames)
+
+	refs := make([]interface{}, len(typeNames))
+	for i, name := range typeNames {
+		refs[i] = ref(name)
+	}
+	return refs
+}
+
+// define adds a struct type to $defs and returns its name
+func (g *schemaGenerator) define(t reflect.Type) string {
+	name := t.Name()
+	if _, ok := g.defs[name]; ok {
+		return name
+	}
+	g.defs[name] = nil // guards against recursive types
+
+	properties := map[string]interface{}{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field, omitempty := jsonField(t.Field(i))
+		if field == "" {
+			continue
+		}
+
+		if field == "type" && g.eventNames[t] != nil {
+			properties[field] = eventTypeSchema(g.eventNames[t])
+		} else {
+			properties[field] = g.typeSchema(t.Field(i).Type)
+		}
+		if !omitempty {
+			required = append(required, field)
+		}
+	}
+
+	// Reliable events are delivered with their sequence number
+	for _, event := range g.eventNames[t] {
+		if reliableEvents[event] {
+			properties["seq"] = map[string]interface{}{"type": "integer", "minimum": 1}
+		}
+	}
+
+	g.defs[name] = map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+	return name
+}
+
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == rawMessageType, t.Kind() == reflect.Interface:
+		return map[string]interface{}{}
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.typeSchema(t.Elem())
+	case reflect.Struct:
+		return ref(g.define(t))
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	}
+	return map[string]interface{}{"type": jsonTypeName(t)}
+}
+
+func eventTypeSchema(names []string) map[string]interface{} {
+	if len(names) == 1 {
+		return map[string]interface{}{"const": names[0]}
+	}
+	return map[string]interface{}{"enum": names}
+}
+
+func ref(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/$defs/" + name}
+}
+
+// jsonTypeName names the JSON type a Go type is encoded as
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Ptr:
+		return jsonTypeName(t.Elem())
+	}
+	return "object"
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}